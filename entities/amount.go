@@ -0,0 +1,40 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/vovamod/BankAPI/money"
+)
+
+// Values are sent as decimals of their currency and kept as minor units, see package money
+
+// units reads m as minor units of currency
+func units(m money.Money, currency string) (int, error) {
+	v, err := m.In(currency)
+	return int(v.Amount), err
+}
+
+// decimal is v minor units of currency as clients see it
+func decimal(v int, currency string) money.Money {
+	return money.New(int64(v), currency)
+}
+
+// badValue reports whether err comes from reading a value
+func badValue(err error) bool {
+	return errors.Is(err, money.ErrPrecision) || errors.Is(err, money.ErrOverflow) || errors.Is(err, money.ErrSyntax)
+}
+
+// invalidValue answers with why a value could not be read
+func invalidValue(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid value, " + err.Error()})
+}
+
+// currencyByName is the currency of the account named name, empty for system accounts and accounts that do not exist
+func currencyByName(ctx context.Context, name string) string {
+	a, err := st.GetAccountByName(ctx, name)
+	if err != nil {
+		return ""
+	}
+	return currencyOf(a)
+}
@@ -0,0 +1,100 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/vovamod/BankAPI/store"
+	"github.com/vovamod/BankAPI/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"slices"
+	"time"
+)
+
+// apiScopes are the permissions API keys may hold, a key cannot manage keys or tokens nor issue value
+var apiScopes = without(Permissions, "keys:manage", "tokens:manage", "tokens:issue", "transactions:issue")
+
+type apiKeyBody struct {
+	Name       string    `json:"name"`
+	Scopes     []string  `json:"scopes"`
+	AllowedIPs []string  `json:"allowedIps"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// key checks the body and turns it into a key without its secret
+func (b apiKeyBody) key() (store.APIKey, error) {
+	if b.Name == "" {
+		return store.APIKey{}, errors.New("Give the API key a name")
+	}
+	if len(b.Scopes) == 0 {
+		return store.APIKey{}, errors.New("Give the API key at least one scope")
+	}
+	for _, scope := range b.Scopes {
+		if !slices.Contains(apiScopes, scope) {
+			return store.APIKey{}, fmt.Errorf("Unknown scope %s", scope)
+		}
+	}
+	for _, ip := range b.AllowedIPs {
+		if _, err := utils.ParseIPRange(ip); err != nil {
+			return store.APIKey{}, fmt.Errorf("Invalid address or CIDR range %s", ip)
+		}
+	}
+	if !b.ExpiresAt.IsZero() && !b.ExpiresAt.After(time.Now()) {
+		return store.APIKey{}, errors.New("API key would be expired already")
+	}
+	return store.APIKey{Name: b.Name, Scopes: b.Scopes, AllowedIPs: b.AllowedIPs, ExpiresAt: b.ExpiresAt}, nil
+}
+
+// CreateAPIKey makes a named key for an integration. Its value is answered once, only its hash is kept
+func CreateAPIKey(c *fiber.Ctx) error {
+	var body apiKeyBody
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	k, err := body.key()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	secret, err := utils.NewSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate API key"})
+	}
+	// The prefix tells keys apart from other secrets in configs and leaks
+	secret = "bk_" + secret
+	k.Id, k.Hash, k.CreatedAt, k.CreatedBy = primitive.NewObjectID(), utils.HashSecret(secret), time.Now(), caller(c)
+	err = st.CreateAPIKey(context.Background(), &k)
+	if errors.Is(err, store.ErrDuplicate) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid API key name provided. This name is already taken"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": "API key has been created", "key": secret, "data": k})
+}
+
+func GetAllAPIKeys(c *fiber.Ctx) error {
+	return GetAll(c, func(ctx context.Context, _ struct{}, p store.Page) (*store.Result[store.APIKey], error) {
+		return st.ListAPIKeys(ctx, p)
+	}, struct{}{})
+}
+
+func GetAPIKeyByID(c *fiber.Ctx) error {
+	return GetByID(c, st.GetAPIKey)
+}
+
+// DeleteAPIKeyByID revokes a key, requests using it are refused right away
+func DeleteAPIKeyByID(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	err = st.DeleteAPIKey(context.Background(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "API key deleted successfully"})
+}
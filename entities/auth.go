@@ -0,0 +1,190 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/vovamod/BankAPI/store"
+	"github.com/vovamod/BankAPI/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"slices"
+	"time"
+)
+
+// userRole is the role of tokens given to players, they only reach their own accounts
+const userRole = "USER"
+
+// challengeTTL is how long a player has to redeem a challenge the game server gave them
+const challengeTTL = 5 * time.Minute
+
+var (
+	errNotOwner     = errors.New("This account does not belong to you")
+	errPlayerCharge = errors.New("Only the bank may charge an account with a negative value")
+	errNoAccount    = errors.New("Give one of your accounts with ?account=")
+	errIssue        = errors.New("Only the bank may issue value from BANK_ISSUER")
+	errNotSelf      = errors.New("You may only see your own user")
+)
+
+type challengeBody struct {
+	Name      string `json:"name"`
+	Challenge string `json:"challenge"`
+}
+
+// CreateChallenge signs a login challenge for a user, the game server hands it to the player it knows by ObjectId
+func CreateChallenge(c *fiber.Ctx) error {
+	var body challengeBody
+	if err := c.BodyParser(&body); err != nil || body.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	u, err := st.GetUserByName(context.Background(), body.Name)
+	if err == nil && !u.DeletedAt.IsZero() {
+		err = store.ErrNotFound
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid username provided"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	expiresAt := time.Now().Add(challengeTTL)
+	challenge, err := utils.GenerateChallenge(u.Id.Hex(), u.ObjectId, challengeTTL)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate challenge"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"challenge": challenge, "expiresAt": expiresAt})
+}
+
+// AuthUser trades a challenge for a USER token of the player it was signed for
+func AuthUser(c *fiber.Ctx) error {
+	var body challengeBody
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid challenge provided"})
+	}
+	userID, objectID, ok := utils.VerifyChallenge(body.Challenge)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid challenge provided"})
+	}
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid challenge provided"})
+	}
+	u, err := liveUser(context.Background(), id)
+	if err != nil || u.ObjectId != objectID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid challenge provided"})
+	}
+	return issueTokens(c, u.Id.Hex(), userRole, "")
+}
+
+// playerOf is the user calling with a USER token, nil for the bank which reaches every account
+func playerOf(c *fiber.Ctx) (*store.User, error) {
+	if !hasRole(c, userRole) {
+		return nil, nil
+	}
+	id, err := primitive.ObjectIDFromHex(caller(c))
+	if err != nil {
+		return nil, store.ErrNotFound
+	}
+	return liveUser(context.Background(), id)
+}
+
+// owns tells whether the account a is linked to u
+func owns(u *store.User, a *store.Account) bool {
+	return slices.Contains(u.Account, a.Id.Hex())
+}
+
+// ownerResponse answers a player reaching what is not theirs, a deleted player being no one
+func ownerResponse(c *fiber.Ctx, err error) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid authentication token"})
+	}
+	if errors.Is(err, errNotOwner) || errors.Is(err, errPlayerCharge) || errors.Is(err, errNoAccount) || errors.Is(err, errIssue) ||
+		errors.Is(err, errNotSelf) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+	return dbError(c, err)
+}
+
+// OwnAccount lets players through only to their own account :id, the bank always goes through
+func OwnAccount(c *fiber.Ctx) error {
+	u, err := playerOf(c)
+	if err != nil {
+		return ownerResponse(c, err)
+	}
+	if u == nil {
+		return c.Next()
+	}
+	id, err := paramID(c, "id")
+	if err != nil || !slices.Contains(u.Account, id.Hex()) {
+		return ownerResponse(c, errNotOwner)
+	}
+	return c.Next()
+}
+
+// OwnUser lets players through only to themselves as user :id
+func OwnUser(c *fiber.Ctx) error {
+	u, err := playerOf(c)
+	if err != nil {
+		return ownerResponse(c, err)
+	}
+	if u != nil && c.Params("id") != u.Id.Hex() {
+		return ownerResponse(c, errNotSelf)
+	}
+	return c.Next()
+}
+
+// OwnTransaction lets players through only to a transaction :id paid or received by one of their accounts
+func OwnTransaction(c *fiber.Ctx) error {
+	u, err := playerOf(c)
+	if err != nil {
+		return ownerResponse(c, err)
+	}
+	if u == nil {
+		return c.Next()
+	}
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	ctx := context.Background()
+	t, err := st.GetTransaction(ctx, id)
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	for _, name := range []string{t.ByWho, t.ToWho} {
+		a, err := st.GetAccountByName(ctx, name)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return dbError(c, err)
+		}
+		if owns(u, a) {
+			return c.Next()
+		}
+	}
+	return ownerResponse(c, errNotOwner)
+}
+
+// checkOwner refuses a player using the account name when it is not theirs
+func checkOwner(ctx context.Context, u *store.User, name string) error {
+	a, err := st.GetAccountByName(ctx, name)
+	if errors.Is(err, store.ErrNotFound) {
+		return errNotOwner
+	}
+	if err != nil {
+		return err
+	}
+	if !owns(u, a) {
+		return errNotOwner
+	}
+	return nil
+}
+
+// checkIssuer refuses callers without transactions:issue paying from payer when it is BANK_ISSUER, which never
+// runs out and so creates what it pays
+func checkIssuer(c *fiber.Ctx, payer string) error {
+	if payer == bankIssuer && !can(c, "transactions:issue") {
+		return errIssue
+	}
+	return nil
+}
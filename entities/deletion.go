@@ -0,0 +1,199 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+var (
+	errNotDeleted    = errors.New("Only a deleted record can be restored")
+	errUserAccounts  = errors.New("User has accounts that are not closed. User will not be deleted unless they are closed")
+	errStopReference = errors.New("referenced")
+)
+
+// caller is who made the request, the user of its token
+func caller(c *fiber.Ctx) string {
+	user, _ := c.Locals("user").(string)
+	return user
+}
+
+// liveAccount gets an account unless it is deleted, for handlers showing accounts to clients
+func liveAccount(ctx context.Context, id primitive.ObjectID) (*store.Account, error) {
+	a, err := st.GetAccount(ctx, id)
+	if err == nil && !a.DeletedAt.IsZero() {
+		return nil, store.ErrNotFound
+	}
+	return a, err
+}
+
+// liveUser gets a user unless it is deleted
+func liveUser(ctx context.Context, id primitive.ObjectID) (*store.User, error) {
+	u, err := st.GetUser(ctx, id)
+	if err == nil && !u.DeletedAt.IsZero() {
+		return nil, store.ErrNotFound
+	}
+	return u, err
+}
+
+// GetDeletedAccounts lists the deleted accounts not purged yet
+func GetDeletedAccounts(c *fiber.Ctx) error {
+	return GetAll(c, st.ListAccounts, store.AccountFilter{NamePrefix: c.Query("name"), Deleted: true})
+}
+
+// GetDeletedUsers lists the deleted users not purged yet
+func GetDeletedUsers(c *fiber.Ctx) error {
+	return GetAll(c, st.ListUsers, store.UserFilter{NamePrefix: c.Query("name"), Deleted: true})
+}
+
+// RestoreAccountByID brings back a deleted account, it stays closed
+func RestoreAccountByID(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
+	}
+	var a *store.Account
+	err = st.WithTx(context.Background(), func(ctx context.Context, s store.Store) error {
+		var err error
+		if a, err = s.GetAccount(ctx, id); err != nil {
+			return err
+		}
+		if a.DeletedAt.IsZero() {
+			return errNotDeleted
+		}
+		a.DeletedAt, a.DeletedBy = time.Time{}, ""
+		return s.UpdateAccount(ctx, a)
+	})
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
+	case errors.Is(err, errNotDeleted):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Account restored successfully", "data": a})
+}
+
+// RestoreUserByID brings back a deleted user with its accounts
+func RestoreUserByID(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid user ID provided"})
+	}
+	var u *store.User
+	err = st.WithTx(context.Background(), func(ctx context.Context, s store.Store) error {
+		var err error
+		if u, err = s.GetUser(ctx, id); err != nil {
+			return err
+		}
+		if u.DeletedAt.IsZero() {
+			return errNotDeleted
+		}
+		u.DeletedAt, u.DeletedBy = time.Time{}, ""
+		return s.UpdateUser(ctx, u)
+	})
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid user ID provided"})
+	case errors.Is(err, errNotDeleted):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User restored successfully", "data": u})
+}
+
+// PurgeDeleted removes for good the users and accounts deleted before before that nothing refers to anymore.
+// Users go first, they are what refers to accounts
+func PurgeDeleted(ctx context.Context, before time.Time) error {
+	err := each(ctx, st.ListUsers, store.UserFilter{Deleted: true}, func(u store.User) error {
+		if u.DeletedAt.After(before) {
+			return nil
+		}
+		used, err := userReferenced(ctx, &u)
+		if err != nil || used {
+			return err
+		}
+		log.Infof("Purging user %s deleted at %v", u.Name, u.DeletedAt)
+		if err := st.DeleteUser(ctx, u.Id); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return each(ctx, st.ListAccounts, store.AccountFilter{Deleted: true}, func(a store.Account) error {
+		if a.DeletedAt.After(before) {
+			return nil
+		}
+		used, err := accountReferenced(ctx, &a)
+		if err != nil || used {
+			return err
+		}
+		log.Infof("Purging account %s deleted at %v", a.Name, a.DeletedAt)
+		if err := st.DeleteAccount(ctx, a.Id); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		return nil
+	})
+}
+
+// userReferenced tells whether u still owns an account that is not deleted, purging it would lose who owns it
+func userReferenced(ctx context.Context, u *store.User) (bool, error) {
+	for _, account := range u.Account {
+		id, err := primitive.ObjectIDFromHex(account)
+		if err != nil {
+			continue
+		}
+		a, err := st.GetAccount(ctx, id)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if a.DeletedAt.IsZero() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// accountReferenced tells whether anything still refers to a: a user, a transaction, a posting, a hold,
+// an escrow or a standing order
+func accountReferenced(ctx context.Context, a *store.Account) (bool, error) {
+	one := store.Page{Limit: 1}
+	if _, err := st.GetUserByAccount(ctx, a.Id.Hex()); err == nil || !errors.Is(err, store.ErrNotFound) {
+		return err == nil, err
+	}
+	if r, err := st.ListTransactions(ctx, store.TransactionFilter{Account: a.Name}, one); err != nil || len(r.Data) > 0 {
+		return err == nil, err
+	}
+	if _, count, err := st.SumPostings(ctx, a.Id); err != nil || count > 0 {
+		return err == nil, err
+	}
+	if r, err := st.ListHolds(ctx, store.HoldFilter{Account: a.Name}, one); err != nil || len(r.Data) > 0 {
+		return err == nil, err
+	}
+	if r, err := st.ListEscrows(ctx, store.EscrowFilter{Account: a.Name}, one); err != nil || len(r.Data) > 0 {
+		return err == nil, err
+	}
+	err := each(ctx, func(ctx context.Context, _ struct{}, p store.Page) (*store.Result[store.StandingOrder], error) {
+		return st.ListStandingOrders(ctx, p)
+	}, struct{}{}, func(o store.StandingOrder) error {
+		if o.ByWho == a.Name || o.ToWho == a.Name {
+			return errStopReference
+		}
+		return nil
+	})
+	if errors.Is(err, errStopReference) {
+		return true, nil
+	}
+	return false, err
+}
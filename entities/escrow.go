@@ -0,0 +1,232 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+	"github.com/vovamod/BankAPI/money"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// bankEscrow is the system account keeping the value of every open escrow
+const bankEscrow = "BANK_ESCROW"
+
+// Escrow statuses
+const (
+	escrowOpen     = "open"
+	escrowReleased = "released"
+	escrowRefunded = "refunded"
+	escrowSplit    = "split"
+)
+
+var (
+	errEscrowAccount = &refusal{"ESCROW_ACCOUNT", "Invalid transaction. BANK_ESCROW only moves through escrows"}
+	errEscrowClosed  = errors.New("Invalid escrow. It has already been released, refunded or split")
+	errSplitTooLarge = errors.New("Invalid split. Value to seller must be between 0 and the value of the escrow")
+)
+
+// Static escrow account ID
+var chEscrow primitive.ObjectID
+
+// EscrowInit makes sure the escrow account exists, like BankInit does for BANK_ISSUER
+func EscrowInit(s store.Store) {
+	chEscrow = systemAccount(s, bankEscrow)
+}
+
+// systemAccount returns the ID of the system account name, creating it when missing
+func systemAccount(s store.Store, name string) primitive.ObjectID {
+	ac, err := s.GetAccountByName(context.Background(), name)
+	if errors.Is(err, store.ErrNotFound) {
+		log.Infof("No %s exists. Creating a new %s...", name, name)
+		ac = &store.Account{Id: primitive.NewObjectID(), Name: name, AccountId: uuid.NewString(), Overdraft: overdraftNone}
+		if errE := s.CreateAccount(context.Background(), ac); errE != nil {
+			log.Fatalf("Error creating %s: %v", name, errE)
+		}
+		log.Infof("Created %s: %v", name, ac.Id)
+	} else if err != nil {
+		log.Fatalf("Error checking %s: %v", name, err)
+	}
+	return ac.Id
+}
+
+type split struct {
+	ToSeller money.Money `json:"toSeller"`
+}
+
+// escrowBody is an escrow as clients send it, the value a decimal of the currency of the buyer
+type escrowBody struct {
+	store.Escrow
+	Value money.Money
+}
+
+// CreateEscrow moves the value from the buyer into the escrow account until the trade is settled
+func CreateEscrow(c *fiber.Ctx) error {
+	var body escrowBody
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	e := body.Escrow
+
+	// Validation
+	e.Currency = currencyByName(context.Background(), e.Buyer)
+	var err error
+	if e.Value, err = units(body.Value, e.Currency); err != nil {
+		return invalidValue(c, err)
+	}
+	if e.Buyer == "" || e.Seller == "" || e.Value <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing or invalid escrow fields"})
+	}
+	if e.Buyer == e.Seller {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Buyer and seller of an escrow must differ"})
+	}
+	if err := checkIssuer(c, e.Buyer); err != nil {
+		return ownerResponse(c, err)
+	}
+	if e.Name == "" {
+		e.Name = "Trade"
+	}
+	e.Id = primitive.NewObjectID()
+	e.Status = escrowOpen
+	e.CreatedAt = time.Now()
+	e.ToSeller, e.ToBuyer, e.ClosedAt = 0, 0, time.Time{}
+	as := asUser
+	if can(c, "accounts:overdraw") {
+		as = asAdmin
+	}
+	t := &store.Transaction{
+		Id:       primitive.NewObjectID(),
+		Value:    e.Value,
+		NameTZ:   "Escrow of " + e.Name,
+		Date:     e.CreatedAt,
+		ByWho:    e.Buyer,
+		ToWho:    bankEscrow,
+		Type:     typeEscrow,
+		Currency: e.Currency,
+	}
+	e.TransactionId = t.Id
+
+	// Actual logic here thou
+	err = st.WithTx(context.Background(), func(ctx context.Context, s store.Store) error {
+		seller, err := s.GetAccountByName(ctx, e.Seller)
+		if errors.Is(err, store.ErrNotFound) {
+			return errNoReceiver
+		}
+		if err != nil {
+			return err
+		}
+		if err := move(ctx, s, t, as); err != nil {
+			return err
+		}
+		// The escrow pays out what it took, so both sides must hold its currency
+		if currency := currencyOf(seller); currency != "" && currency != t.Currency {
+			return errCurrencyMismatch
+		}
+		e.Currency = t.Currency
+		return s.CreateEscrow(ctx, &e)
+	})
+	if refused(err) {
+		return refusedResponse(c, err, "")
+	}
+	if err != nil {
+		log.Errorf("Escrow %v failed: %v", e.Id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Transaction failed and has been rolled back"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": "Escrow has been created", "data": e})
+}
+func GetAllEscrows(c *fiber.Ctx) error {
+	return GetAll(c, st.ListEscrows, store.EscrowFilter{Account: c.Query("account"), Status: c.Query("status")})
+}
+func GetEscrowByID(c *fiber.Ctx) error {
+	return GetByID(c, st.GetEscrow)
+}
+
+// ReleaseEscrow pays the whole escrow to the seller
+func ReleaseEscrow(c *fiber.Ctx) error {
+	return closeEscrow(c, escrowReleased, func(e *store.Escrow) (int, error) { return e.Value, nil })
+}
+
+// RefundEscrow gives the whole escrow back to the buyer
+func RefundEscrow(c *fiber.Ctx) error {
+	return closeEscrow(c, escrowRefunded, func(e *store.Escrow) (int, error) { return 0, nil })
+}
+
+// SplitEscrow pays toSeller to the seller and the rest back to the buyer, it settles disputes so it takes escrows:settle
+func SplitEscrow(c *fiber.Ctx) error {
+	var body split
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	return closeEscrow(c, escrowSplit, func(e *store.Escrow) (int, error) { return units(body.ToSeller, e.Currency) })
+}
+
+// closeEscrow pays toSeller of the escrow to the seller and the rest to the buyer, each with its own transaction
+// from the escrow account
+func closeEscrow(c *fiber.Ctx, status string, toSeller func(e *store.Escrow) (int, error)) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	var e *store.Escrow
+	err = st.WithTx(context.Background(), func(ctx context.Context, s store.Store) error {
+		var err error
+		if e, err = s.GetEscrow(ctx, id); err != nil {
+			return err
+		}
+		if e.Status != escrowOpen {
+			return errEscrowClosed
+		}
+		if e.ToSeller, err = toSeller(e); err != nil {
+			return err
+		}
+		if e.ToSeller < 0 || e.ToSeller > e.Value {
+			return errSplitTooLarge
+		}
+		e.ToBuyer = e.Value - e.ToSeller
+		if err := payOut(ctx, s, e, e.Seller, e.ToSeller, typeEscrowRelease); err != nil {
+			return err
+		}
+		if err := payOut(ctx, s, e, e.Buyer, e.ToBuyer, typeEscrowRefund); err != nil {
+			return err
+		}
+		e.Status, e.ClosedAt = status, time.Now()
+		return s.UpdateEscrow(ctx, e)
+	})
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	case errors.Is(err, errEscrowClosed), errors.Is(err, errSplitTooLarge):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case badValue(err):
+		return invalidValue(c, err)
+	case refused(err):
+		return refusedResponse(c, err, "")
+	case err != nil:
+		log.Errorf("Failed to close escrow %v: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Transaction failed and has been rolled back"})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"success": "Escrow has been " + status, "data": e})
+}
+
+// payOut moves value of the escrow from the escrow account to the account, s must come from WithTx.
+// The escrow account holds exactly what its escrows were paid, so the transfer is not checked
+func payOut(ctx context.Context, s store.Store, e *store.Escrow, to string, value int, kind string) error {
+	if value == 0 {
+		return nil
+	}
+	t := &store.Transaction{
+		Id:         primitive.NewObjectID(),
+		Value:      value,
+		NameTZ:     "Escrow of " + e.Name,
+		Date:       time.Now(),
+		ByWho:      bankEscrow,
+		ToWho:      to,
+		Type:       kind,
+		OriginalId: e.TransactionId,
+		Currency:   e.Currency,
+	}
+	return move(ctx, s, t, forced)
+}
@@ -0,0 +1,127 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/vovamod/BankAPI/money"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	currencyCode = regexp.MustCompile(`^[A-Z0-9]{2,10}$`)
+	decimalRate  = regexp.MustCompile(`^\d+(\.\d+)?$`)
+)
+
+var (
+	errCurrencyMismatch = &refusal{"CURRENCY_MISMATCH", "Invalid transaction. Accounts hold different currencies, give the currency of receiver as toCurrency to convert"}
+	errNoExchangeRate   = &refusal{"NO_EXCHANGE_RATE", "Invalid transaction. There is no exchange rate between the currencies of the accounts"}
+	errConversionZero   = &refusal{"CONVERSION_TOO_SMALL", "Invalid transaction. The value converts to nothing in the currency of receiver"}
+)
+
+// defaultCurrency is the currency of accounts created without one, DEFAULT_CURRENCY or COIN
+func defaultCurrency() string {
+	return money.Default
+}
+
+// currencyOf is the currency of a, system accounts hold every currency so they have none
+func currencyOf(a *store.Account) string {
+	if a.Id == chBank || a.Id == chEscrow || a.Id == chFees || a.Id == chTax {
+		return ""
+	}
+	if a.Currency == "" {
+		return defaultCurrency()
+	}
+	return a.Currency
+}
+
+// convert sets the currencies of t paid by from to to and returns what to receives for value.
+// A conversion must be asked for with t.ToCurrency, it uses t.ToValue when already set (compensations)
+// and the latest exchange rate otherwise
+func convert(ctx context.Context, s store.Store, t *store.Transaction, from, to *store.Account, value int) (int, error) {
+	fromCur, toCur := currencyOf(from), currencyOf(to)
+	if fromCur == "" {
+		fromCur = toCur
+	} else if toCur == "" {
+		toCur = fromCur
+	}
+	t.Currency = fromCur
+	if fromCur == toCur {
+		t.ToCurrency, t.ToValue, t.Rate, t.RateId = "", 0, "", primitive.NilObjectID
+		return value, nil
+	}
+	if t.ToCurrency != toCur || t.Value < 0 {
+		return 0, errCurrencyMismatch
+	}
+	if t.ToValue > 0 {
+		return t.ToValue, nil
+	}
+	r, err := s.LatestExchangeRate(ctx, fromCur, toCur)
+	if errors.Is(err, store.ErrNotFound) {
+		return 0, errNoExchangeRate
+	}
+	if err != nil {
+		return 0, err
+	}
+	rate, ok := new(big.Rat).SetString(r.Rate)
+	if !ok {
+		return 0, fmt.Errorf("invalid exchange rate %v: %q", r.Id, r.Rate)
+	}
+	converted, err := money.New(int64(value), fromCur).Convert(rate, toCur)
+	if errors.Is(err, money.ErrOverflow) {
+		return 0, errAmountOverflow
+	}
+	if converted.Amount <= 0 {
+		return 0, errConversionZero
+	}
+	t.ToValue, t.Rate, t.RateId = int(converted.Amount), r.Rate, r.Id
+	return t.ToValue, nil
+}
+
+// checkCurrency upper cases the code and reports whether it is a valid currency code
+func checkCurrency(code *string) bool {
+	*code = strings.ToUpper(*code)
+	return currencyCode.MatchString(*code)
+}
+
+// CreateExchangeRate adds a rate, it replaces the previous one of the same currencies for new conversions
+func CreateExchangeRate(c *fiber.Ctx) error {
+	var r store.ExchangeRate
+	if err := c.BodyParser(&r); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	// Validation
+	if !checkCurrency(&r.From) || !checkCurrency(&r.To) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid currency code, expected 2 to 10 letters or digits"})
+	}
+	if r.From == r.To {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Currencies of an exchange rate must differ"})
+	}
+	if !decimalRate.MatchString(r.Rate) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid rate, expected a decimal like 1.25"})
+	}
+	if rate, _ := new(big.Rat).SetString(r.Rate); rate.Sign() <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Rate must be positive"})
+	}
+	r.Id = primitive.NewObjectID()
+	r.CreatedAt = time.Now()
+
+	// Actual logic here thou
+	if err := st.CreateExchangeRate(context.Background(), &r); err != nil {
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": "Exchange rate has been created", "data": r})
+}
+func GetAllExchangeRates(c *fiber.Ctx) error {
+	return GetAll(c, st.ListExchangeRates, store.ExchangeRateFilter{From: strings.ToUpper(c.Query("from")), To: strings.ToUpper(c.Query("to"))})
+}
+func GetExchangeRateByID(c *fiber.Ctx) error {
+	return GetByID(c, st.GetExchangeRate)
+}
@@ -0,0 +1,247 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/vovamod/BankAPI/money"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math/big"
+	"slices"
+)
+
+// System accounts collecting what fee rules charge
+const (
+	bankFees = "BANK_FEES"
+	bankTax  = "BANK_TAX"
+)
+
+// Kinds of fee rules, a fee goes to BANK_FEES and a tax to BANK_TAX
+const (
+	feeKind = "fee"
+	taxKind = "tax"
+)
+
+// feeTypes are the transaction types fees are charged on, giving money back or paying interest is free
+var feeTypes = []string{typeTransfer, typeEscrow}
+
+// Static fee accounts IDs
+var chFees, chTax primitive.ObjectID
+
+// FeeInit makes sure the fee and tax accounts exist
+func FeeInit(s store.Store) {
+	chFees = systemAccount(s, bankFees)
+	chTax = systemAccount(s, bankTax)
+}
+
+// feeAccount is the account collecting fees of kind
+func feeAccount(kind string) primitive.ObjectID {
+	if kind == taxKind {
+		return chTax
+	}
+	return chFees
+}
+
+// feeOf is what r charges on value, rounded by the rule of its currency
+func feeOf(r *store.FeeRule, value int) (int, error) {
+	percent, flat := r.Percent, r.Flat
+	for _, tier := range r.Tiers {
+		if value >= tier.From {
+			percent, flat = tier.Percent, tier.Flat
+		}
+	}
+	share, err := money.Round(new(big.Rat).Mul(big.NewRat(int64(value), basisPoints), big.NewRat(int64(percent), 1)), money.Lookup(r.Currency).Rounding)
+	if err != nil {
+		return 0, err
+	}
+	fee, err := money.Add(share, int64(flat))
+	if err != nil {
+		return 0, err
+	}
+	fee = max(fee, int64(r.Min))
+	if r.Max > 0 {
+		fee = min(fee, int64(r.Max))
+	}
+	return int(fee), nil
+}
+
+// chargeFees itemizes on t what from owes on top of value by the fee rules. System accounts pay none
+func chargeFees(ctx context.Context, s store.Store, t *store.Transaction, from *store.Account, value int) error {
+	t.Fees = nil
+	currency := currencyOf(from)
+	if currency == "" || !slices.Contains(feeTypes, t.Type) {
+		return nil
+	}
+	rules, err := s.FeeRulesFor(ctx, t.Type, currency)
+	if err != nil {
+		return err
+	}
+	for i := range rules {
+		fee, err := feeOf(&rules[i], value)
+		if err != nil {
+			return errAmountOverflow
+		}
+		if fee == 0 {
+			continue
+		}
+		account := bankFees
+		if rules[i].Kind == taxKind {
+			account = bankTax
+		}
+		t.Fees = append(t.Fees, store.Fee{Rule: rules[i].Name, Kind: rules[i].Kind, Value: fee, Account: account})
+	}
+	return nil
+}
+
+// feeTierBody is a tier as clients send it
+type feeTierBody struct {
+	From    money.Money
+	Percent int
+	Flat    money.Money
+}
+
+// feeRuleBody is a fee rule as clients send it, amounts are decimals of its currency
+type feeRuleBody struct {
+	store.FeeRule
+	Flat  money.Money
+	Min   money.Money
+	Max   money.Money
+	Tiers []feeTierBody
+}
+
+// rule checks the body and returns it as the rule to keep, the error being what to answer
+func (b *feeRuleBody) rule() (store.FeeRule, error) {
+	r := b.FeeRule
+	if r.Kind == "" {
+		r.Kind = feeKind
+	}
+	if r.Currency == "" {
+		r.Currency = defaultCurrency()
+	}
+	switch {
+	case r.Name == "":
+		return r, errors.New("Missing Name of the fee rule")
+	case r.Kind != feeKind && r.Kind != taxKind:
+		return r, errors.New("Kind of a fee rule must be fee or tax")
+	case r.Type != "" && !slices.Contains(feeTypes, r.Type):
+		return r, errors.New("Type of a fee rule must be empty, transfer or escrow")
+	case !checkCurrency(&r.Currency):
+		return r, errors.New("Invalid currency code, expected 2 to 10 letters or digits")
+	case r.Percent < 0 || r.Percent > basisPoints:
+		return r, errors.New("Percent of a fee rule must be between 0 and 10000 basis points")
+	}
+	var err error
+	if r.Flat, err = units(b.Flat, r.Currency); err != nil {
+		return r, errors.New("Invalid value, " + err.Error())
+	}
+	if r.Min, err = units(b.Min, r.Currency); err != nil {
+		return r, errors.New("Invalid value, " + err.Error())
+	}
+	if r.Max, err = units(b.Max, r.Currency); err != nil {
+		return r, errors.New("Invalid value, " + err.Error())
+	}
+	if r.Flat < 0 || r.Min < 0 || r.Max < 0 {
+		return r, errors.New("Flat, Min and Max of a fee rule cannot be negative")
+	}
+	if r.Max > 0 && r.Max < r.Min {
+		return r, errors.New("Max of a fee rule cannot be below its Min")
+	}
+	r.Tiers = nil
+	for i, tier := range b.Tiers {
+		t := store.FeeTier{Percent: tier.Percent}
+		if t.From, err = units(tier.From, r.Currency); err != nil {
+			return r, errors.New("Invalid value, " + err.Error())
+		}
+		if t.Flat, err = units(tier.Flat, r.Currency); err != nil {
+			return r, errors.New("Invalid value, " + err.Error())
+		}
+		if t.From <= 0 || (i > 0 && t.From <= r.Tiers[i-1].From) {
+			return r, errors.New("Tiers of a fee rule must start from positive values in ascending order")
+		}
+		if t.Percent < 0 || t.Percent > basisPoints || t.Flat < 0 {
+			return r, errors.New("Tiers of a fee rule cannot be negative or above 10000 basis points")
+		}
+		r.Tiers = append(r.Tiers, t)
+	}
+	return r, nil
+}
+
+// CreateFeeRule adds a fee or a tax charged on every new transaction it matches
+func CreateFeeRule(c *fiber.Ctx) error {
+	var body feeRuleBody
+	if err := c.BodyParser(&body); err != nil {
+		if badValue(err) {
+			return invalidValue(c, err)
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	r, err := body.rule()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	r.Id = primitive.NewObjectID()
+	err = st.CreateFeeRule(context.Background(), &r)
+	if errors.Is(err, store.ErrDuplicate) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid fee rule name provided. This name is already taken"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": "Fee rule has been created", "data": r})
+}
+func GetAllFeeRules(c *fiber.Ctx) error {
+	return GetAll(c, func(ctx context.Context, _ struct{}, p store.Page) (*store.Result[store.FeeRule], error) {
+		return st.ListFeeRules(ctx, p)
+	}, struct{}{})
+}
+func GetFeeRuleByID(c *fiber.Ctx) error {
+	return GetByID(c, st.GetFeeRule)
+}
+
+// UpdateFeeRuleByID replaces a rule, transactions already made keep the fees they were charged
+func UpdateFeeRuleByID(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	if _, err := st.GetFeeRule(context.Background(), id); errors.Is(err, store.ErrNotFound) {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	} else if err != nil {
+		return dbError(c, err)
+	}
+	var body feeRuleBody
+	if err := c.BodyParser(&body); err != nil {
+		if badValue(err) {
+			return invalidValue(c, err)
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	r, err := body.rule()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	r.Id = id
+	err = st.UpdateFeeRule(context.Background(), &r)
+	if errors.Is(err, store.ErrDuplicate) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid fee rule name provided. This name is already taken"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Fee rule updated successfully", "data": r})
+}
+func DeleteFeeRuleByID(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	err = st.DeleteFeeRule(context.Background(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Fee rule deleted successfully"})
+}
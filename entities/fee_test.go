@@ -0,0 +1,80 @@
+package entities
+
+import (
+	"github.com/vovamod/BankAPI/money"
+	"github.com/vovamod/BankAPI/store"
+	"testing"
+)
+
+func TestFeeOf(t *testing.T) {
+	if err := money.Configure("COIN:2,GEM:0:up", "COIN"); err != nil {
+		t.Fatal(err)
+	}
+	defer money.Configure("", "COIN")
+
+	// 1% up to 100.00, 0.5% and 1.00 flat from there, 0.1% from 1000.00
+	tiered := store.FeeRule{Currency: "COIN", Percent: 100, Tiers: []store.FeeTier{
+		{From: 10000, Percent: 50, Flat: 100},
+		{From: 100000, Percent: 10},
+	}}
+	tests := []struct {
+		name  string
+		rule  store.FeeRule
+		value int
+		want  int
+	}{
+		{"percent", store.FeeRule{Currency: "COIN", Percent: 250}, 10000, 250},
+		{"rounded down", store.FeeRule{Currency: "COIN", Percent: 100}, 199, 1},
+		{"rounded up by its currency", store.FeeRule{Currency: "GEM", Percent: 100}, 101, 2},
+		{"flat", store.FeeRule{Currency: "COIN", Percent: 100, Flat: 25}, 1000, 35},
+		{"min", store.FeeRule{Currency: "COIN", Percent: 100, Min: 50}, 1000, 50},
+		{"max", store.FeeRule{Currency: "COIN", Percent: 100, Max: 500}, 100000, 500},
+		{"max 0 is none", store.FeeRule{Currency: "COIN", Percent: 100}, 100000, 1000},
+		{"free", store.FeeRule{Currency: "COIN"}, 100000, 0},
+		{"below the tiers", tiered, 9999, 99},
+		{"first tier from its start", tiered, 10000, 150},
+		{"first tier", tiered, 50000, 350},
+		{"last tier", tiered, 100000, 100},
+		{"above the last tier", tiered, 500000, 500},
+	}
+	for _, tt := range tests {
+		got, err := feeOf(&tt.rule, tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: fee of %d = %d, %v, want %d", tt.name, tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestFeeRuleTiers(t *testing.T) {
+	tier := func(from string, percent int) feeTierBody {
+		m, err := money.Parse(from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return feeTierBody{From: m, Percent: percent}
+	}
+	tests := []struct {
+		name  string
+		tiers []feeTierBody
+		valid bool
+	}{
+		{"none", nil, true},
+		{"ascending", []feeTierBody{tier("10", 50), tier("100", 10)}, true},
+		{"same start", []feeTierBody{tier("10", 50), tier("10", 10)}, false},
+		{"descending", []feeTierBody{tier("100", 50), tier("10", 10)}, false},
+		{"from zero", []feeTierBody{tier("0", 50)}, false},
+		{"above 100%", []feeTierBody{tier("10", basisPoints+1)}, false},
+		{"negative", []feeTierBody{tier("10", -1)}, false},
+		{"too many decimals", []feeTierBody{tier("10.001", 50)}, false},
+	}
+	for _, tt := range tests {
+		b := feeRuleBody{FeeRule: store.FeeRule{Name: "fee", Currency: "COIN", Percent: 100}, Tiers: tt.tiers}
+		r, err := b.rule()
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: error = %v, want valid %v", tt.name, err, tt.valid)
+		}
+		if err == nil && len(r.Tiers) != len(tt.tiers) {
+			t.Errorf("%s: %d tiers kept, want %d", tt.name, len(r.Tiers), len(tt.tiers))
+		}
+	}
+}
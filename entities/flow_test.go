@@ -0,0 +1,337 @@
+package entities
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/vovamod/BankAPI/money"
+	"github.com/vovamod/BankAPI/store"
+	"github.com/vovamod/BankAPI/store/memstore"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testBank serves the handlers on a memstore, every request made by the bank itself
+type testBank struct {
+	t   *testing.T
+	app *fiber.App
+}
+
+func newTestBank(t *testing.T) *testBank {
+	t.Helper()
+	if err := money.Configure("", "COIN"); err != nil {
+		t.Fatal(err)
+	}
+	Init(memstore.New())
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("role", bankRole)
+		c.Locals("permissions", PermissionsOf(bankRole))
+		return c.Next()
+	})
+	app.Post("/accounts", CreateAccount)
+	app.Post("/users/:account", CreateUser)
+	app.Put("/users/:id", UpdateUserByID)
+	app.Get("/accounts/:id/transactions", GetAccountTransactions)
+	app.Post("/transactions", CreateTransaction)
+	app.Post("/transactions/:id/reverse", ReverseTransaction)
+	app.Post("/transactions/:id/refund", RefundTransaction)
+	app.Post("/holds", CreateHold)
+	app.Post("/holds/:id/capture", CaptureHold)
+	app.Post("/holds/:id/release", ReleaseHold)
+	app.Post("/escrows", CreateEscrow)
+	app.Post("/escrows/:id/release", ReleaseEscrow)
+	app.Post("/escrows/:id/refund", RefundEscrow)
+	app.Post("/escrows/:id/split", SplitEscrow)
+	return &testBank{t: t, app: app}
+}
+
+// answer is what the handlers answer, the id of the record given as data or the error
+type answer struct {
+	Data  struct{ Id string }
+	Error string
+}
+
+// post sends body as JSON and returns the status and the answer
+func (b *testBank) post(path string, body fiber.Map) (int, answer) {
+	b.t.Helper()
+	return b.send(fiber.MethodPost, path, body)
+}
+
+// send is post with any method
+func (b *testBank) send(method, path string, body fiber.Map) (int, answer) {
+	b.t.Helper()
+	raw, err := json.Marshal(body)
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(raw))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	res, err := b.app.Test(req, -1)
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	var a answer
+	_ = json.NewDecoder(res.Body).Decode(&a)
+	return res.StatusCode, a
+}
+
+// must posts and fails the test unless it is answered with want
+func (b *testBank) must(want int, path string, body fiber.Map) string {
+	b.t.Helper()
+	status, a := b.post(path, body)
+	if status != want {
+		b.t.Fatalf("POST %s %v = %d %q, want %d", path, body, status, a.Error, want)
+	}
+	return a.Data.Id
+}
+
+// open creates an account funded with value
+func (b *testBank) open(name, value string) {
+	b.t.Helper()
+	b.must(fiber.StatusCreated, "/accounts", fiber.Map{"name": name, "value": value})
+}
+
+// balances checks the value and held of the accounts in minor units, and that every one of them matches its ledger
+func (b *testBank) balances(want map[string][2]int) {
+	b.t.Helper()
+	ctx := context.Background()
+	for name, w := range want {
+		a, err := st.GetAccountByName(ctx, name)
+		if err != nil {
+			b.t.Fatalf("account %s: %v", name, err)
+		}
+		if a.Value != w[0] || a.Held != w[1] {
+			b.t.Errorf("%s has %d, %d held, want %d, %d held", name, a.Value, a.Held, w[0], w[1])
+		}
+		sum, _, err := st.SumPostings(ctx, a.Id)
+		if err != nil || sum != a.Value {
+			b.t.Errorf("%s has %d but its postings sum to %d, %v", name, a.Value, sum, err)
+		}
+	}
+}
+
+func TestTransferFlow(t *testing.T) {
+	b := newTestBank(t)
+	b.open("alice", "100")
+	b.open("bob", "0")
+
+	b.must(fiber.StatusCreated, "/transactions", fiber.Map{"nameTZ": "Trade", "byWho": "alice", "toWho": "bob", "value": "25.50"})
+	b.balances(map[string][2]int{"alice": {7450, 0}, "bob": {2550, 0}})
+
+	tests := []struct {
+		name string
+		body fiber.Map
+		want int
+	}{
+		{"above the balance", fiber.Map{"nameTZ": "Trade", "byWho": "alice", "toWho": "bob", "value": "74.51"}, fiber.StatusBadRequest},
+		{"unknown receiver", fiber.Map{"nameTZ": "Trade", "byWho": "alice", "toWho": "carol", "value": "1"}, fiber.StatusBadRequest},
+		{"to itself", fiber.Map{"nameTZ": "Trade", "byWho": "alice", "toWho": "alice", "value": "1"}, fiber.StatusBadRequest},
+		{"charging itself", fiber.Map{"nameTZ": "Trade", "byWho": "alice", "toWho": "alice", "value": "-1"}, fiber.StatusBadRequest},
+		{"too many decimals", fiber.Map{"nameTZ": "Trade", "byWho": "alice", "toWho": "bob", "value": "0.001"}, fiber.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if status, _ := b.post("/transactions", tt.body); status != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, status, tt.want)
+		}
+	}
+	// Refused transfers move nothing
+	b.balances(map[string][2]int{"alice": {7450, 0}, "bob": {2550, 0}})
+
+	b.must(fiber.StatusCreated, "/transactions", fiber.Map{"nameTZ": "Trade", "byWho": "alice", "toWho": "bob", "value": "74.50"})
+	b.balances(map[string][2]int{"alice": {0, 0}, "bob": {10000, 0}})
+}
+
+func TestRefundFlow(t *testing.T) {
+	b := newTestBank(t)
+	b.open("alice", "100")
+	b.open("bob", "0")
+	id := b.must(fiber.StatusCreated, "/transactions", fiber.Map{"nameTZ": "Trade", "byWho": "alice", "toWho": "bob", "value": "40"})
+
+	b.must(fiber.StatusCreated, "/transactions/"+id+"/refund", fiber.Map{"value": "15"})
+	b.balances(map[string][2]int{"alice": {7500, 0}, "bob": {2500, 0}})
+	if original, _ := st.GetTransaction(context.Background(), mustID(t, id)); original.Status != statusPartiallyRefunded || original.Refunded != 1500 {
+		t.Errorf("original is %s with %d refunded, want %s with 1500", original.Status, original.Refunded, statusPartiallyRefunded)
+	}
+
+	// Only what is left may be refunded
+	b.must(fiber.StatusBadRequest, "/transactions/"+id+"/refund", fiber.Map{"value": "25.01"})
+	b.must(fiber.StatusCreated, "/transactions/"+id+"/reverse", fiber.Map{})
+	b.balances(map[string][2]int{"alice": {10000, 0}, "bob": {0, 0}})
+	if original, _ := st.GetTransaction(context.Background(), mustID(t, id)); original.Status != statusReversed || original.Refunded != 4000 {
+		t.Errorf("original is %s with %d refunded, want %s with 4000", original.Status, original.Refunded, statusReversed)
+	}
+	b.must(fiber.StatusBadRequest, "/transactions/"+id+"/refund", fiber.Map{"value": "1"})
+	b.must(fiber.StatusBadRequest, "/transactions/"+id+"/reverse", fiber.Map{})
+
+	// A refund is paid by the receiver like any transfer, it cannot overdraw
+	id = b.must(fiber.StatusCreated, "/transactions", fiber.Map{"nameTZ": "Trade", "byWho": "alice", "toWho": "bob", "value": "10"})
+	b.must(fiber.StatusCreated, "/transactions", fiber.Map{"nameTZ": "Trade", "byWho": "bob", "toWho": "alice", "value": "10"})
+	b.must(fiber.StatusBadRequest, "/transactions/"+id+"/reverse", fiber.Map{})
+	b.balances(map[string][2]int{"alice": {10000, 0}, "bob": {0, 0}})
+}
+
+func TestHoldFlow(t *testing.T) {
+	b := newTestBank(t)
+	b.open("alice", "100")
+	b.open("bob", "0")
+
+	id := b.must(fiber.StatusCreated, "/holds", fiber.Map{"account": "alice", "value": "30"})
+	b.balances(map[string][2]int{"alice": {10000, 3000}})
+	// What is held cannot be spent
+	b.must(fiber.StatusBadRequest, "/transactions", fiber.Map{"nameTZ": "Trade", "byWho": "alice", "toWho": "bob", "value": "70.01"})
+	b.must(fiber.StatusBadRequest, "/holds/"+id+"/capture", fiber.Map{"toWho": "bob", "value": "30.01"})
+
+	// Capturing part of it gives the rest back
+	b.must(fiber.StatusCreated, "/holds/"+id+"/capture", fiber.Map{"toWho": "bob", "value": "20"})
+	b.balances(map[string][2]int{"alice": {8000, 0}, "bob": {2000, 0}})
+	b.must(fiber.StatusBadRequest, "/holds/"+id+"/capture", fiber.Map{"toWho": "bob"})
+	b.must(fiber.StatusBadRequest, "/holds/"+id+"/release", fiber.Map{})
+
+	id = b.must(fiber.StatusCreated, "/holds", fiber.Map{"account": "alice", "value": "80"})
+	b.must(fiber.StatusBadRequest, "/holds", fiber.Map{"account": "alice", "value": "0.01"})
+	b.must(fiber.StatusOK, "/holds/"+id+"/release", fiber.Map{})
+	b.balances(map[string][2]int{"alice": {8000, 0}, "bob": {2000, 0}})
+}
+
+func TestChargeCurrency(t *testing.T) {
+	b := newTestBank(t)
+	if err := money.Configure("COIN:2,GEM:0", "COIN"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { money.Configure("", "COIN") })
+	b.open("shop", "100")
+	b.must(fiber.StatusCreated, "/accounts", fiber.Map{"name": "miner", "value": "10", "currency": "GEM"})
+
+	// A charge is read in the currency of the account charged, the one paying it
+	b.must(fiber.StatusCreated, "/transactions", fiber.Map{"nameTZ": "Tax", "byWho": bankIssuer, "toWho": "miner", "value": "-3"})
+	b.balances(map[string][2]int{"miner": {7, 0}, "shop": {10000, 0}})
+	tests := []struct {
+		name, byWho, toWho, value, error string
+	}{
+		{"decimals of the payer", "shop", "miner", "-1.5", "Invalid value"},
+		{"read, then refused between currencies", "shop", "miner", "-1", "Invalid transaction"},
+		{"decimals of the payer the other way", "miner", "shop", "-0.5", "Invalid transaction"},
+	}
+	for _, tt := range tests {
+		status, a := b.post("/transactions", fiber.Map{"nameTZ": "Tax", "byWho": tt.byWho, "toWho": tt.toWho, "value": tt.value})
+		if status != fiber.StatusBadRequest || !strings.HasPrefix(a.Error, tt.error) {
+			t.Errorf("%s: %d %q, want 400 %s", tt.name, status, a.Error, tt.error)
+		}
+	}
+	b.balances(map[string][2]int{"miner": {7, 0}, "shop": {10000, 0}})
+}
+
+func TestHoldCaptureChecked(t *testing.T) {
+	b := newTestBank(t)
+	b.must(fiber.StatusCreated, "/accounts", fiber.Map{"name": "alice", "value": "100", "limits": fiber.Map{"daily": "50"}})
+	b.open("bob", "0")
+	b.open("carol", "100")
+
+	// Holding is not spending, capturing is
+	id := b.must(fiber.StatusCreated, "/holds", fiber.Map{"account": "alice", "value": "60"})
+	b.must(fiber.StatusBadRequest, "/holds/"+id+"/capture", fiber.Map{"toWho": "bob"})
+	b.balances(map[string][2]int{"alice": {10000, 6000}, "bob": {0, 0}})
+	b.must(fiber.StatusCreated, "/holds/"+id+"/capture", fiber.Map{"toWho": "bob", "value": "50"})
+	b.balances(map[string][2]int{"alice": {5000, 0}, "bob": {5000, 0}})
+
+	// The fee comes on top of the capture and cannot overdraw
+	if err := st.CreateFeeRule(context.Background(), &store.FeeRule{Id: primitive.NewObjectID(), Name: "fee", Kind: feeKind, Currency: "COIN", Percent: 100}); err != nil {
+		t.Fatal(err)
+	}
+	id = b.must(fiber.StatusCreated, "/holds", fiber.Map{"account": "carol", "value": "100"})
+	b.must(fiber.StatusBadRequest, "/holds/"+id+"/capture", fiber.Map{"toWho": "bob"})
+	b.must(fiber.StatusCreated, "/holds/"+id+"/capture", fiber.Map{"toWho": "bob", "value": "99"})
+	b.balances(map[string][2]int{"carol": {1, 0}, "bob": {14900, 0}, bankFees: {99, 0}})
+}
+
+func TestEscrowFlow(t *testing.T) {
+	b := newTestBank(t)
+	b.open("alice", "100")
+	b.open("bob", "0")
+
+	id := b.must(fiber.StatusCreated, "/escrows", fiber.Map{"buyer": "alice", "seller": "bob", "value": "50"})
+	b.balances(map[string][2]int{"alice": {5000, 0}, "bob": {0, 0}, bankEscrow: {5000, 0}})
+	b.must(fiber.StatusOK, "/escrows/"+id+"/release", fiber.Map{})
+	b.balances(map[string][2]int{"alice": {5000, 0}, "bob": {5000, 0}, bankEscrow: {0, 0}})
+	b.must(fiber.StatusBadRequest, "/escrows/"+id+"/refund", fiber.Map{})
+
+	id = b.must(fiber.StatusCreated, "/escrows", fiber.Map{"buyer": "alice", "seller": "bob", "value": "20"})
+	b.must(fiber.StatusOK, "/escrows/"+id+"/refund", fiber.Map{})
+	b.balances(map[string][2]int{"alice": {5000, 0}, "bob": {5000, 0}, bankEscrow: {0, 0}})
+
+	id = b.must(fiber.StatusCreated, "/escrows", fiber.Map{"buyer": "alice", "seller": "bob", "value": "30"})
+	b.must(fiber.StatusBadRequest, "/escrows/"+id+"/split", fiber.Map{"toSeller": "30.01"})
+	b.must(fiber.StatusOK, "/escrows/"+id+"/split", fiber.Map{"toSeller": "10"})
+	b.balances(map[string][2]int{"alice": {4000, 0}, "bob": {6000, 0}, bankEscrow: {0, 0}})
+
+	b.must(fiber.StatusBadRequest, "/escrows", fiber.Map{"buyer": "alice", "seller": "bob", "value": "40.01"})
+	b.must(fiber.StatusBadRequest, "/escrows", fiber.Map{"buyer": "alice", "seller": "alice", "value": "1"})
+	b.balances(map[string][2]int{"alice": {4000, 0}, "bob": {6000, 0}, bankEscrow: {0, 0}})
+}
+
+func TestLinkAccounts(t *testing.T) {
+	b := newTestBank(t)
+	ctx := context.Background()
+	ids := map[string]string{}
+	for _, name := range []string{"alice", "alice2", "bob", "gone"} {
+		b.open(name, "0")
+		a, err := st.GetAccountByName(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = a.Id.Hex()
+	}
+	for _, name := range []string{"alice", "bob"} {
+		b.must(fiber.StatusCreated, "/users/"+ids[name], fiber.Map{"name": name, "objectId": name})
+	}
+	b.must(fiber.StatusBadRequest, "/users/"+ids["bob"], fiber.Map{"name": "mallory", "objectId": "mallory"})
+	alice, err := st.GetUserByName(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gone, err := st.GetAccountByName(ctx, "gone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gone.DeletedAt = time.Now()
+	if err := st.UpdateAccount(ctx, gone); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		accounts []string
+		want     int
+	}{
+		{"linked to another user", []string{ids["bob"]}, fiber.StatusConflict},
+		{"unknown", []string{primitive.NewObjectID().Hex()}, fiber.StatusBadRequest},
+		{"deleted", []string{ids["gone"]}, fiber.StatusBadRequest},
+		{"not an id", []string{"bob"}, fiber.StatusBadRequest},
+		{"one of them linked to another user", []string{ids["alice2"], ids["bob"]}, fiber.StatusConflict},
+		{"free and already linked", []string{ids["alice2"], ids["alice"], ids["alice2"]}, fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		if status, a := b.send(fiber.MethodPut, "/users/"+alice.Id.Hex(), fiber.Map{"account": tt.accounts}); status != tt.want {
+			t.Errorf("%s: status %d %q, want %d", tt.name, status, a.Error, tt.want)
+		}
+	}
+	alice, err = st.GetUserByName(ctx, "alice")
+	if err != nil || !slices.Equal(alice.Account, []string{ids["alice"], ids["alice2"]}) {
+		t.Errorf("alice has %v, %v, want alice and alice2", alice.Account, err)
+	}
+}
+
+func mustID(t *testing.T, hex string) primitive.ObjectID {
+	t.Helper()
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
@@ -0,0 +1,290 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/vovamod/BankAPI/money"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"time"
+)
+
+// Statuses of transfers held by a fraud rule, they move nothing until approved
+const (
+	statusPendingReview = "PendingReview"
+	statusRejected      = "Rejected"
+)
+
+// What a fraud rule does with the transfers it matches
+const (
+	fraudBlock  = "block"
+	fraudReview = "review"
+)
+
+var errNotPending = errors.New("Invalid transaction. Only transactions pending review can be approved or rejected")
+
+// fraudCheck reports whether the transfer of value from from to to matches r, since being the start of its window
+type fraudCheck func(ctx context.Context, s store.Store, r *store.FraudRule, from, to *store.Account, value int, since time.Time) (bool, error)
+
+// fraudChecks are the checks fraud rules pick by name, a new check only has to be added here
+var fraudChecks = map[string]fraudCheck{
+	"newAccount":     newAccountCheck,
+	"roundTrip":      roundTripCheck,
+	"smallTransfers": smallTransfersCheck,
+}
+
+// newAccountCheck matches a transfer of at least Value to an account created within the window
+func newAccountCheck(_ context.Context, _ store.Store, r *store.FraudRule, _, to *store.Account, value int, since time.Time) (bool, error) {
+	return value >= r.Value && to.Id.Timestamp().After(since), nil
+}
+
+// roundTripCheck matches a transfer of at least Value to an account that paid the sender Count times (once when 0)
+// within the window
+func roundTripCheck(ctx context.Context, s store.Store, r *store.FraudRule, from, to *store.Account, value int, since time.Time) (bool, error) {
+	if value < r.Value {
+		return false, nil
+	}
+	count := max(r.Count, 1)
+	back, err := s.ListTransactions(ctx, store.TransactionFilter{ByWho: to.Name, ToWho: from.Name, StatusNot: unsettledStatuses, From: since},
+		store.Page{Limit: min(count, store.MaxLimit)})
+	if err != nil {
+		return false, err
+	}
+	return len(back.Data) >= count, nil
+}
+
+// smallTransfersCheck matches the Count-th transfer of at most Value from the sender to the same receiver within the window
+func smallTransfersCheck(ctx context.Context, s store.Store, r *store.FraudRule, from, to *store.Account, value int, since time.Time) (bool, error) {
+	if value > r.Value {
+		return false, nil
+	}
+	before, err := s.ListTransactions(ctx, store.TransactionFilter{ByWho: from.Name, ToWho: to.Name, StatusNot: unsettledStatuses, From: since},
+		store.Page{Limit: store.MaxLimit})
+	if err != nil {
+		return false, err
+	}
+	small := 1
+	for _, t := range before.Data {
+		if t.Value > 0 && t.Value <= r.Value {
+			small++
+		}
+	}
+	return small >= r.Count, nil
+}
+
+// checkFraud runs the fraud rules of the currency of from on a transfer. A blocking rule refuses it, the others
+// are kept in t.Flags and the transfer goes to review when there is any
+func checkFraud(ctx context.Context, s store.Store, t *store.Transaction, from, to *store.Account, value int) (bool, error) {
+	t.Flags = nil
+	currency := currencyOf(from)
+	if t.Type != typeTransfer || currency == "" || currencyOf(to) == "" {
+		return false, nil
+	}
+	rules, err := s.FraudRulesFor(ctx, currency)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	for i := range rules {
+		r := &rules[i]
+		check, ok := fraudChecks[r.Check]
+		if !ok {
+			log.Warnf("Fraud rule %s has an unknown check %q", r.Name, r.Check)
+			continue
+		}
+		window, _ := time.ParseDuration(r.Window)
+		matched, err := check(ctx, s, r, from, to, value, now.Add(-window))
+		if err != nil {
+			return false, err
+		}
+		if !matched {
+			continue
+		}
+		t.Flags = append(t.Flags, r.Name)
+		if r.Action == fraudBlock {
+			return false, &refusal{"FRAUD_BLOCKED", "Invalid transaction. It has been blocked by the fraud rule " + r.Name}
+		}
+	}
+	return len(t.Flags) > 0, nil
+}
+
+// fraudRuleBody is a fraud rule as clients send it, the value a decimal of its currency
+type fraudRuleBody struct {
+	store.FraudRule
+	Value money.Money
+}
+
+// rule checks the body and returns it as the rule to keep, the error being what to answer
+func (b *fraudRuleBody) rule() (store.FraudRule, error) {
+	r := b.FraudRule
+	if r.Action == "" {
+		r.Action = fraudReview
+	}
+	if r.Currency == "" {
+		r.Currency = defaultCurrency()
+	}
+	_, known := fraudChecks[r.Check]
+	window, err := time.ParseDuration(r.Window)
+	switch {
+	case r.Name == "" || strings.Contains(r.Name, ","):
+		return r, errors.New("Missing or invalid Name of the fraud rule, it cannot contain a comma")
+	case !known:
+		return r, errors.New("Check of a fraud rule must be newAccount, roundTrip or smallTransfers")
+	case r.Action != fraudBlock && r.Action != fraudReview:
+		return r, errors.New("Action of a fraud rule must be block or review")
+	case !checkCurrency(&r.Currency):
+		return r, errors.New("Invalid currency code, expected 2 to 10 letters or digits")
+	case err != nil || window <= 0:
+		return r, errors.New("Window of a fraud rule must be a positive duration like 24h")
+	case r.Count < 0:
+		return r, errors.New("Count of a fraud rule cannot be negative")
+	case r.Check == "smallTransfers" && r.Count < 2:
+		return r, errors.New("Count of a smallTransfers rule must be at least 2")
+	}
+	if r.Value, err = units(b.Value, r.Currency); err != nil {
+		return r, errors.New("Invalid value, " + err.Error())
+	}
+	if r.Value < 0 || (r.Check == "smallTransfers" && r.Value == 0) {
+		return r, errors.New("Value of a fraud rule cannot be negative, nor 0 for smallTransfers")
+	}
+	return r, nil
+}
+
+// CreateFraudRule adds a rule run on every new transfer
+func CreateFraudRule(c *fiber.Ctx) error {
+	var body fraudRuleBody
+	if err := c.BodyParser(&body); err != nil {
+		if badValue(err) {
+			return invalidValue(c, err)
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	r, err := body.rule()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	r.Id = primitive.NewObjectID()
+	err = st.CreateFraudRule(context.Background(), &r)
+	if errors.Is(err, store.ErrDuplicate) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid fraud rule name provided. This name is already taken"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": "Fraud rule has been created", "data": r})
+}
+func GetAllFraudRules(c *fiber.Ctx) error {
+	return GetAll(c, func(ctx context.Context, _ struct{}, p store.Page) (*store.Result[store.FraudRule], error) {
+		return st.ListFraudRules(ctx, p)
+	}, struct{}{})
+}
+func GetFraudRuleByID(c *fiber.Ctx) error {
+	return GetByID(c, st.GetFraudRule)
+}
+
+// UpdateFraudRuleByID replaces a rule, transfers already in review stay there
+func UpdateFraudRuleByID(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	var body fraudRuleBody
+	if err := c.BodyParser(&body); err != nil {
+		if badValue(err) {
+			return invalidValue(c, err)
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	r, err := body.rule()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	r.Id = id
+	err = st.UpdateFraudRule(context.Background(), &r)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	case errors.Is(err, store.ErrDuplicate):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid fraud rule name provided. This name is already taken"})
+	case err != nil:
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Fraud rule updated successfully", "data": r})
+}
+func DeleteFraudRuleByID(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	err = st.DeleteFraudRule(context.Background(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Fraud rule deleted successfully"})
+}
+
+// GetReviewQueue lists the transfers pending review, oldest first unless asked otherwise
+func GetReviewQueue(c *fiber.Ctx) error {
+	return GetAll(c, st.ListTransactions, store.TransactionFilter{Status: statusPendingReview})
+}
+
+// ApproveTransaction settles a transfer pending review as it would have been without the fraud rules, with the
+// authority of a player. Funds are checked again, a refused approval leaves it pending so it can still be rejected
+func ApproveTransaction(c *fiber.Ctx) error {
+	return review(c, true)
+}
+
+// RejectTransaction closes a transfer pending review without moving anything
+func RejectTransaction(c *fiber.Ctx) error {
+	return review(c, false)
+}
+
+func review(c *fiber.Ctx, approve bool) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	var t *store.Transaction
+	err = st.WithTx(context.Background(), func(ctx context.Context, s store.Store) error {
+		var err error
+		if t, err = s.GetTransaction(ctx, id); err != nil {
+			return err
+		}
+		if t.Status != statusPendingReview {
+			return errNotPending
+		}
+		if !approve {
+			t.Status = statusRejected
+			return s.UpdateTransaction(ctx, t)
+		}
+		from, to, value, err := parties(ctx, s, t)
+		if err != nil {
+			return err
+		}
+		// The ledger sees it when it is settled. Approving only clears the fraud rules, it settles within the
+		// overdraft policy of the payer and not the one of the approver
+		t.Date = time.Now()
+		return settle(ctx, s, t, from, to, value, asUser, s.UpdateTransaction)
+	})
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	case errors.Is(err, errNotPending):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case refused(err):
+		return refusedResponse(c, err, "")
+	case err != nil:
+		log.Errorf("Failed to review transaction %v: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Transaction failed and has been rolled back"})
+	}
+	if !approve {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Transaction has been rejected", "data": t})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Transaction has been approved", "data": t})
+}
@@ -0,0 +1,104 @@
+package entities
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/vovamod/BankAPI/money"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// unsettledStatuses are the statuses of transactions that never moved any value
+var unsettledStatuses = []string{statusFail, statusPendingReview, statusRejected}
+
+// historyEntry is a transaction seen from one account: Amount is what it did to the balance, Balance what was left after
+type historyEntry struct {
+	Transaction store.Transaction `json:"transaction"`
+	Amount      money.Money       `json:"amount"`
+	Balance     money.Money       `json:"balance"`
+}
+
+// historyCursor is where the next page starts, the last entry answered and the balance it left.
+// A posting dated before it, like interest settled at the end of a past period, shows on the next walk from the start
+type historyCursor struct {
+	Date    time.Time          `json:"d"`
+	Id      primitive.ObjectID `json:"i"`
+	Balance int                `json:"b"`
+}
+
+// GetAccountTransactions answers with the transactions of the account from the oldest one, each with the balance it left.
+// Amounts and balances come from the postings of the account, the ledger being what the balance is made of
+func GetAccountTransactions(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
+	}
+	ctx := context.Background()
+	a, err := st.GetAccount(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+
+	var from historyCursor
+	if cursor := c.Query("cursor"); cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil || json.Unmarshal(raw, &from) != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid sort or cursor provided"})
+		}
+	}
+	limit := c.QueryInt("limit", store.DefaultLimit)
+	if limit <= 0 {
+		limit = store.DefaultLimit
+	}
+	limit = min(limit, store.MaxLimit)
+	// One more than answered tells whether there is a next page
+	ledger, err := st.ListLedger(ctx, a.Id, store.LedgerEntry{TransactionId: from.Id, Date: from.Date}, limit+1)
+	if err != nil {
+		return dbError(c, err)
+	}
+	more := len(ledger) > limit
+	ledger = ledger[:min(limit, len(ledger))]
+
+	transactions := map[primitive.ObjectID]store.Transaction{}
+	if len(ledger) > 0 {
+		ids := make([]primitive.ObjectID, len(ledger))
+		for i, e := range ledger {
+			ids[i] = e.TransactionId
+		}
+		found, err := st.ListTransactions(ctx, store.TransactionFilter{Ids: ids}, store.Page{Limit: len(ids)})
+		if err != nil {
+			return dbError(c, err)
+		}
+		for _, t := range found.Data {
+			transactions[t.Id] = t
+		}
+	}
+	balance := from.Balance
+	entries := make([]historyEntry, 0, len(ledger))
+	for _, e := range ledger {
+		t, ok := transactions[e.TransactionId]
+		if !ok {
+			// A posting left without its transaction still shows what moved
+			t = store.Transaction{Id: e.TransactionId, Date: e.Date}
+		}
+		balance += e.Amount
+		entries = append(entries, historyEntry{Transaction: t, Amount: decimal(e.Amount, a.Currency), Balance: decimal(balance, a.Currency)})
+	}
+	response := fiber.Map{"account": a.Name, "data": entries}
+	if more {
+		last := ledger[len(ledger)-1]
+		raw, err := json.Marshal(historyCursor{Date: last.Date, Id: last.TransactionId, Balance: balance})
+		if err != nil {
+			return dbError(c, err)
+		}
+		response["next"] = base64.RawURLEncoding.EncodeToString(raw)
+	}
+	return c.Status(fiber.StatusOK).JSON(response)
+}
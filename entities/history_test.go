@@ -0,0 +1,122 @@
+package entities
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// page answers one page of the history of the account named name with its amounts and balances
+func (b *testBank) page(name, limit, cursor string) (amounts, balances []string, next string) {
+	b.t.Helper()
+	a, err := st.GetAccountByName(context.Background(), name)
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	res, err := b.app.Test(httptest.NewRequest(fiber.MethodGet, "/accounts/"+a.Id.Hex()+"/transactions?limit="+limit+"&cursor="+cursor, nil), -1)
+	if err != nil || res.StatusCode != fiber.StatusOK {
+		b.t.Fatalf("history of %s: %v, %v", name, res.StatusCode, err)
+	}
+	var page struct {
+		Data []struct{ Amount, Balance string }
+		Next string
+	}
+	if err := json.NewDecoder(res.Body).Decode(&page); err != nil {
+		b.t.Fatal(err)
+	}
+	for _, e := range page.Data {
+		amounts, balances = append(amounts, e.Amount), append(balances, e.Balance)
+	}
+	return amounts, balances, page.Next
+}
+
+// history walks the history of the account named name page by page and returns its amounts and balances
+func (b *testBank) history(name string, limit string) (amounts, balances []string) {
+	b.t.Helper()
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			b.t.Fatalf("history of %s does not end", name)
+		}
+		a, bs, next := b.page(name, limit, cursor)
+		amounts, balances = append(amounts, a...), append(balances, bs...)
+		if next == "" {
+			return amounts, balances
+		}
+		cursor = next
+	}
+}
+
+func TestHistory(t *testing.T) {
+	b := newTestBank(t)
+	b.open("alice", "100")
+	b.open("bob", "0")
+	b.open("carol", "0")
+	// 1% of every transfer, rounded down
+	if err := st.CreateFeeRule(context.Background(), &store.FeeRule{Id: primitive.NewObjectID(), Name: "fee", Kind: feeKind, Currency: "COIN", Percent: 100}); err != nil {
+		t.Fatal(err)
+	}
+	id := b.must(fiber.StatusCreated, "/transactions", fiber.Map{"nameTZ": "Trade", "byWho": "alice", "toWho": "bob", "value": "25.50"})
+	b.must(fiber.StatusBadRequest, "/transactions", fiber.Map{"nameTZ": "Trade", "byWho": "alice", "toWho": "bob", "value": "1000"})
+	b.must(fiber.StatusCreated, "/transactions", fiber.Map{"nameTZ": "Trade", "byWho": "bob", "toWho": "carol", "value": "5"})
+	b.must(fiber.StatusCreated, "/transactions/"+id+"/refund", fiber.Map{"value": "10"})
+
+	tests := []struct {
+		account, limit    string
+		amounts, balances []string
+	}{
+		{"alice", "1", []string{"100.00", "-25.75", "10.00"}, []string{"100.00", "74.25", "84.25"}},
+		{"alice", "50", []string{"100.00", "-25.75", "10.00"}, []string{"100.00", "74.25", "84.25"}},
+		{"bob", "2", []string{"25.50", "-5.05", "-10.00"}, []string{"25.50", "20.45", "10.45"}},
+		{"carol", "", []string{"5.00"}, []string{"5.00"}},
+		// Giving back is free
+		{bankFees, "", []string{"0.25", "0.05"}, []string{"0.25", "0.30"}},
+	}
+	for _, tt := range tests {
+		amounts, balances := b.history(tt.account, tt.limit)
+		if !slices.Equal(amounts, tt.amounts) || !slices.Equal(balances, tt.balances) {
+			t.Errorf("history of %s by %s = %v %v, want %v %v", tt.account, tt.limit, amounts, balances, tt.amounts, tt.balances)
+		}
+	}
+}
+
+func TestHistoryBackdated(t *testing.T) {
+	b := newTestBank(t)
+	b.open("alice", "100")
+	b.open("bob", "0")
+	b.must(fiber.StatusCreated, "/transactions", fiber.Map{"nameTZ": "Trade", "byWho": "alice", "toWho": "bob", "value": "10"})
+	b.must(fiber.StatusCreated, "/transactions", fiber.Map{"nameTZ": "Trade", "byWho": "alice", "toWho": "bob", "value": "20"})
+
+	amounts, balances, next := b.page("alice", "2", "")
+	if want := []string{"100.00", "-10.00"}; !slices.Equal(amounts, want) || next == "" {
+		t.Fatalf("first page = %v %v %q, want %v and more", amounts, balances, next, want)
+	}
+	// Interest of a past period is dated at its end, before everything answered so far
+	alice, err := st.GetAccountByName(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.WithTx(context.Background(), func(ctx context.Context, s store.Store) error {
+		return settleInterest(ctx, s, "Interest", typeInterest, "COIN", bankIssuer, "alice", chBank, alice.Id, 500, time.Now().Add(-time.Hour))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The walk goes on where it stopped, nothing repeated or skipped
+	amounts, balances, next = b.page("alice", "2", next)
+	if !slices.Equal(amounts, []string{"-20.00"}) || !slices.Equal(balances, []string{"70.00"}) || next != "" {
+		t.Errorf("second page = %v %v %q, want [-20.00] [70.00]", amounts, balances, next)
+	}
+	// A new walk sees it in its place
+	amounts, balances = b.history("alice", "2")
+	if !slices.Equal(amounts, []string{"5.00", "100.00", "-10.00", "-20.00"}) || !slices.Equal(balances, []string{"5.00", "105.00", "95.00", "75.00"}) {
+		t.Errorf("history = %v %v, want the interest first", amounts, balances)
+	}
+}
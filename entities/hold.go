@@ -0,0 +1,287 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/vovamod/BankAPI/money"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Hold statuses
+const (
+	holdActive   = "active"
+	holdCaptured = "captured"
+	holdReleased = "released"
+	holdExpired  = "expired"
+)
+
+const (
+	defaultHoldTTL = 7 * 24 * time.Hour
+	maxHoldTTL     = 30 * 24 * time.Hour
+	// expiredHoldsBatch bounds how many holds are read at once by the sweeper
+	expiredHoldsBatch = 100
+)
+
+var (
+	errNoHolder        = &refusal{"NO_ACCOUNT", "Invalid hold. Account does not exist"}
+	errHoldNotActive   = errors.New("Invalid hold. It has already been captured, released or has expired")
+	errHoldExpired     = errors.New("Invalid hold. It has expired")
+	errCaptureTooLarge = errors.New("Invalid capture. Value must not exceed the value held")
+)
+
+type capture struct {
+	ToWho string      `json:"toWho"`
+	Value money.Money `json:"value"`
+}
+
+// holdBody is a hold as clients send it, the value a decimal of the currency of the account
+type holdBody struct {
+	store.Hold
+	Value money.Money
+}
+
+// CRUD ops for holds
+func CreateHold(c *fiber.Ctx) error {
+	var body holdBody
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	h := body.Hold
+
+	// Validation
+	now := time.Now()
+	h.Currency = currencyByName(context.Background(), h.Account)
+	var err error
+	if h.Value, err = units(body.Value, h.Currency); err != nil {
+		return invalidValue(c, err)
+	}
+	if h.Account == "" || h.Value <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing or invalid hold fields"})
+	}
+	if err := checkIssuer(c, h.Account); err != nil {
+		return ownerResponse(c, err)
+	}
+	if h.ExpiresAt.IsZero() {
+		h.ExpiresAt = now.Add(defaultHoldTTL)
+	}
+	if !h.ExpiresAt.After(now) || h.ExpiresAt.After(now.Add(maxHoldTTL)) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Expiry of a hold must be in the next 30 days"})
+	}
+	if h.Name == "" {
+		h.Name = "Hold"
+	}
+	h.Id = primitive.NewObjectID()
+	h.CreatedAt = now
+	h.Status = holdActive
+	h.Captured, h.ToWho, h.TransactionId = 0, "", primitive.NilObjectID
+	as := asUser
+	if can(c, "accounts:overdraw") {
+		as = asAdmin
+	}
+
+	// Actual logic here thou. The account is read inside the transaction so concurrent holds cannot overbook it
+	err = st.WithTx(context.Background(), func(ctx context.Context, s store.Store) error {
+		a, err := s.GetAccountByName(ctx, h.Account)
+		if errors.Is(err, store.ErrNotFound) {
+			return errNoHolder
+		}
+		if err != nil {
+			return err
+		}
+		if err := checkActive(a); err != nil {
+			return err
+		}
+		if err := checkFunds(a, h.Value, as); err != nil {
+			return err
+		}
+		if err := s.CreateHold(ctx, &h); err != nil {
+			return err
+		}
+		return s.IncAccountHeld(ctx, a.Id, h.Value)
+	})
+	if refused(err) {
+		return refusedResponse(c, err, "")
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": "Hold has been created", "data": h})
+}
+func GetAllHolds(c *fiber.Ctx) error {
+	return GetAll(c, st.ListHolds, store.HoldFilter{Account: c.Query("account"), Status: c.Query("status")})
+}
+func GetHoldByID(c *fiber.Ctx) error {
+	return GetByID(c, st.GetHold)
+}
+
+// CaptureHold moves the value held, or part of it, to toWho. Whatever is not captured is given back.
+// The capture is a transfer like any other, checked against the limits, fraud rules and overdraft of the account
+func CaptureHold(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	var body capture
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if body.ToWho == "" || body.Value.Amount < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing or invalid capture fields"})
+	}
+	as := asUser
+	if can(c, "accounts:overdraw") {
+		as = asAdmin
+	}
+
+	var h *store.Hold
+	var t *store.Transaction
+	err = st.WithTx(context.Background(), func(ctx context.Context, s store.Store) error {
+		var err error
+		if h, err = unhold(ctx, s, id); err != nil {
+			return err
+		}
+		if !h.ExpiresAt.After(time.Now()) {
+			return errHoldExpired
+		}
+		value, err := units(body.Value, h.Currency)
+		if err != nil {
+			return err
+		}
+		if value == 0 {
+			value = h.Value
+		}
+		if value > h.Value {
+			return errCaptureTooLarge
+		}
+		t = &store.Transaction{
+			Id:       primitive.NewObjectID(),
+			Value:    value,
+			NameTZ:   h.Name,
+			Date:     time.Now(),
+			ByWho:    h.Account,
+			ToWho:    body.ToWho,
+			Type:     typeTransfer,
+			Currency: h.Currency,
+		}
+		// The value is given back to the account first so the capture may spend it, the fees come on top
+		if err := move(ctx, s, t, as); err != nil {
+			return err
+		}
+		h.Status, h.Captured, h.ToWho, h.TransactionId = holdCaptured, value, body.ToWho, t.Id
+		return s.UpdateHold(ctx, h)
+	})
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	case errors.Is(err, errHoldNotActive), errors.Is(err, errHoldExpired), errors.Is(err, errCaptureTooLarge):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case badValue(err):
+		return invalidValue(c, err)
+	case refused(err):
+		return refusedResponse(c, err, "")
+	case err != nil:
+		log.Errorf("Failed to capture hold %v: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Transaction failed and has been rolled back"})
+	}
+	if t.Status == statusPendingReview {
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"success": "Capture is pending review by an admin", "data": h, "transaction": t})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": "Hold has been captured", "data": h, "transaction": t})
+}
+
+// ReleaseHold gives the value held back to the account
+func ReleaseHold(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	err = releaseHold(context.Background(), id, holdReleased)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	case errors.Is(err, errHoldNotActive):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Hold released successfully"})
+}
+
+// releaseHold ends the hold with status, giving its value back
+func releaseHold(ctx context.Context, id primitive.ObjectID, status string) error {
+	return st.WithTx(ctx, func(ctx context.Context, s store.Store) error {
+		h, err := unhold(ctx, s, id)
+		if err != nil {
+			return err
+		}
+		h.Status = status
+		return s.UpdateHold(ctx, h)
+	})
+}
+
+// unhold takes the value of an active hold off the value held by its account, s must come from WithTx.
+// An account deleted since has nothing to take it off
+func unhold(ctx context.Context, s store.Store, id primitive.ObjectID) (*store.Hold, error) {
+	h, err := s.GetHold(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if h.Status != holdActive {
+		return nil, errHoldNotActive
+	}
+	a, err := s.GetAccountByName(ctx, h.Account)
+	if errors.Is(err, store.ErrNotFound) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return h, s.IncAccountHeld(ctx, a.Id, -h.Value)
+}
+
+// ReleaseExpiredHolds gives back the value of every hold past its expiry, the scheduler calls it on every tick
+func ReleaseExpiredHolds(ctx context.Context, now time.Time) error {
+	for {
+		expired, err := st.ExpiredHolds(ctx, holdActive, now, expiredHoldsBatch)
+		if err != nil {
+			return err
+		}
+		released := 0
+		for _, h := range expired {
+			// Captured or released meanwhile is fine, it is not held anymore either way
+			if err := releaseHold(ctx, h.Id, holdExpired); err != nil && !errors.Is(err, errHoldNotActive) {
+				log.Errorf("Failed to release expired hold %v: %v", h.Id, err)
+				continue
+			}
+			released++
+		}
+		if len(expired) < expiredHoldsBatch || released == 0 {
+			return nil
+		}
+	}
+}
+
+// GetAccountBalance answers with the balance of the account, its holds and what is available to spend
+func GetAccountBalance(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
+	}
+	a, err := st.GetAccount(context.Background(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"account":   a.Name,
+		"value":     decimal(a.Value, a.Currency),
+		"held":      decimal(a.Held, a.Currency),
+		"available": decimal(a.Value-a.Held, a.Currency),
+	})
+}
@@ -0,0 +1,286 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/vovamod/BankAPI/money"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math/big"
+	"time"
+)
+
+// How often interest is paid to the accounts of a type
+const (
+	postingDaily   = "daily"
+	postingMonthly = "monthly"
+)
+
+// Rates are yearly in basis points, interest is worked out every day on a 365 days year
+const (
+	basisPoints = 10000
+	daysInYear  = 365
+)
+
+// day truncates t to the start of its UTC day
+func day(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// periodEnd returns the end of the posting period starting at from
+func periodEnd(posting string, from time.Time) time.Time {
+	if posting == postingMonthly {
+		y, m, _ := from.Date()
+		return time.Date(y, m+1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return from.AddDate(0, 0, 1)
+}
+
+// dueAccrualsBatch bounds how many accruals are read at once
+const dueAccrualsBatch = 100
+
+// dailyBalances walks the balance of an account day by day between from and to, at the end of each day.
+// credit sums the balances above exclude and debit the negative ones, postings must be in date order
+func dailyBalances(postings []store.Posting, from, to time.Time, exclude int) (credit, debit int64) {
+	balance, next := 0, 0
+	for end := from.AddDate(0, 0, 1); !end.After(to); end = end.AddDate(0, 0, 1) {
+		for ; next < len(postings) && postings[next].Date.Before(end); next++ {
+			balance += postings[next].Amount
+		}
+		if balance > exclude {
+			credit += int64(balance - exclude)
+		}
+		if balance < 0 {
+			debit += int64(-balance)
+		}
+	}
+	return credit, debit
+}
+
+// yearly applies the yearly rate in basis points to a sum of daily balances, rounded down
+func yearly(sum int64, rate int) int {
+	r := big.NewRat(sum, daysInYear*basisPoints)
+	v, _ := money.Round(r.Mul(r, big.NewRat(int64(rate), 1)), money.Down)
+	return int(v)
+}
+
+// AccrueInterest settles the interest of every account for the periods that ended: it pays the interest of its
+// type and charges the interest of its overdraft. The scheduler calls it on every tick
+func AccrueInterest(ctx context.Context, now time.Time) error {
+	for {
+		due, err := st.DueAccruals(ctx, now, dueAccrualsBatch)
+		if err != nil {
+			return err
+		}
+		settled := 0
+		for _, acc := range due {
+			if err := accrue(ctx, acc.Account, now); err != nil {
+				log.Errorf("Interest of account %v failed and will be tried again: %v", acc.Account, err)
+				continue
+			}
+			settled++
+		}
+		// Failed ones stay due, leave them to the next tick
+		if len(due) < dueAccrualsBatch || settled == 0 {
+			return nil
+		}
+	}
+}
+
+// accrue settles the account every period that ended before now, one transaction each
+func accrue(ctx context.Context, id primitive.ObjectID, now time.Time) error {
+	for {
+		settled, err := accruePeriod(ctx, id, now)
+		if err != nil || !settled {
+			return err
+		}
+	}
+}
+
+// accruePeriod settles the first period of the account not settled yet if it has ended, otherwise it moves Due
+// to its end. The accrual is read again and moved forward in the same transaction as the interest, so a period
+// is never settled twice even across restarts
+func accruePeriod(ctx context.Context, id primitive.ObjectID, now time.Time) (bool, error) {
+	settled := false
+	err := st.WithTx(ctx, func(ctx context.Context, s store.Store) error {
+		settled = false
+		acc, err := s.GetAccrual(ctx, id)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		a, err := s.GetAccount(ctx, id)
+		if errors.Is(err, store.ErrNotFound) {
+			return s.DeleteAccrual(ctx, id)
+		}
+		if err != nil {
+			return err
+		}
+		if stateOf(a) == accountClosed {
+			return s.DeleteAccrual(ctx, id)
+		}
+		// Accounts without a type only pay overdraft interest, monthly
+		var t *store.AccountType
+		posting := postingMonthly
+		if a.Type != "" {
+			if t, err = s.GetAccountTypeByName(ctx, a.Type); err != nil {
+				return err
+			}
+			posting = t.Posting
+		}
+		end := periodEnd(posting, acc.Through)
+		if end.After(now) {
+			if acc.Due.Equal(end) {
+				return nil
+			}
+			acc.Due = end
+			return s.UpdateAccrual(ctx, acc)
+		}
+
+		postings, err := s.ListPostings(ctx, id)
+		if err != nil {
+			return err
+		}
+		// Simple interest leaves out the interest paid so far
+		exclude := 0
+		if t != nil && !t.Compound {
+			exclude = acc.Paid
+		}
+		credit, debit := dailyBalances(postings, acc.Through, end, exclude)
+		since := acc.Through.Format(time.DateOnly)
+		if t != nil {
+			if amount := yearly(credit, t.Rate); amount > 0 {
+				if err := settleInterest(ctx, s, "Interest from "+since, typeInterest, currencyOf(a), bankIssuer, a.Name, chBank, a.Id, amount, end); err != nil {
+					return err
+				}
+				acc.Paid += amount
+			}
+		}
+		if amount := yearly(debit, a.OverdraftRate); amount > 0 {
+			if err := settleInterest(ctx, s, "Overdraft interest from "+since, typeOverdraft, currencyOf(a), a.Name, bankIssuer, a.Id, chBank, amount, end); err != nil {
+				return err
+			}
+			acc.Charged += amount
+		}
+		acc.Through, acc.Due = end, periodEnd(posting, end)
+		settled = true
+		return s.UpdateAccrual(ctx, acc)
+	})
+	return settled, err
+}
+
+// settleInterest records amount of interest in currency going from one account to the other at date. Overdraft
+// interest is charged whatever the overdraft policy allows, s must come from WithTx
+func settleInterest(ctx context.Context, s store.Store, name, kind, currency, byWho, toWho string, from, to primitive.ObjectID, amount int, date time.Time) error {
+	t := &store.Transaction{
+		Id:       primitive.NewObjectID(),
+		Value:    amount,
+		NameTZ:   name,
+		Date:     date,
+		Status:   statusCompleted,
+		ByWho:    byWho,
+		ToWho:    toWho,
+		Type:     kind,
+		Currency: currency,
+	}
+	if err := s.CreateTransaction(ctx, t); err != nil {
+		return err
+	}
+	return post(ctx, s, t, leg{from, -amount}, leg{to, amount})
+}
+
+// accountTypeBody is an account type as clients send it, the limits decimals applied in every currency
+type accountTypeBody struct {
+	store.AccountType
+	Limits limitsBody
+}
+
+// typeDecimal is v of the limits of an account type as clients see it
+func typeDecimal(v int) money.Money {
+	return money.Money{Amount: int64(v), Scale: money.DefaultScale}
+}
+
+// CRUD ops for account types
+func CreateAccountType(c *fiber.Ctx) error {
+	var body accountTypeBody
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	t := body.AccountType
+	var err error
+	if t.Limits, err = body.Limits.read(typeUnits); err != nil {
+		return invalidValue(c, err)
+	}
+	if msg := checkAccountType(&t); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	t.Id = primitive.NewObjectID()
+	err = st.CreateAccountType(context.Background(), &t)
+	if errors.Is(err, store.ErrDuplicate) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid account type name provided. This name is already taken"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": "Account type has been created", "id": t.Id})
+}
+func GetAllAccountTypes(c *fiber.Ctx) error {
+	return GetAll(c, func(ctx context.Context, _ struct{}, p store.Page) (*store.Result[store.AccountType], error) {
+		return st.ListAccountTypes(ctx, p)
+	}, struct{}{})
+}
+func GetAccountTypeByID(c *fiber.Ctx) error {
+	return GetByID(c, st.GetAccountType)
+}
+
+// UpdateAccountTypeByID changes the rate or the posting of a type, accounts keep it by name so it cannot be renamed.
+// The new terms apply to every period not paid yet
+func UpdateAccountTypeByID(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	t, err := st.GetAccountType(context.Background(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	name := t.Name
+	l := t.Limits
+	body := accountTypeBody{AccountType: *t, Limits: limitsBody{typeDecimal(l.PerTransaction), typeDecimal(l.Daily), typeDecimal(l.Weekly), l.Hourly}}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	*t = body.AccountType
+	if t.Limits, err = body.Limits.read(typeUnits); err != nil {
+		return invalidValue(c, err)
+	}
+	t.Id, t.Name = id, name
+	if msg := checkAccountType(t); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	if err := st.UpdateAccountType(context.Background(), t); err != nil {
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Account type updated successfully", "data": t})
+}
+
+// checkAccountType returns what is wrong with t, empty when it is valid
+func checkAccountType(t *store.AccountType) string {
+	switch {
+	case t.Name == "":
+		return "Missing Name of the account type"
+	case t.Rate < 0:
+		return "Rate of an account type cannot be negative"
+	case t.Posting != postingDaily && t.Posting != postingMonthly:
+		return "Posting of an account type must be daily or monthly"
+	}
+	return checkLimits(t.Limits)
+}
@@ -0,0 +1,176 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// leg is one side of a ledger entry, amount is negative for the debited account
+type leg struct {
+	account primitive.ObjectID
+	amount  int
+}
+
+// post records the postings of t and applies them to the cached balances. The legs must sum to zero
+// and s must come from WithTx so the postings and the balances commit together
+func post(ctx context.Context, s store.Store, t *store.Transaction, legs ...leg) error {
+	sum := 0
+	postings := make([]store.Posting, 0, len(legs))
+	for _, l := range legs {
+		sum += l.amount
+		postings = append(postings, store.Posting{
+			Id:            primitive.NewObjectID(),
+			TransactionId: t.Id,
+			Account:       l.account,
+			Amount:        l.amount,
+			Date:          t.Date,
+		})
+	}
+	if sum != 0 {
+		return fmt.Errorf("unbalanced postings for transaction %v, they sum to %d", t.Id, sum)
+	}
+	if err := s.CreatePostings(ctx, postings); err != nil {
+		return err
+	}
+	for _, p := range postings {
+		if err := s.IncAccountValue(ctx, p.Account, p.Amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openingTransaction is the transaction BANK_ISSUER funds a new account with
+func openingTransaction(name string, value int) *store.Transaction {
+	return &store.Transaction{
+		Id:     primitive.NewObjectID(),
+		Value:  value,
+		NameTZ: "Opening balance",
+		Date:   time.Now(),
+		Status: statusCompleted,
+		ByWho:  bankIssuer,
+		ToWho:  name,
+	}
+}
+
+// fund funds a new account from BANK_ISSUER, s must come from WithTx
+func fund(ctx context.Context, s store.Store, a *store.Account, value int) error {
+	t := openingTransaction(a.Name, value)
+	t.Currency = currencyOf(a)
+	if err := s.CreateTransaction(ctx, t); err != nil {
+		return err
+	}
+	return post(ctx, s, t, leg{chBank, -value}, leg{a.Id, value})
+}
+
+// LedgerInit opens the ledger of accounts created before it existed. An account with a value but
+// no postings gets an opening transaction from BANK_ISSUER, leaving its cached balance as it was.
+// BANK_ISSUER is opened last, by the openings of the others
+func LedgerInit(s store.Store) {
+	ctx := context.Background()
+	_, issued, err := s.SumPostings(ctx, chBank)
+	if err != nil {
+		log.Fatalf("Error opening the ledger: %v", err)
+	}
+	err = each(ctx, s.ListAccounts, store.AccountFilter{}, func(a store.Account) error {
+		return openLedger(ctx, s, a)
+	})
+	if err == nil && issued == 0 {
+		err = openIssuer(ctx, s)
+	}
+	if err != nil {
+		log.Fatalf("Error opening the ledger: %v", err)
+	}
+}
+
+// openIssuer sets the cached balance of BANK_ISSUER to the sum of its postings. Before the ledger it paid out
+// what the openings of the other accounts pay again, so what it had then is replaced and not added to
+func openIssuer(ctx context.Context, s store.Store) error {
+	return s.WithTx(ctx, func(ctx context.Context, s store.Store) error {
+		a, err := s.GetAccount(ctx, chBank)
+		if err != nil {
+			return err
+		}
+		sum, _, err := s.SumPostings(ctx, chBank)
+		if err != nil || a.Value == sum {
+			return err
+		}
+		log.Infof("Opened the ledger of %s with %d, its balance was %d", bankIssuer, sum, a.Value)
+		return s.IncAccountValue(ctx, chBank, sum-a.Value)
+	})
+}
+
+// openLedger funds a from BANK_ISSUER when it has a value but no postings yet
+func openLedger(ctx context.Context, s store.Store, a store.Account) error {
+	if a.Id == chBank || a.Value == 0 {
+		return nil
+	}
+	_, count, err := s.SumPostings(ctx, a.Id)
+	if err != nil || count > 0 {
+		return err
+	}
+	err = s.WithTx(ctx, func(ctx context.Context, s store.Store) error {
+		// fund adds the value again, take it out first
+		if err := s.IncAccountValue(ctx, a.Id, -a.Value); err != nil {
+			return err
+		}
+		return fund(ctx, s, &a, a.Value)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", a.Name, err)
+	}
+	log.Infof("Opened the ledger of %s with %d", a.Name, a.Value)
+	return nil
+}
+
+// each calls fn for every record list returns for f, page by page
+func each[T any, F any](ctx context.Context, list func(ctx context.Context, f F, p store.Page) (*store.Result[T], error), f F, fn func(v T) error) error {
+	p := store.Page{Limit: store.MaxLimit}
+	for {
+		result, err := list(ctx, f, p)
+		if err != nil {
+			return err
+		}
+		for _, v := range result.Data {
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
+		if result.Next == "" {
+			return nil
+		}
+		p.Cursor = result.Next
+	}
+}
+
+// VerifyAccountByID compares the cached balance of the account with the sum of its postings
+func VerifyAccountByID(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
+	}
+	a, err := st.GetAccount(context.Background(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	sum, count, err := st.SumPostings(context.Background(), id)
+	if err != nil {
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"account":  a.Name,
+		"cached":   decimal(a.Value, a.Currency),
+		"ledger":   decimal(sum, a.Currency),
+		"postings": count,
+		"balanced": a.Value == sum,
+	})
+}
@@ -0,0 +1,39 @@
+package entities
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/money"
+	"github.com/vovamod/BankAPI/store"
+	"github.com/vovamod/BankAPI/store/memstore"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+func TestLedgerInit(t *testing.T) {
+	if err := money.Configure("", "COIN"); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	s := memstore.New()
+	// Balances from before the ledger, BANK_ISSUER having paid out what the others hold
+	for _, a := range []store.Account{{Name: bankIssuer, Value: -300}, {Name: "alice", Value: 100, Currency: "COIN"}, {Name: "bob", Value: 200, Currency: "COIN"}} {
+		a.Id = primitive.NewObjectID()
+		if err := s.CreateAccount(ctx, &a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Opening it again changes nothing
+	for range 2 {
+		Init(s)
+		for name, want := range map[string]int{bankIssuer: -300, "alice": 100, "bob": 200} {
+			a, err := s.GetAccountByName(ctx, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sum, _, err := s.SumPostings(ctx, a.Id)
+			if err != nil || a.Value != want || sum != want {
+				t.Errorf("%s has %d and its postings sum to %d, %v, want %d", name, a.Value, sum, err, want)
+			}
+		}
+	}
+}
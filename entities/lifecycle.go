@@ -0,0 +1,184 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"slices"
+	"time"
+)
+
+// Lifecycle states of an account. Only an active account moves money, a closed one is kept for its history
+const (
+	accountPending = "pending"
+	accountActive  = "active"
+	accountFrozen  = "frozen"
+	accountClosed  = "closed"
+)
+
+// transitions are the states an account may go to from each state, closed is final
+var transitions = map[string][]string{
+	accountPending: {accountActive, accountClosed},
+	accountActive:  {accountFrozen, accountClosed},
+	accountFrozen:  {accountActive, accountClosed},
+}
+
+var (
+	errAccountPending = &refusal{"ACCOUNT_PENDING", "Invalid transaction. Account is not activated yet"}
+	errAccountFrozen  = &refusal{"ACCOUNT_FROZEN", "Invalid transaction. Account is frozen"}
+	errAccountClosed  = &refusal{"ACCOUNT_CLOSED", "Invalid transaction. Account is closed"}
+	errTransition     = &refusal{"INVALID_TRANSITION", "Account cannot go to this state from its current one"}
+	errSystemState    = &refusal{"SYSTEM_ACCOUNT", "System accounts are always active"}
+	errCloseHeld      = &refusal{"ACCOUNT_HELD", "Account has active holds, release or capture them before closing it"}
+	errCloseNegative  = &refusal{"ACCOUNT_NEGATIVE", "Account is below zero, it must be paid back before closing it"}
+	errCloseBalance   = &refusal{"ACCOUNT_BALANCE", "Account still has a balance, give an account to sweep it to with sweepTo"}
+	errSweepSelf      = &refusal{"SWEEP_SELF", "An account cannot be swept to itself"}
+)
+
+// stateOf is the state of a, accounts made before states existed are active
+func stateOf(a *store.Account) string {
+	if a.Status == "" {
+		return accountActive
+	}
+	return a.Status
+}
+
+// checkActive refuses a unless it is active
+func checkActive(a *store.Account) error {
+	switch stateOf(a) {
+	case accountPending:
+		return errAccountPending
+	case accountFrozen:
+		return errAccountFrozen
+	case accountClosed:
+		return errAccountClosed
+	}
+	return nil
+}
+
+// transition moves the account id to state to inside s, refusing system accounts and transitions not allowed
+func transition(ctx context.Context, s store.Store, id primitive.ObjectID, to string) (*store.Account, error) {
+	a, err := s.GetAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if currencyOf(a) == "" {
+		return nil, errSystemState
+	}
+	if !slices.Contains(transitions[stateOf(a)], to) {
+		return nil, errTransition
+	}
+	a.Status = to
+	return a, s.UpdateAccount(ctx, a)
+}
+
+// closeAccount closes the account id inside s. Whatever it holds is swept to sweepTo first, its unpaid interest is given up
+func closeAccount(ctx context.Context, s store.Store, id primitive.ObjectID, sweepTo string) (*store.Account, error) {
+	a, err := s.GetAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if currencyOf(a) == "" {
+		return nil, errSystemState
+	}
+	if !slices.Contains(transitions[stateOf(a)], accountClosed) {
+		return nil, errTransition
+	}
+	switch {
+	case a.Held > 0:
+		return nil, errCloseHeld
+	case a.Value < 0:
+		return nil, errCloseNegative
+	case a.Value > 0 && sweepTo == "":
+		return nil, errCloseBalance
+	case a.Value > 0 && sweepTo == a.Name:
+		return nil, errSweepSelf
+	}
+	if a.Value > 0 {
+		t := &store.Transaction{
+			Id:       primitive.NewObjectID(),
+			Value:    a.Value,
+			NameTZ:   "Sweep of closed account " + a.Name,
+			Date:     time.Now(),
+			ByWho:    a.Name,
+			ToWho:    sweepTo,
+			Type:     typeSweep,
+			Currency: currencyOf(a),
+		}
+		if err := move(ctx, s, t, forced); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.DeleteAccrual(ctx, a.Id); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	a.Status, a.Value = accountClosed, 0
+	return a, s.UpdateAccount(ctx, a)
+}
+
+// stateResponse answers the outcome of changing the state of an account
+func stateResponse(c *fiber.Ctx, a *store.Account, err error, msg string) error {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
+	case refused(err):
+		return refusedResponse(c, err, "")
+	case err != nil:
+		log.Errorf("Failed to change the state of account %v: %v", c.Params("id"), err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Account state change failed and has been rolled back"})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": msg, "data": a})
+}
+
+// setState is the body of the endpoints moving an account to state to
+func setState(c *fiber.Ctx, to, msg string) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
+	}
+	var a *store.Account
+	err = st.WithTx(context.Background(), func(ctx context.Context, s store.Store) error {
+		var err error
+		a, err = transition(ctx, s, id, to)
+		return err
+	})
+	return stateResponse(c, a, err, msg)
+}
+
+// ActivateAccountByID activates a pending account or unfreezes a frozen one
+func ActivateAccountByID(c *fiber.Ctx) error {
+	return setState(c, accountActive, "Account has been activated")
+}
+
+// FreezeAccountByID stops an active account from paying or receiving anything until it is activated again
+func FreezeAccountByID(c *fiber.Ctx) error {
+	return setState(c, accountFrozen, "Account has been frozen")
+}
+
+type closeBody struct {
+	SweepTo string `json:"sweepTo"`
+}
+
+// CloseAccountByID closes an account for good. It must be empty or have its balance swept to the account sweepTo
+func CloseAccountByID(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
+	}
+	var body closeBody
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+		}
+	}
+	var a *store.Account
+	err = st.WithTx(context.Background(), func(ctx context.Context, s store.Store) error {
+		var err error
+		a, err = closeAccount(ctx, s, id, body.SweepTo)
+		return err
+	})
+	return stateResponse(c, a, err, "Account has been closed")
+}
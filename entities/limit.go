@@ -0,0 +1,294 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/vovamod/BankAPI/money"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math/big"
+	"slices"
+	"time"
+)
+
+var (
+	errLimitTransaction = &refusal{"LIMIT_PER_TRANSACTION", "Invalid transaction. Value is above the limit of a single transaction of the sender"}
+	errLimitDaily       = &refusal{"LIMIT_DAILY", "Invalid transaction. Sender would go above its limit for the last 24 hours"}
+	errLimitWeekly      = &refusal{"LIMIT_WEEKLY", "Invalid transaction. Sender would go above its limit for the last 7 days"}
+	errLimitHourly      = &refusal{"LIMIT_VELOCITY", "Invalid transaction. Sender made too many transfers in the last hour"}
+)
+
+// limitTypes are the transaction types limits apply to and count, giving money back or paying interest is not spending
+var limitTypes = []string{typeTransfer, typeEscrow}
+
+// limitsBody is limits as clients send them, amounts are decimals
+type limitsBody struct {
+	PerTransaction money.Money
+	Daily          money.Money
+	Weekly         money.Money
+	Hourly         int
+}
+
+// read returns the limits with the amounts read by units
+func (b limitsBody) read(units func(money.Money) (int, error)) (store.Limits, error) {
+	l := store.Limits{Hourly: b.Hourly}
+	var err error
+	if l.PerTransaction, err = units(b.PerTransaction); err != nil {
+		return l, err
+	}
+	if l.Daily, err = units(b.Daily); err != nil {
+		return l, err
+	}
+	if l.Weekly, err = units(b.Weekly); err != nil {
+		return l, err
+	}
+	return l, nil
+}
+
+// typeUnits reads m at the scale the limits of account types are kept
+func typeUnits(m money.Money) (int, error) {
+	v, err := m.At(money.DefaultScale)
+	return int(v.Amount), err
+}
+
+// accountUnits reads amounts in the currency of a
+func accountUnits(a *store.Account) func(money.Money) (int, error) {
+	return func(m money.Money) (int, error) {
+		return units(m, currencyOf(a))
+	}
+}
+
+// checkLimits returns what is wrong with l, empty when it is valid
+func checkLimits(l store.Limits) string {
+	if l.PerTransaction < 0 || l.Daily < 0 || l.Weekly < 0 || l.Hourly < 0 {
+		return "Limits cannot be negative, 0 is no limit"
+	}
+	return ""
+}
+
+// limitsOf are the limits of a: those of its type in the currency of a, replaced one by one by those set on a
+func limitsOf(ctx context.Context, s store.Store, a *store.Account) (store.Limits, error) {
+	var l store.Limits
+	if a.Type != "" {
+		t, err := s.GetAccountTypeByName(ctx, a.Type)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return l, err
+		}
+		if err == nil {
+			in := func(v int) int {
+				// A type limit too large for the currency is no limit at all
+				m, err := money.Money{Amount: int64(v), Scale: money.DefaultScale}.Convert(big.NewRat(1, 1), currencyOf(a))
+				if err != nil {
+					return 0
+				}
+				return int(m.Amount)
+			}
+			l = store.Limits{PerTransaction: in(t.Limits.PerTransaction), Daily: in(t.Limits.Daily), Weekly: in(t.Limits.Weekly), Hourly: t.Limits.Hourly}
+		}
+	}
+	for _, o := range []struct{ limit, override *int }{
+		{&l.PerTransaction, &a.Limits.PerTransaction}, {&l.Daily, &a.Limits.Daily}, {&l.Weekly, &a.Limits.Weekly}, {&l.Hourly, &a.Limits.Hourly},
+	} {
+		if *o.override != 0 {
+			*o.limit = *o.override
+		}
+	}
+	return l, nil
+}
+
+// payersOf are the accounts whose payments count against the limits of a: a and the other accounts
+// of its user in the same currency, so spreading transfers over accounts does not get around them
+func payersOf(ctx context.Context, s store.Store, a *store.Account) ([]string, error) {
+	names := []string{a.Name}
+	u, err := s.GetUserByAccount(ctx, a.Id.Hex())
+	if errors.Is(err, store.ErrNotFound) {
+		return names, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ids []primitive.ObjectID
+	for _, account := range u.Account {
+		if id, err := primitive.ObjectIDFromHex(account); err == nil && id != a.Id {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return names, nil
+	}
+	others, err := s.ListAccounts(ctx, store.AccountFilter{Ids: ids}, store.Page{Limit: store.MaxLimit})
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range others.Data {
+		if currencyOf(&other) == currencyOf(a) {
+			names = append(names, other.Name)
+		}
+	}
+	return names, nil
+}
+
+// usage is what has been paid lately against the limits of an account: the value of the last 24 hours and 7 days
+// and the number of payments of the last hour
+type usage struct {
+	Daily  int
+	Weekly int
+	Hourly int
+}
+
+// usageOf works out the usage of a at now, only for the limits set in l
+func usageOf(ctx context.Context, s store.Store, a *store.Account, l store.Limits, now time.Time) (usage, error) {
+	var u usage
+	if l.Daily == 0 && l.Weekly == 0 && l.Hourly == 0 {
+		return u, nil
+	}
+	payers, err := payersOf(ctx, s, a)
+	if err != nil {
+		return u, err
+	}
+	f := store.SpendingFilter{Payers: payers, Types: limitTypes, StatusNot: unsettledStatuses}
+	if l.Daily > 0 {
+		f.Since = now.Add(-24 * time.Hour)
+		if u.Daily, _, err = s.Spent(ctx, f); err != nil {
+			return u, err
+		}
+	}
+	if l.Weekly > 0 {
+		f.Since = now.AddDate(0, 0, -7)
+		if u.Weekly, _, err = s.Spent(ctx, f); err != nil {
+			return u, err
+		}
+	}
+	if l.Hourly > 0 {
+		f.Since = now.Add(-time.Hour)
+		if _, u.Hourly, err = s.Spent(ctx, f); err != nil {
+			return u, err
+		}
+	}
+	return u, nil
+}
+
+// checkSpending refuses from paying value when it would go above its limits. System accounts have none
+func checkSpending(ctx context.Context, s store.Store, t *store.Transaction, from *store.Account, value int) error {
+	if currencyOf(from) == "" || !slices.Contains(limitTypes, t.Type) {
+		return nil
+	}
+	l, err := limitsOf(ctx, s, from)
+	if err != nil {
+		return err
+	}
+	if l.PerTransaction > 0 && value > l.PerTransaction {
+		return errLimitTransaction
+	}
+	u, err := usageOf(ctx, s, from, l, time.Now())
+	if err != nil {
+		return err
+	}
+	switch {
+	case l.Daily > 0 && value > l.Daily-u.Daily:
+		return errLimitDaily
+	case l.Weekly > 0 && value > l.Weekly-u.Weekly:
+		return errLimitWeekly
+	case l.Hourly > 0 && u.Hourly >= l.Hourly:
+		return errLimitHourly
+	}
+	return nil
+}
+
+// allowance is what an account may still pay, nil where there is no limit
+type allowance struct {
+	PerTransaction *money.Money
+	Daily          *money.Money
+	Weekly         *money.Money
+	Hourly         *int
+}
+
+// GetAccountLimits shows the limits of an account, what it used of them and what is left
+func GetAccountLimits(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
+	}
+	ctx := context.Background()
+	a, err := st.GetAccount(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	l, err := limitsOf(ctx, st, a)
+	if err != nil {
+		return dbError(c, err)
+	}
+	u, err := usageOf(ctx, st, a, l, time.Now())
+	if err != nil {
+		return dbError(c, err)
+	}
+	currency := currencyOf(a)
+	remaining := func(limit, used int) *money.Money {
+		if limit == 0 {
+			return nil
+		}
+		m := decimal(max(limit-used, 0), currency)
+		return &m
+	}
+	var left allowance
+	left.PerTransaction, left.Daily, left.Weekly = remaining(l.PerTransaction, 0), remaining(l.Daily, u.Daily), remaining(l.Weekly, u.Weekly)
+	if l.Hourly > 0 {
+		hourly := max(l.Hourly-u.Hourly, 0)
+		left.Hourly = &hourly
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"account": a.Name,
+		"limits":  limitsBody{decimal(l.PerTransaction, currency), decimal(l.Daily, currency), decimal(l.Weekly, currency), l.Hourly},
+		"used":    fiber.Map{"Daily": decimal(u.Daily, currency), "Weekly": decimal(u.Weekly, currency), "Hourly": u.Hourly},
+		"left":    left,
+	})
+}
+
+// UpdateLimitsByID sets the limits of the account replacing those of its type, 0 keeps the one of the type
+func UpdateLimitsByID(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
+	}
+	var body limitsBody
+	if err := c.BodyParser(&body); err != nil {
+		if badValue(err) {
+			return invalidValue(c, err)
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	// The limits are in the currency of the account, which never changes so it can be read ahead
+	a, err := st.GetAccount(context.Background(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	limits, err := body.read(accountUnits(a))
+	if err != nil {
+		return invalidValue(c, err)
+	}
+	if msg := checkLimits(limits); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	err = st.WithTx(context.Background(), func(ctx context.Context, s store.Store) error {
+		var err error
+		if a, err = s.GetAccount(ctx, id); err != nil {
+			return err
+		}
+		a.Limits = limits
+		return s.UpdateAccount(ctx, a)
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Limits updated successfully", "data": a})
+}
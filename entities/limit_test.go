@@ -0,0 +1,146 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"github.com/vovamod/BankAPI/money"
+	"github.com/vovamod/BankAPI/store"
+	"github.com/vovamod/BankAPI/store/memstore"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+// spender is a store with alice paying in COIN from two accounts of her user and in GEM from a third,
+// with payments spread over the last days
+func spender(t *testing.T) (store.Store, *store.Account) {
+	t.Helper()
+	if err := money.Configure("COIN:2,GEM:0", "COIN"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { money.Configure("", "COIN") })
+
+	ctx := context.Background()
+	s := memstore.New()
+	var accounts []*store.Account
+	for _, a := range []struct{ name, currency string }{{"alice", "COIN"}, {"alice2", "COIN"}, {"alice3", "GEM"}} {
+		acc := &store.Account{Id: primitive.NewObjectID(), Name: a.name, Currency: a.currency}
+		if err := s.CreateAccount(ctx, acc); err != nil {
+			t.Fatal(err)
+		}
+		accounts = append(accounts, acc)
+	}
+	u := &store.User{Id: primitive.NewObjectID(), Name: "alice", Account: []string{accounts[0].Id.Hex(), accounts[1].Id.Hex(), accounts[2].Id.Hex()}}
+	if err := s.CreateUser(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for _, p := range []struct {
+		ago          time.Duration
+		byWho, toWho string
+		value        int
+		kind, status string
+	}{
+		{30 * time.Minute, "alice", "bob", 1000, typeTransfer, statusCompleted},
+		{2 * time.Hour, "alice", "BANK_ESCROW", 2000, typeEscrow, statusCompleted},
+		// The other COIN account of her user
+		{23 * time.Hour, "alice2", "bob", 3000, typeTransfer, statusCompleted},
+		{25 * time.Hour, "alice", "bob", 4000, typeTransfer, statusCompleted},
+		// Charged by the bank, she pays
+		{6 * 24 * time.Hour, bankIssuer, "alice", -500, typeTransfer, statusCompleted},
+		{8 * 24 * time.Hour, "alice", "bob", 9000, typeTransfer, statusCompleted},
+		// None of these count: giving back, failed, waiting for review, received, another currency
+		{10 * time.Minute, "alice", "bob", 7000, typeRefund, statusCompleted},
+		{5 * time.Minute, "alice", "bob", 8000, typeTransfer, statusFail},
+		{5 * time.Minute, "alice", "bob", 100, typeTransfer, statusPendingReview},
+		{5 * time.Minute, "bob", "alice", 600, typeTransfer, statusCompleted},
+		{5 * time.Minute, "alice3", "bob", 6000, typeTransfer, statusCompleted},
+	} {
+		tr := &store.Transaction{Id: primitive.NewObjectID(), Date: now.Add(-p.ago), ByWho: p.byWho, ToWho: p.toWho, Value: p.value, Type: p.kind, Status: p.status}
+		if err := s.CreateTransaction(ctx, tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s, accounts[0]
+}
+
+func TestUsageWindows(t *testing.T) {
+	s, alice := spender(t)
+	u, err := usageOf(context.Background(), s, alice, store.Limits{Daily: 1, Weekly: 1, Hourly: 1}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (usage{Daily: 6000, Weekly: 10500, Hourly: 1}); u != want {
+		t.Errorf("usage = %+v, want %+v", u, want)
+	}
+	// Only the windows with a limit are worked out
+	u, err = usageOf(context.Background(), s, alice, store.Limits{Weekly: 1}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (usage{Weekly: 10500}); u != want {
+		t.Errorf("usage of the weekly limit = %+v, want %+v", u, want)
+	}
+}
+
+func TestCheckSpending(t *testing.T) {
+	s, alice := spender(t)
+	tests := []struct {
+		name   string
+		limits store.Limits
+		value  int
+		want   error
+	}{
+		{"no limits", store.Limits{}, 1000000, nil},
+		{"per transaction", store.Limits{PerTransaction: 5000}, 5000, nil},
+		{"above per transaction", store.Limits{PerTransaction: 5000}, 5001, errLimitTransaction},
+		{"daily", store.Limits{Daily: 10000}, 4000, nil},
+		{"above daily", store.Limits{Daily: 10000}, 4001, errLimitDaily},
+		{"weekly", store.Limits{Weekly: 12000}, 1500, nil},
+		{"above weekly", store.Limits{Weekly: 12000}, 1501, errLimitWeekly},
+		{"hourly", store.Limits{Hourly: 2}, 1, nil},
+		{"above hourly", store.Limits{Hourly: 1}, 1, errLimitHourly},
+		{"daily first", store.Limits{Daily: 6000, Weekly: 10500}, 1, errLimitDaily},
+	}
+	for _, tt := range tests {
+		alice.Limits = tt.limits
+		tr := &store.Transaction{Type: typeTransfer}
+		if err := checkSpending(context.Background(), s, tr, alice, tt.value); !errors.Is(err, tt.want) {
+			t.Errorf("%s: paying %d = %v, want %v", tt.name, tt.value, err, tt.want)
+		}
+	}
+	// Giving money back is not spending
+	alice.Limits = store.Limits{PerTransaction: 1}
+	if err := checkSpending(context.Background(), s, &store.Transaction{Type: typeRefund}, alice, 100); err != nil {
+		t.Errorf("refund checked against the limits: %v", err)
+	}
+}
+
+func TestLimitsOf(t *testing.T) {
+	s, _ := spender(t)
+	ctx := context.Background()
+	// 10.00 a day and 50.00 a week, kept at the default scale
+	capped := &store.AccountType{Id: primitive.NewObjectID(), Name: "capped", Posting: postingMonthly, Limits: store.Limits{Daily: 1000, Weekly: 5000, Hourly: 3}}
+	if err := s.CreateAccountType(ctx, capped); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		account store.Account
+		want    store.Limits
+	}{
+		{"no type", store.Account{Currency: "COIN", Limits: store.Limits{Daily: 7}}, store.Limits{Daily: 7}},
+		{"type", store.Account{Currency: "COIN", Type: "capped"}, store.Limits{Daily: 1000, Weekly: 5000, Hourly: 3}},
+		{"type in a currency without decimals", store.Account{Currency: "GEM", Type: "capped"}, store.Limits{Daily: 10, Weekly: 50, Hourly: 3}},
+		{"overridden", store.Account{Currency: "COIN", Type: "capped", Limits: store.Limits{Weekly: 200, Hourly: 1}}, store.Limits{Daily: 1000, Weekly: 200, Hourly: 1}},
+		{"unknown type", store.Account{Currency: "COIN", Type: "gone"}, store.Limits{}},
+	}
+	for _, tt := range tests {
+		tt.account.Id = primitive.NewObjectID()
+		got, err := limitsOf(ctx, s, &tt.account)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: limits = %+v, %v, want %+v", tt.name, got, err, tt.want)
+		}
+	}
+}
@@ -2,178 +2,476 @@ package entities
 
 import (
 	"context"
+	"errors"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/log"
 	"github.com/google/uuid"
-	"github.com/vovamod/BankAPI/utils"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/vovamod/BankAPI/money"
+	"github.com/vovamod/BankAPI/store"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"os"
+	"slices"
+	"strings"
 	"time"
 )
 
-// Create a class like obj
-type transaction struct {
-	Id     primitive.ObjectID `bson:"_id"`
-	Value  int                `bson:"value"`
-	NameTZ string             `bson:"nameTZ"`
-	Date   time.Time          `bson:"date"`
-	Status string             `bson:"status"`
-	ByWho  string             `bson:"byWho"`
-	ToWho  string             `bson:"toWho"`
-}
-type account struct {
-	Id        primitive.ObjectID `bson:"_id"`
-	Name      string             `bson:"name"`
-	Value     int                `bson:"value"`
-	AccountId string             `bson:"accountId"`
-}
-type user struct {
-	Id       primitive.ObjectID `bson:"_id"`
-	Name     string             `bson:"name"`
-	Account  []string           `bson:"account"`
-	ObjectId string             `bson:"objectId"`
-}
 type token struct {
 	Token string `json:"token"`
 }
 
+// transferBody is a transaction as clients send it, the value a decimal of the currency of the paying account:
+// ByWho, or ToWho for a negative value which charges it
+type transferBody struct {
+	store.Transaction
+	Value money.Money
+}
+
+// accountBody is an account as clients send it, the values decimals of its currency
+type accountBody struct {
+	store.Account
+	Value          money.Money
+	OverdraftLimit money.Money
+	Limits         limitsBody
+}
+
+// bankIssuer is the account every value is issued from, the only one allowed below zero
+const bankIssuer = "BANK_ISSUER"
+
+// Transaction statuses
+const (
+	statusCompleted = "Completed"
+	statusFail      = "Fail"
+)
+
+// refusal is a transfer refused for a business reason. Its text is returned to the caller as is, along with code
+// so clients can tell refusals apart without parsing the text
+type refusal struct {
+	code string
+	msg  string
+}
+
+func (r *refusal) Error() string {
+	return r.msg
+}
+
+// Transfer refusals, move returns them before writing anything
+var (
+	errNoReceiver         = &refusal{"NO_RECEIVER", "Invalid transaction. Account receiver does not exist"}
+	errNoSender           = &refusal{"NO_SENDER", "Invalid transaction. Account sender does not exist"}
+	errSelfTransfer       = &refusal{"SELF_TRANSFER", "Invalid transaction. Sender and receiver must be different accounts"}
+	errInsufficientFunds  = &refusal{"INSUFFICIENT_FUNDS", "Invalid transaction. Account of sender does not have the value required for transaction"}
+	errOverdraftExceeded  = &refusal{"OVERDRAFT_LIMIT_EXCEEDED", "Invalid transaction. It would take the account of sender beyond its overdraft limit"}
+	errOverdraftAdminOnly = &refusal{"OVERDRAFT_ADMIN_ONLY", "Invalid transaction. Only an admin may take the account of sender into overdraft"}
+	errAmountOverflow     = &refusal{"AMOUNT_OUT_OF_RANGE", "Invalid transaction. The value or the balance of receiver would be out of range"}
+)
+
+// refused reports whether err is a refusal of the transfer rather than a failure of the store
+func refused(err error) bool {
+	var r *refusal
+	return errors.As(err, &r)
+}
+
+// refusedResponse answers with the refusal err, msg replaces its text when not empty
+func refusedResponse(c *fiber.Ctx, err error, msg string) error {
+	var r *refusal
+	errors.As(err, &r)
+	if msg == "" {
+		msg = r.msg
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg, "code": r.code})
+}
+
 // Static checkID
 var chBank primitive.ObjectID
 
-// Static mongo client
-var db *mongo.Database
+// Static store, every handler goes through it
+var st store.Store
 
 // Init all operations and add them to main App via pointer
-func Init() {
-	db = utils.MongoDatabase()
-	BankInit(db, "account")
+func Init(s store.Store) {
+	st = s
+	TokenInit()
+	BankInit(s)
+	EscrowInit(s)
+	FeeInit(s)
+	LedgerInit(s)
 }
 
 // BankInit check BANK_ISSUER in system for proper Bank support at plugin site (secure enough???)
-func BankInit(db *mongo.Database, collectionName string) {
-	var ac account
-	collection := db.Collection(collectionName)
+func BankInit(s store.Store) {
 	log.Debugf("Checking if user BANK_ISSUER exists...")
-	err := collection.FindOne(context.Background(), bson.M{"name": "BANK_ISSUER"}).Decode(&ac)
-	if err != nil {
+	ac, err := s.GetAccountByName(context.Background(), bankIssuer)
+	if errors.Is(err, store.ErrNotFound) {
 		log.Info("No BANK_ISSUER exists. Creating a new BANK_ISSUER...")
-		res, errB := collection.InsertOne(context.Background(), &account{Name: "BANK_ISSUER"})
-		if errB != nil {
-			log.Errorf("Error creating BANK_ISSUER: %v", errB)
+		ac = &store.Account{Id: primitive.NewObjectID(), Name: bankIssuer, AccountId: uuid.NewString()}
+		if errB := s.CreateAccount(context.Background(), ac); errB != nil {
+			log.Fatalf("Error creating BANK_ISSUER: %v", errB)
 		}
-		log.Infof("Created BANK_ISSUER: %v", res.InsertedID)
+		log.Infof("Created BANK_ISSUER: %v", ac.Id)
+	} else if err != nil {
+		log.Fatalf("Error checking BANK_ISSUER: %v", err)
 	}
 	log.Debugf("BANK_ISSUER exists: %v. Passing to var ch_bank an ObjectID", ac)
 	chBank = ac.Id
 }
 
-// Middleware
-func withCollection(collectionName string) *mongo.Collection {
-	return db.Collection(collectionName)
+// paramID reads the route param as an ObjectID
+func paramID(c *fiber.Ctx, param string) (primitive.ObjectID, error) {
+	return primitive.ObjectIDFromHex(c.Params(param))
+}
+
+// dbError logs err and answers with the generic DB failure response
+func dbError(c *fiber.Ctx, err error) error {
+	log.Info("Error from database, Error: " + err.Error())
+	return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid data was received from DB, check logs!"})
 }
 
 // CRUD ops for InitTransactionRouter
 func CreateTransaction(c *fiber.Ctx) error {
-	collection := withCollection("transactions")
-	var t transaction
-	if err := c.BodyParser(&t); err != nil {
+	var body transferBody
+	if err := c.BodyParser(&body); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
+	t := body.Transaction
 
 	// Validation
 	if t.NameTZ == "" || t.ByWho == "" || t.ToWho == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing or invalid transaction fields"})
 	}
+	// A negative value charges ToWho, which then pays. The value is in the currency of the payer, BANK_ISSUER
+	// taking the currency of the other side
+	payer, other := t.ByWho, t.ToWho
+	if body.Value.Amount < 0 {
+		payer, other = t.ToWho, t.ByWho
+	}
+	currency := currencyByName(context.Background(), payer)
+	if currency == "" {
+		currency = currencyByName(context.Background(), other)
+	}
+	var err error
+	if t.Value, err = units(body.Value, currency); err != nil {
+		return invalidValue(c, err)
+	}
 	t.Date = time.Now()
 	t.Id = primitive.NewObjectID()
-	var toWho account
-	var byWho account
-	errA := collection.Database().Collection("accounts").FindOne(context.Background(), bson.M{"name": t.ToWho}).Decode(&toWho)
-	errB := collection.Database().Collection("accounts").FindOne(context.Background(), bson.M{"name": t.ToWho}).Decode(&toWho)
-	if errA == nil {
-		t.Status = "Fail"
-		_, _ = collection.InsertOne(context.Background(), t.Status)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid transaction. Account receiver does not exist"})
-	}
-	if errB == nil {
-		t.Status = "Fail"
-		_, _ = collection.InsertOne(context.Background(), t.Status)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid transaction. Account sender does not exist"})
-	}
-
-	// Actual logic here thou\
-	// If negative value shows up
-	if t.Value < 0 && toWho.Value >= t.Value {
-		result, _ := collection.InsertOne(context.Background(), t)
-		_, _ = collection.Database().Collection("accounts").UpdateOne(context.Background(), bson.M{"name": byWho}, bson.M{"$set": byWho.Value + t.Value})
-		_, _ = collection.Database().Collection("accounts").UpdateOne(context.Background(), bson.M{"name": t.ToWho}, bson.M{"$set": toWho.Value - t.Value})
-		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": "Account has been charged", "data": result})
-	}
-	// If positive value shows up
-	if t.Value >= 0 && byWho.Value >= t.Value {
-		_, _ = collection.Database().Collection("accounts").UpdateOne(context.Background(), bson.M{"name": t.ToWho}, bson.M{"$set": toWho.Value + t.Value})
-		_, _ = collection.Database().Collection("accounts").UpdateOne(context.Background(), bson.M{"name": byWho}, bson.M{"$set": byWho.Value - t.Value})
-		result, _ := collection.InsertOne(context.Background(), t)
-		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": "Account has received value", "data": result})
-	}
-	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid transaction. Maybe Account of receiver or sender does not have the value required for transaction"})
+	t.Currency = currency
+	t.Type = typeTransfer
+	t.OriginalId = primitive.NilObjectID
+	t.Refunded = 0
+	t.ToCurrency = strings.ToUpper(t.ToCurrency)
+	t.ToValue, t.Rate, t.RateId = 0, "", primitive.NilObjectID
+
+	as := asUser
+	if can(c, "accounts:overdraw") {
+		as = asAdmin
+	}
+	// Only the bank charges others or pays from BANK_ISSUER
+	if t.Value < 0 && !can(c, "transactions:issue") {
+		return ownerResponse(c, errPlayerCharge)
+	}
+	if err := checkIssuer(c, payer); err != nil {
+		return ownerResponse(c, err)
+	}
+	// Players only pay from their own accounts
+	u, err := playerOf(c)
+	if err == nil && u != nil {
+		if t.Value <= 0 {
+			err = errPlayerCharge
+		} else {
+			err = checkOwner(context.Background(), u, t.ByWho)
+		}
+	}
+	if err != nil {
+		return ownerResponse(c, err)
+	}
+
+	// Actual logic here thou
+	if err := transfer(context.Background(), &t, as); err != nil {
+		// Keep a record of the failed attempt. It is written outside the rolled back transaction on purpose
+		t.Status = statusFail
+		if errI := st.CreateTransaction(context.Background(), &t); errI != nil {
+			log.Errorf("Failed to record failed transaction %v: %v", t.Id, errI)
+		}
+		if refused(err) {
+			return refusedResponse(c, err, "")
+		}
+		log.Errorf("Transaction %v failed: %v", t.Id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Transaction failed and has been rolled back"})
+	}
+	if t.Status == statusPendingReview {
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"success": "Transaction is pending review by an admin", "data": t})
+	}
+	if t.Value < 0 {
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": "Account has been charged", "data": t})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": "Account has received value", "data": t})
+}
 
+// transfer moves t.Value from ByWho to ToWho (a negative value charges ToWho instead) through the ledger
+// and inserts t, all inside one store transaction so balances and the record commit or roll back together.
+func transfer(ctx context.Context, t *store.Transaction, as authority) error {
+	return st.WithTx(ctx, func(ctx context.Context, s store.Store) error {
+		return move(ctx, s, t, as)
+	})
+}
+
+// move is the body of transfer, s must come from WithTx. as decides how far the paying account may go below zero.
+// A refusal is returned before anything is written, so callers may record it and still commit
+func move(ctx context.Context, s store.Store, t *store.Transaction, as authority) error {
+	from, to, value, err := parties(ctx, s, t)
+	if err != nil {
+		return err
+	}
+	// Fees are charged to the payer on top of the value, in its currency
+	if err := chargeFees(ctx, s, t, from, value); err != nil {
+		return err
+	}
+	if as != forced {
+		if err := checkSpending(ctx, s, t, from, value); err != nil {
+			return err
+		}
+		review, err := checkFraud(ctx, s, t, from, to, value)
+		if err != nil {
+			return err
+		}
+		if review {
+			// Nothing moves until an admin approves it, the conversion is worked out then
+			t.Status, t.Currency = statusPendingReview, currencyOf(from)
+			return s.CreateTransaction(ctx, t)
+		}
+	}
+	return settle(ctx, s, t, from, to, value, as, s.CreateTransaction)
+}
+
+// parties loads the accounts of t and returns the one paying value and the one receiving it
+func parties(ctx context.Context, s store.Store, t *store.Transaction) (from, to *store.Account, value int, err error) {
+	byWho, err := s.GetAccountByName(ctx, t.ByWho)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil, 0, errNoSender
+	}
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	toWho, err := s.GetAccountByName(ctx, t.ToWho)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil, 0, errNoReceiver
+	}
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if byWho.Id == toWho.Id {
+		return nil, nil, 0, errSelfTransfer
+	}
+
+	if (byWho.Id == chEscrow || toWho.Id == chEscrow) && t.Type != typeEscrow && t.Type != typeEscrowRelease && t.Type != typeEscrowRefund {
+		return nil, nil, 0, errEscrowAccount
+	}
+	// A closing account sweeps its balance out whatever its state
+	if t.Type != typeSweep {
+		if err := checkActive(byWho); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+	if err := checkActive(toWho); err != nil {
+		return nil, nil, 0, err
+	}
+
+	if t.Value < 0 {
+		return toWho, byWho, -t.Value, nil
+	}
+	return byWho, toWho, t.Value, nil
+}
+
+// settle moves value from from to to and the fees of t to the fee accounts, saving t completed with save
+// (CreateTransaction, or UpdateTransaction for a transaction already recorded) before posting it
+func settle(ctx context.Context, s store.Store, t *store.Transaction, from, to *store.Account, value int, as authority,
+	save func(context.Context, *store.Transaction) error) error {
+	total := int64(value)
+	for _, f := range t.Fees {
+		var err error
+		if total, err = money.Add(total, int64(f.Value)); err != nil {
+			return errAmountOverflow
+		}
+	}
+	if err := checkFunds(from, int(total), as); err != nil {
+		return err
+	}
+	credit, err := convert(ctx, s, t, from, to, value)
+	if err != nil {
+		return err
+	}
+	if _, err := money.Add(int64(to.Value), int64(credit)); err != nil {
+		return errAmountOverflow
+	}
+
+	t.Status = statusCompleted
+	if err := save(ctx, t); err != nil {
+		return err
+	}
+	legs := []leg{{from.Id, -value}, {to.Id, value}}
+	if t.ToCurrency != "" {
+		// Conversions go through BANK_ISSUER, which takes one currency and gives the other
+		legs = []leg{{from.Id, -value}, {chBank, value}, {chBank, -credit}, {to.Id, credit}}
+	}
+	for _, f := range t.Fees {
+		legs = append(legs, leg{from.Id, -f.Value}, leg{feeAccount(f.Kind), f.Value})
+	}
+	return post(ctx, s, t, legs...)
 }
 func GetAllTransactions(c *fiber.Ctx) error {
-	_, err := GetAll[transaction](c, withCollection("transactions"))
-	return err
+	var f store.TransactionFilter
+	var err error
+	f.ByWho, f.ToWho, f.Account, f.Status = c.Query("byWho"), c.Query("toWho"), c.Query("account"), c.Query("status")
+	if f.From, err = queryTime(c, "from"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from date, expected RFC 3339"})
+	}
+	if f.To, err = queryTime(c, "to"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to date, expected RFC 3339"})
+	}
+	// Players list the transactions of one of their accounts at a time
+	u, err := playerOf(c)
+	if err == nil && u != nil {
+		if f.Account == "" {
+			err = errNoAccount
+		} else {
+			err = checkOwner(context.Background(), u, f.Account)
+		}
+	}
+	if err != nil {
+		return ownerResponse(c, err)
+	}
+	return GetAll(c, st.ListTransactions, f)
 }
 func GetTransactionByID(c *fiber.Ctx) error {
-	return GetByID[transaction](c, withCollection("transactions"))
+	return GetByID(c, st.GetTransaction)
 }
 
 // CRUD ops for InitAccountRouter
 func CreateAccount(c *fiber.Ctx) error {
-	var a account
-	collection := withCollection("account")
-	if err := c.BodyParser(&a); err != nil {
+	var body accountBody
+	if err := c.BodyParser(&body); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
+	a := body.Account
 
 	// Validation
 	if a.Name == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing Name of the Account"})
 	}
+	if a.Currency == "" {
+		a.Currency = defaultCurrency()
+	}
+	if !checkCurrency(&a.Currency) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid currency code, expected 2 to 10 letters or digits"})
+	}
+	var err error
+	if a.Value, err = units(body.Value, a.Currency); err != nil {
+		return invalidValue(c, err)
+	}
+	if a.OverdraftLimit, err = units(body.OverdraftLimit, a.Currency); err != nil {
+		return invalidValue(c, err)
+	}
+	if a.Limits, err = body.Limits.read(accountUnits(&a)); err != nil {
+		return invalidValue(c, err)
+	}
+	if a.Value < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Value of a new Account cannot be negative"})
+	}
+	if msg := checkLimits(a.Limits); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	if msg := checkOverdraft(&a); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	if a.Type != "" {
+		if _, err := st.GetAccountTypeByName(context.Background(), a.Type); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid account type provided"})
+		}
+	}
+	if a.Overdraft == "" {
+		a.Overdraft = overdraftNone
+	}
+	if a.Status == "" {
+		a.Status = accountActive
+	}
+	if a.Status != accountActive && a.Status != accountPending {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Status of a new Account must be active or pending"})
+	}
 	a.AccountId = uuid.NewString()
 	a.Id = primitive.NewObjectID()
-	var duplicate account
-	err := collection.FindOne(context.Background(), bson.M{"name": a.Name}).Decode(&duplicate)
-	if err == nil {
+
+	// Actual logic here thou. The balance only ever comes from the ledger, the given value is funded by BANK_ISSUER
+	value := a.Value
+	a.Value = 0
+	err = st.WithTx(context.Background(), func(ctx context.Context, s store.Store) error {
+		if err := s.CreateAccount(ctx, &a); err != nil {
+			return err
+		}
+		if err := openAccrual(ctx, s, &a); err != nil {
+			return err
+		}
+		if value == 0 {
+			return nil
+		}
+		return fund(ctx, s, &a, value)
+	})
+	if errors.Is(err, store.ErrDuplicate) {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid Account name provided. This account name is already taken"})
 	}
-
-	// Actual logic here thou
-	result, err1 := collection.InsertOne(context.Background(), a)
-	log.Info(err1)
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": "Account has been created", "id": result.InsertedID})
+	if err != nil {
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": "Account has been created", "id": a.Id})
 }
+
+// GetAllAccount lists every account to the bank and their own ones to players
 func GetAllAccount(c *fiber.Ctx) error {
-	_, err := GetAll[account](c, withCollection("account"))
-	return err
+	f := store.AccountFilter{NamePrefix: c.Query("name"), Type: c.Query("type")}
+	u, err := playerOf(c)
+	if err != nil {
+		return ownerResponse(c, err)
+	}
+	if u != nil {
+		for _, account := range u.Account {
+			if id, err := primitive.ObjectIDFromHex(account); err == nil {
+				f.Ids = append(f.Ids, id)
+			}
+		}
+		if len(f.Ids) == 0 {
+			return c.Status(fiber.StatusOK).JSON(store.Result[store.Account]{Data: []store.Account{}})
+		}
+	}
+	return GetAll(c, st.ListAccounts, f)
 }
 func GetAccountByID(c *fiber.Ctx) error {
-	return GetByID[account](c, withCollection("account"))
+	return GetByID(c, liveAccount)
 }
-func DeleteAccountByID(c *fiber.Ctx) error {
-	id := c.Params("id")
-	collection := withCollection("account")
 
-	err := collection.FindOne(context.Background(), bson.M{"_id": id})
+// DeleteAccountByID deletes an account softly, closing it first so it must be empty. It is kept for its history
+// until the retention job purges it. Use close with sweepTo for one with a balance
+func DeleteAccountByID(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
 	if err != nil {
 		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
 	}
-	_, errD := collection.DeleteOne(context.Background(), bson.M{"_id": id})
-	if errD != nil {
-		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID or DB failed?"})
-	}
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Account deleted successfully"})
+	var a *store.Account
+	err = st.WithTx(context.Background(), func(ctx context.Context, s store.Store) error {
+		var err error
+		if a, err = s.GetAccount(ctx, id); err != nil {
+			return err
+		}
+		if !a.DeletedAt.IsZero() {
+			return store.ErrNotFound
+		}
+		if stateOf(a) != accountClosed {
+			if a, err = closeAccount(ctx, s, id, ""); err != nil {
+				return err
+			}
+		}
+		a.DeletedAt, a.DeletedBy = time.Now(), caller(c)
+		return s.UpdateAccount(ctx, a)
+	})
+	return stateResponse(c, a, err, "Account deleted successfully")
 }
 
 // TODO: Understand why I wrote such a bad code and why I wanted THAT in the first place!
@@ -228,9 +526,7 @@ func DeleteAccountByID(c *fiber.Ctx) error {
 
 // CRUD ops for InitUserRouter
 func CreateUser(c *fiber.Ctx) error {
-	var u user
-	var acc account
-	collection := withCollection("user")
+	var u store.User
 	if err := c.BodyParser(&u); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
@@ -240,58 +536,122 @@ func CreateUser(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing Name or ObjectId"})
 	}
 	// add an objectid check since we will pass to it [16]UUID.string obj
-	var duplicate user
-	err := collection.FindOne(context.Background(), bson.M{"name": u.Name}).Decode(&duplicate)
+	_, err := st.GetUserByName(context.Background(), u.Name)
 	if err == nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid username provided. This username is already taken"})
 	}
-	// check for account
-	acID, _ := primitive.ObjectIDFromHex(c.Params("account"))
-	errB := db.Collection("account").FindOne(context.Background(), bson.M{"_id": acID}).Decode(&acc)
-	if errB == nil {
+	// check for account, it must exist and must not be linked to someone else
+	acID, err := paramID(c, "account")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid account provided"})
+	}
+	switch err := checkLinkable(context.Background(), st, acID, primitive.NilObjectID); {
+	case errors.Is(err, errLinkedAccount):
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid account provided. You cannot create a new user with linked account"})
+	case err != nil:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid account provided"})
 	}
 
 	// Actual logic here thou
 	u.Id = primitive.NewObjectID()
-	u.Account = append(u.Account, c.Params("account"))
-	result, _ := collection.InsertOne(context.Background(), u)
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": "User has been created", "id": result.InsertedID})
+	u.Account = append(u.Account, acID.Hex())
+	err = st.CreateUser(context.Background(), &u)
+	if errors.Is(err, store.ErrDuplicate) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid username provided. This username is already taken"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": "User has been created", "id": u.Id})
 }
 func GetAllUsers(c *fiber.Ctx) error {
-	_, err := GetAll[user](c, withCollection("user"))
-	return err
+	// Players only see themselves
+	u, err := playerOf(c)
+	if err != nil {
+		return ownerResponse(c, err)
+	}
+	if u != nil {
+		return c.Status(fiber.StatusOK).JSON(store.Result[store.User]{Data: []store.User{*u}})
+	}
+	return GetAll(c, st.ListUsers, store.UserFilter{NamePrefix: c.Query("name")})
 }
 func GetUserByID(c *fiber.Ctx) error {
-	return GetByID[user](c, withCollection("user"))
+	return GetByID(c, liveUser)
 }
-func DeleteUserByID(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var u user
-	collection := withCollection("user")
 
-	err := collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&u)
+// DeleteUserByID deletes a user softly once all its accounts are closed, until the retention job purges it
+func DeleteUserByID(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
 	if err != nil {
 		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid user ID provided"})
 	}
-	for _, account := range u.Account {
-		if account != "" {
-			return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "User has an account ID provided. User will not be deleted unless his account has been deleted"})
+	err = st.WithTx(context.Background(), func(ctx context.Context, s store.Store) error {
+		u, err := s.GetUser(ctx, id)
+		if err != nil {
+			return err
 		}
-	}
-	_, errD := collection.DeleteOne(context.Background(), bson.M{"_id": id})
-	if errD != nil {
-		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid user ID or DB failed?"})
+		if !u.DeletedAt.IsZero() {
+			return store.ErrNotFound
+		}
+		for _, account := range u.Account {
+			accountID, err := primitive.ObjectIDFromHex(account)
+			if err != nil {
+				continue
+			}
+			a, err := s.GetAccount(ctx, accountID)
+			if errors.Is(err, store.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if stateOf(a) != accountClosed {
+				return errUserAccounts
+			}
+		}
+		u.DeletedAt, u.DeletedBy = time.Now(), caller(c)
+		return s.UpdateUser(ctx, u)
+	})
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid user ID provided"})
+	case errors.Is(err, errUserAccounts):
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return dbError(c, err)
 	}
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User deleted successfully"})
 }
+
+// Accounts linked to a user must exist and must not be linked to someone else
+var (
+	errLinkAccount   = errors.New("Invalid account provided")
+	errLinkedAccount = errors.New("Invalid account provided. It is linked to another user")
+)
+
+// checkLinkable refuses linking the account to the user unless it exists and is linked to nobody else
+func checkLinkable(ctx context.Context, s store.Store, account, user primitive.ObjectID) error {
+	a, err := s.GetAccount(ctx, account)
+	if errors.Is(err, store.ErrNotFound) || err == nil && !a.DeletedAt.IsZero() {
+		return errLinkAccount
+	}
+	if err != nil {
+		return err
+	}
+	owner, err := s.GetUserByAccount(ctx, account.Hex())
+	if err == nil && owner.Id != user {
+		return errLinkedAccount
+	}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// UpdateUserByID links the accounts of the body to the user, those already linked to it are left as they are
 func UpdateUserByID(c *fiber.Ctx) error {
-	var u user
-	var uu user
-	collection := withCollection("user")
-	id := c.Params("id")
-	// Validate if the ID is a valid ObjectID
-	err := collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&u)
+	var uu store.User
+	id, err := paramID(c, "id")
 	if err != nil {
 		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid user ID provided"})
 	}
@@ -301,28 +661,45 @@ func UpdateUserByID(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
 
-	update := bson.M{
-		"$set": bson.M{
-			"account": append(u.Account, uu.Account...),
-		},
-	}
-
-	filter := bson.M{"_id": u}
-	result, err := collection.UpdateOne(context.Background(), filter, update)
-	if err != nil {
+	// The accounts are checked inside the transaction so two users cannot take the same one
+	var u *store.User
+	err = st.WithTx(context.Background(), func(ctx context.Context, s store.Store) error {
+		var err error
+		if u, err = s.GetUser(ctx, id); err != nil {
+			return err
+		}
+		if !u.DeletedAt.IsZero() {
+			return store.ErrNotFound
+		}
+		for _, account := range uu.Account {
+			acID, err := primitive.ObjectIDFromHex(account)
+			if err != nil {
+				return errLinkAccount
+			}
+			if err := checkLinkable(ctx, s, acID, u.Id); err != nil {
+				return err
+			}
+			if !slices.Contains(u.Account, acID.Hex()) {
+				u.Account = append(u.Account, acID.Hex())
+			}
+		}
+		return s.UpdateUser(ctx, u)
+	})
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid user ID provided"})
+	case errors.Is(err, errLinkAccount):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, errLinkedAccount):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
 		log.Errorf("Failed to update user: %v", err)
 		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Failed to update user"})
 	}
 
-	// Check if an account was updated
-	if result.MatchedCount == 0 {
-		// Rare cases
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
-	}
-
-	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "User updated successfully",
-		"updated": uu,
+		"updated": u,
 	})
 }
 
@@ -337,66 +714,48 @@ func AuthBank(c *fiber.Ctx) error {
 	if sToken != t.Token {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid key provided"})
 	}
-	token, err := utils.GenerateToken(chBank.String(), "BANK_ISSUER", 72)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to generate token",
-		})
-	}
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"token": token,
-	})
+	return issueTokens(c, chBank.Hex(), bankRole, "")
 }
 func CheckToken(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"auth": "success"})
 }
 
-func GetAll[T any](c *fiber.Ctx, collection *mongo.Collection) ([]T, error) {
-	var results []T
-
-	// Perform a find operation on the collection
-	cursor, err := collection.Find(context.Background(), bson.M{})
-	if err != nil {
-		log.Info("Error retrieving documents from database, Error: " + err.Error())
-		return nil, c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid data was received from DB, check logs!"})
+// GetAll answers with one page of what list returns for filter f. The page comes from the query:
+// limit, cursor (next of the previous page), sort (a field name) and order (asc or desc)
+func GetAll[T any, F any](c *fiber.Ctx, list func(ctx context.Context, f F, p store.Page) (*store.Result[T], error), f F) error {
+	p := store.Page{
+		Limit:  c.QueryInt("limit", store.DefaultLimit),
+		Cursor: c.Query("cursor"),
+		Sort:   c.Query("sort"),
+		Desc:   c.Query("order") == "desc",
 	}
-	// Ensure the cursor is closed after processing
-	defer func(cursor *mongo.Cursor, ctx context.Context) {
-		err := cursor.Close(ctx)
-		if err != nil {
-			log.Info("Error closing Mongo cursor, Error: " + err.Error())
-		}
-	}(cursor, context.Background())
-
-	// Decode each document into the result slice
-	for cursor.Next(context.Background()) {
-		var item T
-		if err := cursor.Decode(&item); err != nil {
-			log.Info("Error decoding document from database, Error: " + err.Error())
-			return nil, c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid data was received from DB, check logs!"})
-		}
-		results = append(results, item)
+	results, err := list(context.Background(), f, p)
+	if errors.Is(err, store.ErrInvalidPage) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid sort or cursor provided"})
 	}
-
-	// Check if there were any errors during cursor iteration
-	if err := cursor.Err(); err != nil {
-		log.Info("Error iterating over cursor, Error: " + err.Error())
-		return nil, c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid data was received from DB, check logs!"})
+	if err != nil {
+		return dbError(c, err)
 	}
+	// Return the results as JSON
+	return c.Status(fiber.StatusOK).JSON(results)
+}
 
-	// Check if context provided
-	if c == nil {
-		return results, nil
+// queryTime parses an optional RFC 3339 query parameter
+func queryTime(c *fiber.Ctx, key string) (time.Time, error) {
+	if c.Query(key) == "" {
+		return time.Time{}, nil
 	}
-
-	// Return the results as JSON
-	return nil, c.Status(fiber.StatusOK).JSON(results)
+	return time.Parse(time.RFC3339, c.Query(key))
 }
-func GetByID[T any](c *fiber.Ctx, collection *mongo.Collection) error {
-	var result T
-	id, _ := primitive.ObjectIDFromHex(c.Params("id"))
-	err := collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&result)
-	if err == nil {
+
+// GetByID answers with the record get finds for the :id route param
+func GetByID[T any](c *fiber.Ctx, get func(ctx context.Context, id primitive.ObjectID) (*T, error)) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	result, err := get(context.Background(), id)
+	if err != nil {
 		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
 	}
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"data": result})
@@ -0,0 +1,246 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/vovamod/BankAPI/money"
+	"github.com/vovamod/BankAPI/store"
+	"github.com/vovamod/BankAPI/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Standing order statuses
+const (
+	orderActive    = "active"
+	orderCompleted = "completed"
+	orderCancelled = "cancelled"
+)
+
+const (
+	// maxOrderRetries failed attempts of a run are retried, after that the run is missed and the order moves on
+	maxOrderRetries = 3
+	// orderRetryDelay grows with every retry: 10, 20 then 30 minutes
+	orderRetryDelay = 10 * time.Minute
+	// dueOrdersBatch bounds how many orders one scheduler tick executes
+	dueOrdersBatch   = 100
+	minOrderInterval = time.Minute
+)
+
+// orderBody is a standing order as clients send it, the value a decimal of the currency of its accounts
+type orderBody struct {
+	store.StandingOrder
+	Value money.Money
+}
+
+// nextRun returns the first run of o strictly after t, never before its start. Zero when there is none
+func nextRun(o *store.StandingOrder, t time.Time) (time.Time, error) {
+	if o.Interval != "" {
+		every, err := time.ParseDuration(o.Interval)
+		if err != nil || every < minOrderInterval {
+			return time.Time{}, errors.New("Invalid interval provided, it must be a duration of at least 1m like 1h or 24h")
+		}
+		if t.Before(o.StartAt) {
+			return o.StartAt, nil
+		}
+		return o.StartAt.Add((t.Sub(o.StartAt)/every + 1) * every), nil
+	}
+	cron, err := utils.ParseCron(o.Cron)
+	if err != nil {
+		return time.Time{}, errors.New("Invalid cron provided: " + err.Error())
+	}
+	if t.Before(o.StartAt) {
+		t = o.StartAt.Add(-time.Nanosecond)
+	}
+	return cron.Next(t), nil
+}
+
+// advance moves o to its next run after now, completing it once it is over
+func advance(o *store.StandingOrder, now time.Time) {
+	next, err := nextRun(o, now)
+	if err != nil || next.IsZero() || (o.MaxRuns > 0 && o.Runs >= o.MaxRuns) || (!o.EndAt.IsZero() && next.After(o.EndAt)) {
+		o.Status = orderCompleted
+		return
+	}
+	o.NextRun = next
+}
+
+// CRUD ops for standing orders
+func CreateStandingOrder(c *fiber.Ctx) error {
+	var body orderBody
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	o := body.StandingOrder
+
+	// Validation
+	if o.Name == "" || o.ByWho == "" || o.ToWho == "" || body.Value.Amount <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing or invalid standing order fields"})
+	}
+	if o.ByWho == o.ToWho {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Payer and payee of a standing order must differ"})
+	}
+	if (o.Interval == "") == (o.Cron == "") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Provide either an interval or a cron schedule"})
+	}
+	if err := checkIssuer(c, o.ByWho); err != nil {
+		return ownerResponse(c, err)
+	}
+	var currencies []string
+	for _, name := range []string{o.ByWho, o.ToWho} {
+		a, err := st.GetAccountByName(context.Background(), name)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid standing order. Account " + name + " does not exist"})
+		}
+		currencies = append(currencies, currencyOf(a))
+	}
+	if currencies[0] != "" && currencies[1] != "" && currencies[0] != currencies[1] {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid standing order. Accounts hold different currencies"})
+	}
+	o.Currency = currencies[0]
+	if o.Currency == "" {
+		o.Currency = currencies[1]
+	}
+	var err error
+	if o.Value, err = units(body.Value, o.Currency); err != nil {
+		return invalidValue(c, err)
+	}
+	if o.StartAt.IsZero() {
+		o.StartAt = time.Now()
+	}
+	first, err := nextRun(&o, o.StartAt.Add(-time.Nanosecond))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if first.IsZero() || (!o.EndAt.IsZero() && first.After(o.EndAt)) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid standing order. Its schedule never runs"})
+	}
+	o.Id = primitive.NewObjectID()
+	o.NextRun = first
+	o.Status = orderActive
+	o.Runs, o.Retries, o.Missed, o.LastError = 0, 0, 0, ""
+
+	// Actual logic here thou
+	if err := st.CreateStandingOrder(context.Background(), &o); err != nil {
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": "Standing order has been created", "data": o})
+}
+func GetAllStandingOrders(c *fiber.Ctx) error {
+	return GetAll(c, func(ctx context.Context, _ struct{}, p store.Page) (*store.Result[store.StandingOrder], error) {
+		return st.ListStandingOrders(ctx, p)
+	}, struct{}{})
+}
+func GetStandingOrderByID(c *fiber.Ctx) error {
+	return GetByID(c, st.GetStandingOrder)
+}
+func GetStandingOrderRuns(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	return GetAll(c, st.ListStandingOrderRuns, id)
+}
+
+// CancelStandingOrder stops the order, its runs stay for history
+func CancelStandingOrder(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	err = st.WithTx(context.Background(), func(ctx context.Context, s store.Store) error {
+		o, err := s.GetStandingOrder(ctx, id)
+		if err != nil {
+			return err
+		}
+		if o.Status != orderActive {
+			return errOrderNotActive
+		}
+		o.Status = orderCancelled
+		return s.UpdateStandingOrder(ctx, o)
+	})
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	case errors.Is(err, errOrderNotActive):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Standing order cancelled successfully"})
+}
+
+var errOrderNotActive = errors.New("Standing order is not active anymore")
+
+// RunStandingOrders executes every due standing order, the scheduler calls it on every tick
+func RunStandingOrders(ctx context.Context, now time.Time) error {
+	due, err := st.DueStandingOrders(ctx, orderActive, now, dueOrdersBatch)
+	if err != nil {
+		return err
+	}
+	for _, o := range due {
+		if err := executeOrder(ctx, o.Id, now); err != nil {
+			log.Errorf("Standing order %v failed and will be tried again: %v", o.Id, err)
+		}
+	}
+	return nil
+}
+
+// executeOrder runs the order through the same transfer as CreateTransaction. The order is read again inside the
+// transaction so a run is never executed twice, even with several server processes
+func executeOrder(ctx context.Context, id primitive.ObjectID, now time.Time) error {
+	return st.WithTx(ctx, func(ctx context.Context, s store.Store) error {
+		o, err := s.GetStandingOrder(ctx, id)
+		if err != nil {
+			return err
+		}
+		if o.Status != orderActive || o.NextRun.After(now) {
+			return nil
+		}
+		t := &store.Transaction{
+			Id:       primitive.NewObjectID(),
+			Value:    o.Value,
+			NameTZ:   o.Name,
+			Date:     now,
+			ByWho:    o.ByWho,
+			ToWho:    o.ToWho,
+			Type:     typeTransfer,
+			Currency: o.Currency,
+		}
+		run := &store.StandingOrderRun{Id: primitive.NewObjectID(), OrderId: o.Id, TransactionId: t.Id, Date: now}
+
+		err = move(ctx, s, t, asUser)
+		switch {
+		case err == nil:
+			// Completed, or PendingReview when held by a fraud rule
+			run.Status = t.Status
+			o.Runs++
+			o.Retries, o.LastError = 0, ""
+			advance(o, now)
+		case refused(err):
+			// Nothing was written by move, keep the failed attempt like CreateTransaction does
+			t.Status = statusFail
+			if err := s.CreateTransaction(ctx, t); err != nil {
+				return err
+			}
+			run.Status, run.Error = statusFail, err.Error()
+			o.LastError = err.Error()
+			if o.Retries < maxOrderRetries {
+				o.Retries++
+				o.NextRun = now.Add(orderRetryDelay * time.Duration(o.Retries))
+			} else {
+				o.Retries = 0
+				o.Missed++
+				advance(o, now)
+			}
+		default:
+			return err
+		}
+		if err := s.CreateStandingOrderRun(ctx, run); err != nil {
+			return err
+		}
+		return s.UpdateStandingOrder(ctx, o)
+	})
+}
@@ -0,0 +1,137 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/vovamod/BankAPI/money"
+	"github.com/vovamod/BankAPI/store"
+	"time"
+)
+
+// Overdraft policies, accounts made before policies existed have an empty one which is the same as none
+const (
+	overdraftNone  = "none"
+	overdraftLimit = "limit"
+	overdraftAdmin = "admin"
+)
+
+// authority is how far a transfer may take the paying account below zero
+type authority int
+
+const (
+	// asUser stays within the limit of the limit policy
+	asUser authority = iota
+	// asAdmin stays within the limit of the limit and admin policies
+	asAdmin
+	// forced is not checked at all, for admins forcing a reversal
+	forced
+)
+
+// checkFunds refuses paying value from what is available on the account (its balance less its holds) when
+// its overdraft policy does not allow it. BANK_ISSUER issues every value and is never refused
+func checkFunds(from *store.Account, value int, as authority) error {
+	after := from.Value - from.Held - value
+	if as == forced || from.Id == chBank || after >= 0 {
+		return nil
+	}
+	switch from.Overdraft {
+	case overdraftLimit:
+	case overdraftAdmin:
+		if as != asAdmin {
+			return errOverdraftAdminOnly
+		}
+	default:
+		return errInsufficientFunds
+	}
+	if after < -from.OverdraftLimit {
+		return errOverdraftExceeded
+	}
+	return nil
+}
+
+// checkOverdraft returns what is wrong with the overdraft settings of a, empty when they are valid
+func checkOverdraft(a *store.Account) string {
+	switch {
+	case a.Overdraft != "" && a.Overdraft != overdraftNone && a.Overdraft != overdraftLimit && a.Overdraft != overdraftAdmin:
+		return "Overdraft of an account must be none, limit or admin"
+	case a.OverdraftLimit < 0:
+		return "Overdraft limit of an account cannot be negative"
+	case a.OverdraftRate < 0:
+		return "Overdraft rate of an account cannot be negative"
+	}
+	return ""
+}
+
+type overdraft struct {
+	Overdraft      string      `json:"overdraft"`
+	OverdraftLimit money.Money `json:"overdraftLimit"`
+	OverdraftRate  int         `json:"overdraftRate"`
+}
+
+// UpdateOverdraftByID sets the overdraft policy, limit and rate of the account. A lower limit does not touch
+// an account already beyond it, it only refuses further transfers
+func UpdateOverdraftByID(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
+	}
+	var body overdraft
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	// The limit is in the currency of the account, which never changes so it can be read ahead
+	a, err := st.GetAccount(context.Background(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	settings := store.Account{Overdraft: body.Overdraft, OverdraftRate: body.OverdraftRate}
+	if settings.OverdraftLimit, err = units(body.OverdraftLimit, a.Currency); err != nil {
+		return invalidValue(c, err)
+	}
+	if msg := checkOverdraft(&settings); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	err = st.WithTx(context.Background(), func(ctx context.Context, s store.Store) error {
+		var err error
+		if a, err = s.GetAccount(ctx, id); err != nil {
+			return err
+		}
+		if a.Id == chBank {
+			return errBankOverdraft
+		}
+		a.Overdraft, a.OverdraftLimit, a.OverdraftRate = settings.Overdraft, settings.OverdraftLimit, settings.OverdraftRate
+		if err := s.UpdateAccount(ctx, a); err != nil {
+			return err
+		}
+		return openAccrual(ctx, s, a)
+	})
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid account ID provided"})
+	case errors.Is(err, errBankOverdraft):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Overdraft updated successfully", "data": a})
+}
+
+var errBankOverdraft = errors.New("BANK_ISSUER has no overdraft, it may always go below zero")
+
+// openAccrual starts the interest bookkeeping of a when it earns or pays interest and has none yet. s must come from WithTx
+func openAccrual(ctx context.Context, s store.Store, a *store.Account) error {
+	if a.Type == "" && a.OverdraftRate == 0 {
+		return nil
+	}
+	_, err := s.GetAccrual(ctx, a.Id)
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	// Interest accrues from the day it is opened
+	today := day(time.Now())
+	return s.CreateAccrual(ctx, &store.Accrual{Account: a.Id, Through: today, Due: today})
+}
@@ -0,0 +1,75 @@
+package entities
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"slices"
+	"strings"
+)
+
+// Roles of tokens. BANK_ISSUER is the bank itself, logged in with BANK_256_CODE, the others get their tokens from it
+const (
+	bankRole    = "BANK_ISSUER"
+	adminRole   = "ADMIN"
+	serverRole  = "SERVER"
+	auditorRole = "AUDITOR"
+)
+
+// Permissions are everything a caller may be allowed to do, API keys holding a pick of them as scopes
+var Permissions = []string{
+	"transactions:create", "transactions:read", "transactions:write", "transactions:force", "transactions:issue",
+	"accounts:read", "accounts:write", "accounts:overdraw",
+	"users:read", "users:write",
+	"challenges:create",
+	"holds:read", "holds:write",
+	"escrows:read", "escrows:write", "escrows:settle",
+	"rates:read", "rates:write",
+	"fees:read", "fees:write",
+	"fraud:read", "fraud:write",
+	"orders:read", "orders:write",
+	"keys:manage",
+	"tokens:manage", "tokens:issue",
+}
+
+// roles grants each role its permissions. Players only use theirs on what they own, see OwnOnly
+var roles = map[string][]string{
+	bankRole:  Permissions,
+	adminRole: without(Permissions, "tokens:issue", "transactions:issue"),
+	serverRole: {
+		"transactions:create", "transactions:read",
+		"accounts:read", "accounts:write",
+		"users:read", "users:write",
+		"challenges:create",
+		"holds:read", "holds:write",
+		"escrows:read", "escrows:write",
+		"rates:read",
+		"orders:read", "orders:write",
+	},
+	auditorRole: readOnly(Permissions),
+	userRole:    {"transactions:create", "transactions:read", "accounts:read", "users:read"},
+}
+
+// without is perms less drop
+func without(perms []string, drop ...string) []string {
+	return slices.DeleteFunc(slices.Clone(perms), func(p string) bool { return slices.Contains(drop, p) })
+}
+
+// readOnly is the read permissions of perms
+func readOnly(perms []string) []string {
+	return slices.DeleteFunc(slices.Clone(perms), func(p string) bool { return !strings.HasSuffix(p, ":read") })
+}
+
+// PermissionsOf is what a token of role may do, nothing for a role it does not know
+func PermissionsOf(role string) []string {
+	return roles[role]
+}
+
+// OwnOnly tells whether role only reaches the records it owns, the routes it may use checking who owns what
+func OwnOnly(role string) bool {
+	return role == userRole
+}
+
+// can tells whether the caller was granted permission, by the role of its token or the scopes of its API key
+func can(c *fiber.Ctx, permission string) bool {
+	permissions, _ := c.Locals("permissions").([]string)
+	return slices.Contains(permissions, permission)
+}
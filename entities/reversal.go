@@ -0,0 +1,180 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/vovamod/BankAPI/money"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math/big"
+	"time"
+)
+
+// Transaction types, transfers made before types existed have an empty one
+const (
+	typeTransfer      = "transfer"
+	typeReversal      = "reversal"
+	typeRefund        = "refund"
+	typeInterest      = "interest"
+	typeOverdraft     = "overdraft"
+	typeEscrow        = "escrow"
+	typeEscrowRelease = "escrowRelease"
+	typeEscrowRefund  = "escrowRefund"
+	typeSweep         = "sweep"
+)
+
+// Statuses of a transfer that has been given back
+const (
+	statusReversed          = "Reversed"
+	statusRefunded          = "Refunded"
+	statusPartiallyRefunded = "PartiallyRefunded"
+)
+
+var (
+	errNotReversible  = errors.New("Invalid transaction. Only completed transfers can be reversed or refunded")
+	errRefundTooLarge = errors.New("Invalid refund. Value must be positive and not exceed what is left to refund")
+	errRefundTooSmall = errors.New("Invalid refund. Value is worth nothing in the currency it was received in")
+)
+
+type compensation struct {
+	Value money.Money `json:"value"`
+	Force bool        `json:"force"`
+}
+
+// ReverseTransaction gives back everything left of a transfer
+func ReverseTransaction(c *fiber.Ctx) error {
+	return compensate(c, typeReversal)
+}
+
+// RefundTransaction gives back part of a transfer, it can be repeated up to the transferred value
+func RefundTransaction(c *fiber.Ctx) error {
+	return compensate(c, typeRefund)
+}
+
+func compensate(c *fiber.Ctx, kind string) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	}
+	var body compensation
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+		}
+	}
+	if body.Force && !can(c, "transactions:force") {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Only an admin may force a reversal"})
+	}
+
+	var t *store.Transaction
+	err = st.WithTx(context.Background(), func(ctx context.Context, s store.Store) error {
+		original, err := s.GetTransaction(ctx, id)
+		if err != nil {
+			return err
+		}
+		if original.Type != "" && original.Type != typeTransfer {
+			return errNotReversible
+		}
+		if original.Status != statusCompleted && original.Status != statusPartiallyRefunded {
+			return errNotReversible
+		}
+		left := abs(original.Value) - original.Refunded
+		value := left
+		if kind == typeRefund {
+			if value, err = units(body.Value, original.Currency); err != nil {
+				return err
+			}
+		}
+		if value <= 0 || value > left {
+			return errRefundTooLarge
+		}
+
+		// Same direction flipped around: whoever received the value pays it back
+		sign := 1
+		if original.Value < 0 {
+			sign = -1
+		}
+		t = &store.Transaction{
+			Id:         primitive.NewObjectID(),
+			Value:      sign * value,
+			NameTZ:     kind + " of " + original.Id.Hex(),
+			Date:       time.Now(),
+			ByWho:      original.ToWho,
+			ToWho:      original.ByWho,
+			Type:       kind,
+			OriginalId: original.Id,
+			Currency:   original.Currency,
+		}
+		if original.ToCurrency != "" {
+			// Paid back in the currency received, at the rate of the original so refunds add up to what it converted
+			t.Value = share(original.Refunded+value, original) - share(original.Refunded, original)
+			// Rounding down may leave nothing to pay back, the payer would be credited for free
+			if t.Value == 0 {
+				return errRefundTooSmall
+			}
+			t.Currency, t.ToCurrency = original.ToCurrency, original.Currency
+			t.ToValue, t.Rate, t.RateId = value, original.Rate, original.RateId
+		}
+		as := asUser
+		if body.Force {
+			as = forced
+		}
+		if err := move(ctx, s, t, as); err != nil {
+			return err
+		}
+
+		original.Refunded += value
+		switch {
+		case kind == typeReversal:
+			original.Status = statusReversed
+		case original.Refunded == abs(original.Value):
+			original.Status = statusRefunded
+		default:
+			original.Status = statusPartiallyRefunded
+		}
+		return s.UpdateTransaction(ctx, original)
+	})
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return c.Status(fiber.StatusExpectationFailed).JSON(fiber.Map{"error": "Invalid ID provided"})
+	case errors.Is(err, errNotReversible), errors.Is(err, errRefundTooLarge), errors.Is(err, errRefundTooSmall):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case badValue(err):
+		return invalidValue(c, err)
+	case errors.Is(err, errInsufficientFunds), errors.Is(err, errOverdraftExceeded), errors.Is(err, errOverdraftAdminOnly):
+		return refusedResponse(c, err, "Invalid "+kind+". Account of receiver no longer has the value, an admin may force it")
+	case refused(err):
+		return refusedResponse(c, err, "")
+	case err != nil:
+		log.Errorf("Failed to %s transaction %v: %v", kind, id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Transaction failed and has been rolled back"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": "Transaction has been given back", "data": t})
+}
+
+// share is the part of the converted value of t that value of it is worth, rounded down
+func share(value int, t *store.Transaction) int {
+	r := big.NewRat(int64(value), int64(t.Value))
+	v, _ := money.Round(r.Mul(r, big.NewRat(int64(t.ToValue), 1)), money.Down)
+	return int(v)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// hasRole reports whether the role AuthMiddleware found in the token is one of roles
+func hasRole(c *fiber.Ctx, roles ...string) bool {
+	role, _ := c.Locals("role").(string)
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
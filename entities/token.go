@@ -0,0 +1,188 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+	"github.com/vovamod/BankAPI/store"
+	"github.com/vovamod/BankAPI/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"slices"
+	"time"
+)
+
+// How long access and refresh tokens last, TokenInit reads them
+var accessTTL, refreshTTL time.Duration
+
+// TokenInit reads how long tokens last from ACCESS_TOKEN_TTL and REFRESH_TOKEN_TTL
+func TokenInit() {
+	var err error
+	if accessTTL, err = time.ParseDuration(utils.GetEnv("ACCESS_TOKEN_TTL", "15m")); err != nil || accessTTL <= 0 {
+		log.Fatal("ACCESS_TOKEN_TTL must be a positive duration like 15m")
+	}
+	if refreshTTL, err = time.ParseDuration(utils.GetEnv("REFRESH_TOKEN_TTL", "720h")); err != nil || refreshTTL <= 0 {
+		log.Fatal("REFRESH_TOKEN_TTL must be a positive duration like 720h")
+	}
+}
+
+// issueTokens answers with a new access token and refresh token for userID, in family or in a new one when it is empty
+func issueTokens(c *fiber.Ctx, userID, role, family string) error {
+	if family == "" {
+		family = uuid.NewString()
+	}
+	now := time.Now()
+	token, err := utils.GenerateToken(userID, role, family, accessTTL)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
+	}
+	refresh, err := utils.NewSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
+	}
+	t := store.RefreshToken{
+		Hash:      utils.HashSecret(refresh),
+		Family:    family,
+		UserId:    userID,
+		Role:      role,
+		CreatedAt: now,
+		ExpiresAt: now.Add(refreshTTL),
+	}
+	if err := st.CreateRefreshToken(context.Background(), &t); err != nil {
+		return dbError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"token":            token,
+		"expiresAt":        now.Add(accessTTL),
+		"refreshToken":     refresh,
+		"refreshExpiresAt": t.ExpiresAt,
+	})
+}
+
+// revokeFamily kills every token of a login, access and refresh ones
+func revokeFamily(ctx context.Context, family string) error {
+	return st.Revoke(ctx, &store.Revocation{Id: family, ExpiresAt: time.Now().Add(refreshTTL)})
+}
+
+type refreshBody struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// RefreshToken trades a refresh token for new tokens, once. A token used twice was stolen by one of the two callers,
+// so its whole family is revoked and both have to log in again
+func RefreshToken(c *fiber.Ctx) error {
+	var body refreshBody
+	if err := c.BodyParser(&body); err != nil || body.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid refresh token provided"})
+	}
+	ctx := context.Background()
+	now := time.Now()
+	hash := utils.HashSecret(body.RefreshToken)
+	t, err := st.GetRefreshToken(ctx, hash)
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid refresh token provided"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	if !t.ExpiresAt.After(now) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Refresh token has expired"})
+	}
+	revoked, err := st.Revoked(ctx, t.Family)
+	if err != nil {
+		return dbError(c, err)
+	}
+	if revoked {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Refresh token has been revoked"})
+	}
+	err = st.UseRefreshToken(ctx, hash, now)
+	if errors.Is(err, store.ErrNotFound) {
+		log.Warnf("Refresh token of family %s used twice, revoking the family", t.Family)
+		if err := revokeFamily(ctx, t.Family); err != nil {
+			return dbError(c, err)
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Refresh token was used already, the session has been revoked"})
+	}
+	if err != nil {
+		return dbError(c, err)
+	}
+	// A deleted player or one whose id no longer parses gets nothing
+	if t.Role == userRole {
+		id, err := primitive.ObjectIDFromHex(t.UserId)
+		if err == nil {
+			_, err = liveUser(ctx, id)
+		}
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid refresh token provided"})
+		}
+	}
+	return issueTokens(c, t.UserId, t.Role, t.Family)
+}
+
+// Logout revokes the token of the caller and its family, ending the login it came from
+func Logout(c *fiber.Ctx) error {
+	ctx := context.Background()
+	jti, _ := c.Locals("jti").(string)
+	if err := st.Revoke(ctx, &store.Revocation{Id: jti, ExpiresAt: time.Now().Add(accessTTL)}); err != nil {
+		return dbError(c, err)
+	}
+	if family, _ := c.Locals("family").(string); family != "" {
+		if err := revokeFamily(ctx, family); err != nil {
+			return dbError(c, err)
+		}
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Logged out successfully"})
+}
+
+type issueBody struct {
+	Role string `json:"role"`
+	Name string `json:"name"`
+}
+
+// IssueToken logs in someone the bank vouches for, an ADMIN, a game SERVER or an AUDITOR known by name
+func IssueToken(c *fiber.Ctx) error {
+	var body issueBody
+	if err := c.BodyParser(&body); err != nil || body.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Give the role and the name of who the token is for"})
+	}
+	if !slices.Contains([]string{adminRole, serverRole, auditorRole}, body.Role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Role must be ADMIN, SERVER or AUDITOR"})
+	}
+	return issueTokens(c, body.Name, body.Role, "")
+}
+
+type revokeBody struct {
+	Jti    string `json:"jti"`
+	Family string `json:"family"`
+}
+
+// RevokeToken kills a leaked token right away by its jti claim, its fam claim kills the whole login it came from
+func RevokeToken(c *fiber.Ctx) error {
+	var body revokeBody
+	if err := c.BodyParser(&body); err != nil || (body.Jti == "" && body.Family == "") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Give the jti or the family of the token to revoke"})
+	}
+	ctx := context.Background()
+	if body.Jti != "" {
+		if err := st.Revoke(ctx, &store.Revocation{Id: body.Jti, ExpiresAt: time.Now().Add(accessTTL)}); err != nil {
+			return dbError(c, err)
+		}
+	}
+	if body.Family != "" {
+		if err := revokeFamily(ctx, body.Family); err != nil {
+			return dbError(c, err)
+		}
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Token revoked successfully"})
+}
+
+// PurgeExpiredTokens forgets refresh tokens and revocations past their expiry, the scheduler calls it on every tick
+func PurgeExpiredTokens(ctx context.Context, now time.Time) error {
+	return st.DeleteExpiredTokens(ctx, now)
+}
+
+// GetJWKS publishes the public keys checking tokens, it is empty while tokens are signed with JWT_SECRET
+func GetJWKS(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(utils.JWKS())
+}
@@ -0,0 +1,285 @@
+// Package money is fixed point amounts: a whole number of minor units of a currency and the scale of that currency,
+// 1250 COIN at scale 2 being 12.50 COIN. Arithmetic is checked for overflow and results that do not fit
+// the scale are rounded by the rule of their currency
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Rounding rules for amounts that do not fit the scale of their currency
+const (
+	Down     = "down"     // towards zero
+	Up       = "up"       // away from zero
+	HalfUp   = "halfUp"   // to the nearest, halves away from zero
+	HalfEven = "halfEven" // to the nearest, halves to the even neighbour
+)
+
+// DefaultScale is the scale of currencies not configured, amounts stored before scales existed were whole units at it
+const DefaultScale = 2
+
+// maxScale keeps 10^scale inside an int64
+const maxScale = 18
+
+var (
+	ErrOverflow  = errors.New("amount is out of range")
+	ErrPrecision = errors.New("amount has more decimals than its currency")
+	ErrSyntax    = errors.New("invalid amount, expected a decimal like 12.50")
+	ErrCurrency  = errors.New("amounts are in different currencies")
+)
+
+// Currency is how amounts of Code are kept: Scale decimals, rounded by Rounding
+type Currency struct {
+	Code     string
+	Scale    int
+	Rounding string
+}
+
+// currencies set by Configure, the others get DefaultScale rounded Down
+var currencies = map[string]Currency{}
+
+// Default is the currency of accounts created without one, an empty code stands for it
+var Default = "COIN"
+
+// Configure reads the currencies from spec, a comma separated list of CODE:scale[:rounding] like "COIN:2,GEM:0:halfUp",
+// and sets the Default one. It must be called before amounts are handled, the scale of a currency may not change
+// once it holds money
+func Configure(spec, defaultCode string) error {
+	configured := map[string]Currency{}
+	for _, item := range strings.Split(spec, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return fmt.Errorf("invalid currency %q, expected CODE:scale[:rounding]", item)
+		}
+		scale, err := strconv.Atoi(parts[1])
+		if err != nil || scale < 0 || scale > maxScale {
+			return fmt.Errorf("invalid scale of currency %s", parts[0])
+		}
+		c := Currency{Code: strings.ToUpper(parts[0]), Scale: scale, Rounding: Down}
+		if len(parts) == 3 {
+			c.Rounding = parts[2]
+		}
+		switch c.Rounding {
+		case Down, Up, HalfUp, HalfEven:
+		default:
+			return fmt.Errorf("invalid rounding %q of currency %s", c.Rounding, c.Code)
+		}
+		configured[c.Code] = c
+	}
+	currencies = configured
+	if defaultCode != "" {
+		Default = strings.ToUpper(defaultCode)
+	}
+	return nil
+}
+
+// Lookup returns how amounts of code are kept
+func Lookup(code string) Currency {
+	if code == "" {
+		code = Default
+	}
+	if c, ok := currencies[code]; ok {
+		return c
+	}
+	return Currency{Code: code, Scale: DefaultScale, Rounding: Down}
+}
+
+// Money is Amount minor units of Currency, Scale of them making one unit.
+// Amounts read from JSON have no currency and the scale they were written with until In gives them one
+type Money struct {
+	Amount   int64
+	Currency string
+	Scale    int
+}
+
+// New is amount minor units of currency
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency, Scale: Lookup(currency).Scale}
+}
+
+// Parse reads a decimal like "12.50" or "-3", keeping as many decimals as it has
+func Parse(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(s, "-")
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" || !isDigits(whole) || !isDigits(fraction) || strings.HasSuffix(digits, ".") {
+		return Money{}, ErrSyntax
+	}
+	if len(fraction) > maxScale {
+		return Money{}, ErrPrecision
+	}
+	amount, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return Money{}, ErrOverflow
+	}
+	if negative {
+		amount = -amount
+	}
+	return Money{Amount: amount, Scale: len(fraction)}, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String is the decimal of m with all the decimals of its scale
+func (m Money) String() string {
+	digits := strconv.FormatUint(absUint(m.Amount), 10)
+	if m.Scale > 0 {
+		if len(digits) <= m.Scale {
+			digits = strings.Repeat("0", m.Scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-m.Scale] + "." + digits[len(digits)-m.Scale:]
+	}
+	if m.Amount < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+func absUint(v int64) uint64 {
+	if v < 0 {
+		return uint64(-(v + 1)) + 1
+	}
+	return uint64(v)
+}
+
+// MarshalJSON writes m as a decimal string
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(m.String())), nil
+}
+
+// UnmarshalJSON reads a decimal given as a string or a number
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// In is m in minor units of currency. Amounts with more decimals than the currency keeps are refused, not rounded
+func (m Money) In(currency string) (Money, error) {
+	if m.Currency != "" && m.Currency != currency {
+		return Money{}, ErrCurrency
+	}
+	v, err := m.At(Lookup(currency).Scale)
+	if err != nil {
+		return Money{}, err
+	}
+	v.Currency = currency
+	return v, nil
+}
+
+// At is m with scale decimals, refused like In when it has more
+func (m Money) At(scale int) (Money, error) {
+	amount := m.Amount
+	for s := m.Scale; s < scale; s++ {
+		if amount > math.MaxInt64/10 || amount < math.MinInt64/10 {
+			return Money{}, ErrOverflow
+		}
+		amount *= 10
+	}
+	for s := m.Scale; s > scale; s-- {
+		if amount%10 != 0 {
+			return Money{}, ErrPrecision
+		}
+		amount /= 10
+	}
+	return Money{Amount: amount, Currency: m.Currency, Scale: scale}, nil
+}
+
+// Add is m + o, both in the same currency
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency || m.Scale != o.Scale {
+		return Money{}, ErrCurrency
+	}
+	sum, err := Add(m.Amount, o.Amount)
+	return Money{Amount: sum, Currency: m.Currency, Scale: m.Scale}, err
+}
+
+// Sub is m - o, both in the same currency
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return m.Add(Money{Amount: -o.Amount, Currency: o.Currency, Scale: o.Scale})
+}
+
+// Convert is m times rate in currency, a rate giving units of currency for one unit of m.
+// The result is rounded by the rule of currency
+func (m Money) Convert(rate *big.Rat, currency string) (Money, error) {
+	to := Lookup(currency)
+	r := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), rate)
+	shift := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(to.Scale-m.Scale))), nil))
+	if to.Scale >= m.Scale {
+		r.Mul(r, shift)
+	} else {
+		r.Quo(r, shift)
+	}
+	amount, err := Round(r, to.Rounding)
+	return Money{Amount: amount, Currency: currency, Scale: to.Scale}, err
+}
+
+// Add is a + b, ErrOverflow when it does not fit an int64
+func Add(a, b int64) (int64, error) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, ErrOverflow
+	}
+	return sum, nil
+}
+
+// Round is r as a whole number by rule
+func Round(r *big.Rat, rule string) (int64, error) {
+	q, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if rem.Sign() != 0 {
+		away := false
+		switch rule {
+		case Up:
+			away = true
+		case HalfUp, HalfEven:
+			// Compare twice the remainder with the denominator to tell below, at or above the half
+			half := new(big.Int).Abs(rem)
+			half.Lsh(half, 1)
+			cmp := half.Cmp(r.Denom())
+			away = cmp > 0 || (cmp == 0 && (rule == HalfUp || q.Bit(0) == 1))
+		}
+		if away {
+			q.Add(q, big.NewInt(int64(r.Sign())))
+		}
+	}
+	if !q.IsInt64() {
+		return 0, ErrOverflow
+	}
+	return q.Int64(), nil
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
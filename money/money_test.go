@@ -0,0 +1,157 @@
+package money
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in     string
+		amount int64
+		scale  int
+		err    error
+	}{
+		{"12.50", 1250, 2, nil},
+		{"-3", -3, 0, nil},
+		{" 0.001 ", 1, 3, nil},
+		{"007", 7, 0, nil},
+		{"9223372036854775807", 9223372036854775807, 0, nil},
+		{"9223372036854775808", 0, 0, ErrOverflow},
+		{"0.1234567890123456789", 0, 0, ErrPrecision},
+		{"", 0, 0, ErrSyntax},
+		{"12.", 0, 0, ErrSyntax},
+		{".5", 0, 0, ErrSyntax},
+		{"1,5", 0, 0, ErrSyntax},
+		{"+1", 0, 0, ErrSyntax},
+		{"--1", 0, 0, ErrSyntax},
+		{"1e3", 0, 0, ErrSyntax},
+	}
+	for _, tt := range tests {
+		m, err := Parse(tt.in)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Parse(%q) error = %v, want %v", tt.in, err, tt.err)
+			continue
+		}
+		if err == nil && (m.Amount != tt.amount || m.Scale != tt.scale) {
+			t.Errorf("Parse(%q) = %d at scale %d, want %d at scale %d", tt.in, m.Amount, m.Scale, tt.amount, tt.scale)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		num, denom int64
+		rule       string
+		want       int64
+	}{
+		{7, 2, Down, 3},
+		{-7, 2, Down, -3},
+		{7, 2, Up, 4},
+		{-7, 2, Up, -4},
+		{7, 2, HalfUp, 4},
+		{-7, 2, HalfUp, -4},
+		{5, 2, HalfUp, 3},
+		{5, 2, HalfEven, 2},
+		{7, 2, HalfEven, 4},
+		{-5, 2, HalfEven, -2},
+		{-7, 2, HalfEven, -4},
+		{10, 3, HalfUp, 3},
+		{11, 3, HalfUp, 4},
+		{11, 3, HalfEven, 4},
+		{1, 3, Up, 1},
+		{6, 3, Up, 2},
+	}
+	for _, tt := range tests {
+		got, err := Round(big.NewRat(tt.num, tt.denom), tt.rule)
+		if err != nil || got != tt.want {
+			t.Errorf("Round(%d/%d, %s) = %d, %v, want %d", tt.num, tt.denom, tt.rule, got, err, tt.want)
+		}
+	}
+	huge := new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), 64))
+	if _, err := Round(huge, Down); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Round(2^64) error = %v, want %v", err, ErrOverflow)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	if err := Configure("COIN:2,GEM:0:up,DUST:4:halfEven", "COIN"); err != nil {
+		t.Fatal(err)
+	}
+	defer Configure("", "COIN")
+
+	tests := []struct {
+		amount   int64
+		from     string
+		rate     string
+		to       string
+		want     int64
+		wantErr  error
+		wantCode string
+	}{
+		// 12.50 COIN at 2 GEM each is 25 GEM
+		{1250, "COIN", "2", "GEM", 25, nil, "GEM"},
+		// 12.34 COIN at 1.5 is 18.51 GEM, rounded up
+		{1234, "COIN", "1.5", "GEM", 19, nil, "GEM"},
+		// 3 GEM at 0.333 is 0.99 COIN, rounded down
+		{3, "GEM", "0.333", "COIN", 99, nil, "COIN"},
+		// 0.01 COIN at 0.25 is 0.0025 DUST, exactly
+		{1, "COIN", "0.25", "DUST", 25, nil, "DUST"},
+		// 0.0001 DUST at 0.5 is half of a minor unit, halves go to the even neighbour
+		{1, "DUST", "0.5", "DUST", 0, nil, "DUST"},
+		{3, "DUST", "0.5", "DUST", 2, nil, "DUST"},
+		{1 << 62, "GEM", "100", "COIN", 0, ErrOverflow, ""},
+	}
+	for _, tt := range tests {
+		rate, _ := new(big.Rat).SetString(tt.rate)
+		got, err := New(tt.amount, tt.from).Convert(rate, tt.to)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("Convert(%d %s at %s to %s) error = %v, want %v", tt.amount, tt.from, tt.rate, tt.to, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got.Amount != tt.want || got.Currency != tt.wantCode || got.Scale != Lookup(tt.to).Scale {
+			t.Errorf("Convert(%d %s at %s to %s) = %+v, want %d %s", tt.amount, tt.from, tt.rate, tt.to, got, tt.want, tt.wantCode)
+		}
+	}
+}
+
+func TestIn(t *testing.T) {
+	if err := Configure("COIN:2,GEM:0", "COIN"); err != nil {
+		t.Fatal(err)
+	}
+	defer Configure("", "COIN")
+
+	tests := []struct {
+		in       string
+		currency string
+		want     int64
+		err      error
+	}{
+		{"12.5", "COIN", 1250, nil},
+		{"12.50", "COIN", 1250, nil},
+		{"12.505", "COIN", 0, ErrPrecision},
+		{"12.500", "COIN", 1250, nil},
+		{"3", "GEM", 3, nil},
+		{"3.5", "GEM", 0, ErrPrecision},
+		{"92233720368547758.07", "COIN", 9223372036854775807, nil},
+		{"92233720368547758.08", "COIN", 0, ErrOverflow},
+		{"922337203685477581", "COIN", 0, ErrOverflow},
+	}
+	for _, tt := range tests {
+		m, err := Parse(tt.in)
+		if err == nil {
+			m, err = m.In(tt.currency)
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s in %s error = %v, want %v", tt.in, tt.currency, err, tt.err)
+			continue
+		}
+		if err == nil && m.Amount != tt.want {
+			t.Errorf("%s in %s = %d, want %d", tt.in, tt.currency, m.Amount, tt.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package router
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/vovamod/BankAPI/store"
+	"slices"
+	"time"
+)
+
+// idempotencyTTL is how long a key is remembered, the plugin retries within seconds so a day is plenty
+const idempotencyTTL = 24 * time.Hour
+
+// Idempotency replays the stored response when a request comes again with the same Idempotency-Key header
+// and the same body. Reusing a key for another request, or while the first one is still running, is a conflict.
+// Server errors are not stored so the client may retry them.
+func Idempotency(s store.IdempotencyStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get("Idempotency-Key")
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > 255 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Idempotency-Key is too long"})
+		}
+		ctx := context.Background()
+		// Keys are scoped to the route and the caller so one key can not replay the response of another endpoint
+		// or of another player
+		if user, _ := c.Locals("user").(string); user != "" {
+			key = user + " " + key
+		}
+		record := &store.IdempotencyRecord{
+			Key:         c.Route().Path + " " + key,
+			RequestHash: requestHash(c),
+			CreatedAt:   time.Now(),
+		}
+
+		err := s.CreateIdempotencyRecord(ctx, record)
+		if errors.Is(err, store.ErrDuplicate) {
+			stored, errG := s.GetIdempotencyRecord(ctx, record.Key)
+			if errG != nil {
+				log.Errorf("Failed to read idempotency record: %v", errG)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check Idempotency-Key"})
+			}
+			if time.Since(stored.CreatedAt) <= idempotencyTTL {
+				return replay(c, stored, record.RequestHash)
+			}
+			// Expired, forget it and take the key again
+			if errD := s.DeleteIdempotencyRecord(ctx, record.Key); errD != nil && !errors.Is(errD, store.ErrNotFound) {
+				log.Errorf("Failed to delete idempotency record: %v", errD)
+			}
+			err = s.CreateIdempotencyRecord(ctx, record)
+		}
+		if err != nil {
+			log.Errorf("Failed to store idempotency record: %v", err)
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Failed to reserve Idempotency-Key, retry later"})
+		}
+
+		if err := c.Next(); err != nil {
+			// Nothing was answered yet, release the key
+			release(s, record.Key)
+			return err
+		}
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			release(s, record.Key)
+			return nil
+		}
+		if err := s.CompleteIdempotencyRecord(ctx, record.Key, status, slices.Clone(c.Response().Body())); err != nil {
+			log.Errorf("Failed to store response for Idempotency-Key: %v", err)
+		}
+		return nil
+	}
+}
+
+// replay answers with the stored response of the first request
+func replay(c *fiber.Ctx, stored *store.IdempotencyRecord, hash string) error {
+	if stored.RequestHash != hash {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Idempotency-Key was already used for a different request"})
+	}
+	if stored.Status == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "A request with this Idempotency-Key is still in progress"})
+	}
+	c.Set("Idempotent-Replayed", "true")
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Status(stored.Status).Send(stored.Body)
+}
+
+func release(s store.IdempotencyStore, key string) {
+	if err := s.DeleteIdempotencyRecord(context.Background(), key); err != nil {
+		log.Errorf("Failed to release Idempotency-Key: %v", err)
+	}
+}
+
+// requestHash identifies the request by method, full path and body
+func requestHash(c *fiber.Ctx) string {
+	h := sha256.New()
+	h.Write([]byte(c.Method() + " " + c.OriginalURL() + "\n"))
+	h.Write(c.Body())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// PurgeIdempotency forgets the records older than idempotencyTTL, the scheduler calls it on every tick
+func PurgeIdempotency(s store.IdempotencyStore) func(ctx context.Context, now time.Time) error {
+	return func(ctx context.Context, now time.Time) error {
+		return s.DeleteIdempotencyRecordsBefore(ctx, now.Add(-idempotencyTTL))
+	}
+}
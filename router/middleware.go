@@ -1,10 +1,24 @@
 package router
 
 import (
+	"context"
+	"errors"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/vovamod/BankAPI/entities"
+	"github.com/vovamod/BankAPI/store"
 	"github.com/vovamod/BankAPI/utils"
+	"net/netip"
+	"slices"
 	"strings"
+	"time"
+)
+
+// Where AuthMiddleware looks up revoked tokens and API keys, Configure sets them
+var (
+	revocations store.TokenStore
+	apiKeys     store.APIKeyStore
 )
 
 // TokenClaims represents the expected JWT claims
@@ -14,8 +28,14 @@ type TokenClaims struct {
 	jwt.RegisteredClaims
 }
 
-func AuthMiddleware(allowedRoles ...string) fiber.Handler {
+// AuthMiddleware lets through callers granted permission: tokens by the permissions of their role, API keys given
+// in X-API-Key by their scopes. An empty permission takes any token and no API key. Roles only reaching what they
+// own are let through when own says the route checks it
+func AuthMiddleware(permission string, own bool) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		if key := c.Get("X-API-Key"); key != "" {
+			return apiKeyAuth(c, key, permission)
+		}
 		tokenString := c.Get("Authorization")
 		if tokenString == "" {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Missing authentication token"})
@@ -30,11 +50,76 @@ func AuthMiddleware(allowedRoles ...string) fiber.Handler {
 		tokenString = tokenParts[1]
 
 		claims := utils.VerifyToken(tokenString)
-		for _, b := range allowedRoles {
-			if b == claims["role"] {
-				return c.Next()
-			}
+		// Tokens without a jti come from before revocation and cannot be killed, they are not taken anymore
+		jti, _ := claims["jti"].(string)
+		family, _ := claims["fam"].(string)
+		role, _ := claims["role"].(string)
+		permissions := entities.PermissionsOf(role)
+		if jti == "" || len(permissions) == 0 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid authentication token"})
+		}
+		revoked, err := revocations.Revoked(context.Background(), jti, family)
+		if err != nil {
+			log.Errorf("Failed to check revocation of token %s: %v", jti, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not check the authentication token"})
+		}
+		if revoked {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Authentication token has been revoked"})
+		}
+		if permission != "" && (!slices.Contains(permissions, permission) || entities.OwnOnly(role) && !own) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Your role may not do this"})
+		}
+		// Handlers may need to know who is calling and what they may do
+		c.Locals("role", role)
+		c.Locals("user", claims["user_id"])
+		c.Locals("jti", jti)
+		c.Locals("family", family)
+		c.Locals("permissions", permissions)
+		return c.Next()
+	}
+}
+
+// apiKeyAuth lets the key through when it holds scope, is not expired and is used from an allowed address.
+// It may do what its scopes allow, on every record
+func apiKeyAuth(c *fiber.Ctx, key, scope string) error {
+	if scope == "" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "This endpoint does not take API keys"})
+	}
+	k, err := apiKeys.GetAPIKeyByHash(context.Background(), utils.HashSecret(key))
+	if errors.Is(err, store.ErrNotFound) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid API key"})
+	}
+	if err != nil {
+		log.Errorf("Failed to look up an API key: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not check the API key"})
+	}
+	if !k.ExpiresAt.IsZero() && !k.ExpiresAt.After(time.Now()) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "API key has expired"})
+	}
+	if !ipAllowed(k.AllowedIPs, c.IP()) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "API key may not be used from this address"})
+	}
+	if !slices.Contains(k.Scopes, scope) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "API key lacks the scope " + scope})
+	}
+	c.Locals("user", k.Id.Hex())
+	c.Locals("permissions", k.Scopes)
+	return c.Next()
+}
+
+// ipAllowed tells whether ip is in one of the ranges of allowed, any ip is when there are none
+func ipAllowed(allowed []string, ip string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	for _, r := range allowed {
+		if prefix, err := utils.ParseIPRange(r); err == nil && prefix.Contains(addr.Unmap()) {
+			return true
 		}
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid authentication token"})
 	}
+	return false
 }
@@ -0,0 +1,155 @@
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/vovamod/BankAPI/entities"
+)
+
+// policy is what a route asks of its callers. A public route asks nothing, an empty permission any token.
+// Roles reaching only what they own (players) need the route to check it: owner does before the handler,
+// own says the handler does it itself
+type policy struct {
+	public     bool
+	permission string
+	own        bool
+	owner      fiber.Handler
+}
+
+// policies of every route by method and path, Configure stops at a route missing from here.
+// Roles get their permissions in the entities package
+var policies = map[string]policy{
+	"GET /.well-known/jwks.json": {public: true},
+
+	"POST /auth/call":      {public: true},
+	"GET /auth/call":       {},
+	"POST /auth/challenge": {permission: "challenges:create"},
+	"POST /auth/user":      {public: true},
+	"POST /auth/refresh":   {public: true},
+	"POST /auth/token":     {permission: "tokens:issue"},
+	"POST /auth/logout":    {},
+	"POST /auth/revoke":    {permission: "tokens:manage"},
+
+	"POST /api/keys/create": {permission: "keys:manage"},
+	"GET /api/keys/":        {permission: "keys:manage"},
+	"GET /api/keys/:id":     {permission: "keys:manage"},
+	"DELETE /api/keys/:id":  {permission: "keys:manage"},
+
+	"POST /api/transactions/create":      {permission: "transactions:create", own: true},
+	"GET /api/transactions/":             {permission: "transactions:read", own: true},
+	"GET /api/transactions/reviews":      {permission: "transactions:read"},
+	"GET /api/transactions/:id":          {permission: "transactions:read", owner: entities.OwnTransaction},
+	"POST /api/transactions/:id/approve": {permission: "transactions:write"},
+	"POST /api/transactions/:id/reject":  {permission: "transactions:write"},
+	"POST /api/transactions/:id/reverse": {permission: "transactions:write"},
+	"POST /api/transactions/:id/refund":  {permission: "transactions:write"},
+
+	"POST /api/user/create/:account": {permission: "users:write"},
+	"GET /api/user/":                 {permission: "users:read", own: true},
+	"GET /api/user/deleted":          {permission: "users:read"},
+	"GET /api/user/:id":              {permission: "users:read", owner: entities.OwnUser},
+	"POST /api/user/:id/restore":     {permission: "users:write"},
+	"DELETE /api/user/:id":           {permission: "users:write"},
+	"PUT /api/user/:id":              {permission: "users:write"},
+
+	"POST /api/account/create":          {permission: "accounts:write"},
+	"GET /api/account/":                 {permission: "accounts:read", own: true},
+	"POST /api/account/types/create":    {permission: "accounts:write"},
+	"GET /api/account/types":            {permission: "accounts:read"},
+	"GET /api/account/deleted":          {permission: "accounts:read"},
+	"GET /api/account/types/:id":        {permission: "accounts:read"},
+	"PUT /api/account/types/:id":        {permission: "accounts:write"},
+	"GET /api/account/:id":              {permission: "accounts:read", owner: entities.OwnAccount},
+	"GET /api/account/:id/verify":       {permission: "accounts:read"},
+	"GET /api/account/:id/transactions": {permission: "accounts:read", owner: entities.OwnAccount},
+	"PUT /api/account/:id/overdraft":    {permission: "accounts:write"},
+	"GET /api/account/:id/balance":      {permission: "accounts:read", owner: entities.OwnAccount},
+	"GET /api/account/:id/limits":       {permission: "accounts:read", owner: entities.OwnAccount},
+	"PUT /api/account/:id/limits":       {permission: "accounts:write"},
+	"POST /api/account/:id/activate":    {permission: "accounts:write"},
+	"POST /api/account/:id/freeze":      {permission: "accounts:write"},
+	"POST /api/account/:id/close":       {permission: "accounts:write"},
+	"POST /api/account/:id/restore":     {permission: "accounts:write"},
+	"DELETE /api/account/:id":           {permission: "accounts:write"},
+
+	"POST /api/holds/create":      {permission: "holds:write"},
+	"GET /api/holds/":             {permission: "holds:read"},
+	"GET /api/holds/:id":          {permission: "holds:read"},
+	"POST /api/holds/:id/capture": {permission: "holds:write"},
+	"POST /api/holds/:id/release": {permission: "holds:write"},
+
+	"POST /api/escrows/create":      {permission: "escrows:write"},
+	"GET /api/escrows/":             {permission: "escrows:read"},
+	"GET /api/escrows/:id":          {permission: "escrows:read"},
+	"POST /api/escrows/:id/release": {permission: "escrows:write"},
+	"POST /api/escrows/:id/refund":  {permission: "escrows:write"},
+	"POST /api/escrows/:id/split":   {permission: "escrows:settle"},
+
+	"POST /api/rates/create": {permission: "rates:write"},
+	"GET /api/rates/":        {permission: "rates:read"},
+	"GET /api/rates/:id":     {permission: "rates:read"},
+
+	"POST /api/fees/create": {permission: "fees:write"},
+	"GET /api/fees/":        {permission: "fees:read"},
+	"GET /api/fees/:id":     {permission: "fees:read"},
+	"PUT /api/fees/:id":     {permission: "fees:write"},
+	"DELETE /api/fees/:id":  {permission: "fees:write"},
+
+	"POST /api/fraud/rules/create": {permission: "fraud:write"},
+	"GET /api/fraud/rules/":        {permission: "fraud:read"},
+	"GET /api/fraud/rules/:id":     {permission: "fraud:read"},
+	"PUT /api/fraud/rules/:id":     {permission: "fraud:write"},
+	"DELETE /api/fraud/rules/:id":  {permission: "fraud:write"},
+
+	"POST /api/orders/create":  {permission: "orders:write"},
+	"GET /api/orders/":         {permission: "orders:read"},
+	"GET /api/orders/:id":      {permission: "orders:read"},
+	"GET /api/orders/:id/runs": {permission: "orders:read"},
+	"DELETE /api/orders/:id":   {permission: "orders:write"},
+}
+
+// guard is what runs before the handlers of the route, by its policy
+func guard(method, path string) []fiber.Handler {
+	p, ok := policies[method+" "+path]
+	if !ok {
+		log.Fatalf("Route %s %s has no policy", method, path)
+	}
+	if p.public {
+		return nil
+	}
+	handlers := []fiber.Handler{AuthMiddleware(p.permission, p.own || p.owner != nil)}
+	if p.owner != nil {
+		handlers = append(handlers, p.owner)
+	}
+	return handlers
+}
+
+// group registers routes under prefix behind the guard of their policy
+type group struct {
+	router fiber.Router
+	prefix string
+}
+
+func secure(app *fiber.App, prefix string) group {
+	return group{router: app.Group(prefix), prefix: prefix}
+}
+
+func (g group) add(method, path string, handlers []fiber.Handler) {
+	g.router.Add(method, path, append(guard(method, g.prefix+path), handlers...)...)
+}
+
+func (g group) Get(path string, handlers ...fiber.Handler) {
+	g.add(fiber.MethodGet, path, handlers)
+}
+
+func (g group) Post(path string, handlers ...fiber.Handler) {
+	g.add(fiber.MethodPost, path, handlers)
+}
+
+func (g group) Put(path string, handlers ...fiber.Handler) {
+	g.add(fiber.MethodPut, path, handlers)
+}
+
+func (g group) Delete(path string, handlers ...fiber.Handler) {
+	g.add(fiber.MethodDelete, path, handlers)
+}
@@ -3,31 +3,115 @@ package router
 import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/vovamod/BankAPI/entities"
+	"github.com/vovamod/BankAPI/store"
 )
 
-// Configure runs at the beginning to configure all endpoints and their handlers
-func Configure(app *fiber.App) *fiber.App {
-	auth := app.Group("/auth")
+// Configure runs at the beginning to configure all endpoints and their handlers, each behind its policy (see policies)
+func Configure(app *fiber.App, s store.Store) *fiber.App {
+	idempotency := Idempotency(s)
+	revocations, apiKeys = s, s
+
+	secure(app, "/.well-known").Get("/jwks.json", entities.GetJWKS)
+
+	auth := secure(app, "/auth")
 	auth.Post("/call", entities.AuthBank)
-	auth.Get("/call", AuthMiddleware("BANK_ISSUER"), entities.CheckToken)
+	auth.Get("/call", entities.CheckToken)
+	auth.Post("/challenge", entities.CreateChallenge)
+	auth.Post("/user", entities.AuthUser)
+	auth.Post("/refresh", entities.RefreshToken)
+	auth.Post("/token", entities.IssueToken)
+	auth.Post("/logout", entities.Logout)
+	auth.Post("/revoke", entities.RevokeToken)
+
+	key := secure(app, "/api/keys")
+	key.Post("/create", idempotency, entities.CreateAPIKey)
+	key.Get("/", entities.GetAllAPIKeys)
+	key.Get("/:id", entities.GetAPIKeyByID)
+	key.Delete("/:id", entities.DeleteAPIKeyByID)
 
-	transaction := app.Group("/api/transactions")
-	transaction.Post("/create", AuthMiddleware("BANK_ISSUER"), entities.CreateTransaction)
-	transaction.Get("/", AuthMiddleware("BANK_ISSUER"), entities.GetAllTransactions)
-	transaction.Get("/:id", AuthMiddleware("BANK_ISSUER"), entities.GetTransactionByID)
+	transaction := secure(app, "/api/transactions")
+	transaction.Post("/create", idempotency, entities.CreateTransaction)
+	transaction.Get("/", entities.GetAllTransactions)
+	// Before /:id so "reviews" is not taken for an ID
+	transaction.Get("/reviews", entities.GetReviewQueue)
+	transaction.Get("/:id", entities.GetTransactionByID)
+	transaction.Post("/:id/approve", idempotency, entities.ApproveTransaction)
+	transaction.Post("/:id/reject", idempotency, entities.RejectTransaction)
+	transaction.Post("/:id/reverse", idempotency, entities.ReverseTransaction)
+	transaction.Post("/:id/refund", idempotency, entities.RefundTransaction)
 
-	user := app.Group("/api/user")
-	user.Post("/create/:account", AuthMiddleware("BANK_ISSUER"), entities.CreateUser)
+	user := secure(app, "/api/user")
+	user.Post("/create/:account", idempotency, entities.CreateUser)
 	user.Get("/", entities.GetAllUsers)
+	// Before /:id so "deleted" is not taken for an ID
+	user.Get("/deleted", entities.GetDeletedUsers)
 	user.Get("/:id", entities.GetUserByID)
-	user.Delete("/:id", AuthMiddleware("BANK_ISSUER"), entities.DeleteUserByID)
-	user.Put("/:id", AuthMiddleware("BANK_ISSUER"), entities.UpdateUserByID)
-
-	account := app.Group("/api/account")
-	account.Post("/create", AuthMiddleware("BANK_ISSUER"), entities.CreateAccount)
-	account.Get("/", AuthMiddleware("BANK_ISSUER"), entities.GetAllAccount)
-	account.Get("/:id", AuthMiddleware("BANK_ISSUER"), entities.GetAccountByID)
-	account.Delete("/:id", AuthMiddleware("BANK_ISSUER"), entities.DeleteAccountByID)
+	user.Post("/:id/restore", idempotency, entities.RestoreUserByID)
+	user.Delete("/:id", entities.DeleteUserByID)
+	user.Put("/:id", entities.UpdateUserByID)
+
+	account := secure(app, "/api/account")
+	account.Post("/create", idempotency, entities.CreateAccount)
+	account.Get("/", entities.GetAllAccount)
+	// Before /:id so "types" is not taken for an ID
+	account.Post("/types/create", idempotency, entities.CreateAccountType)
+	account.Get("/types", entities.GetAllAccountTypes)
+	account.Get("/deleted", entities.GetDeletedAccounts)
+	account.Get("/types/:id", entities.GetAccountTypeByID)
+	account.Put("/types/:id", entities.UpdateAccountTypeByID)
+	account.Get("/:id", entities.GetAccountByID)
+	account.Get("/:id/verify", entities.VerifyAccountByID)
+	account.Get("/:id/transactions", entities.GetAccountTransactions)
+	account.Put("/:id/overdraft", entities.UpdateOverdraftByID)
+	account.Get("/:id/balance", entities.GetAccountBalance)
+	account.Get("/:id/limits", entities.GetAccountLimits)
+	account.Put("/:id/limits", entities.UpdateLimitsByID)
+	account.Post("/:id/activate", idempotency, entities.ActivateAccountByID)
+	account.Post("/:id/freeze", idempotency, entities.FreezeAccountByID)
+	account.Post("/:id/close", idempotency, entities.CloseAccountByID)
+	account.Post("/:id/restore", idempotency, entities.RestoreAccountByID)
+	account.Delete("/:id", entities.DeleteAccountByID)
+
+	hold := secure(app, "/api/holds")
+	hold.Post("/create", idempotency, entities.CreateHold)
+	hold.Get("/", entities.GetAllHolds)
+	hold.Get("/:id", entities.GetHoldByID)
+	hold.Post("/:id/capture", idempotency, entities.CaptureHold)
+	hold.Post("/:id/release", entities.ReleaseHold)
+
+	escrow := secure(app, "/api/escrows")
+	escrow.Post("/create", idempotency, entities.CreateEscrow)
+	escrow.Get("/", entities.GetAllEscrows)
+	escrow.Get("/:id", entities.GetEscrowByID)
+	escrow.Post("/:id/release", idempotency, entities.ReleaseEscrow)
+	escrow.Post("/:id/refund", idempotency, entities.RefundEscrow)
+	escrow.Post("/:id/split", idempotency, entities.SplitEscrow)
+
+	rate := secure(app, "/api/rates")
+	rate.Post("/create", idempotency, entities.CreateExchangeRate)
+	rate.Get("/", entities.GetAllExchangeRates)
+	rate.Get("/:id", entities.GetExchangeRateByID)
+
+	fee := secure(app, "/api/fees")
+	fee.Post("/create", idempotency, entities.CreateFeeRule)
+	fee.Get("/", entities.GetAllFeeRules)
+	fee.Get("/:id", entities.GetFeeRuleByID)
+	fee.Put("/:id", entities.UpdateFeeRuleByID)
+	fee.Delete("/:id", entities.DeleteFeeRuleByID)
+
+	fraud := secure(app, "/api/fraud/rules")
+	fraud.Post("/create", idempotency, entities.CreateFraudRule)
+	fraud.Get("/", entities.GetAllFraudRules)
+	fraud.Get("/:id", entities.GetFraudRuleByID)
+	fraud.Put("/:id", entities.UpdateFraudRuleByID)
+	fraud.Delete("/:id", entities.DeleteFraudRuleByID)
+
+	order := secure(app, "/api/orders")
+	order.Post("/create", idempotency, entities.CreateStandingOrder)
+	order.Get("/", entities.GetAllStandingOrders)
+	order.Get("/:id", entities.GetStandingOrderByID)
+	order.Get("/:id/runs", entities.GetStandingOrderRuns)
+	order.Delete("/:id", entities.CancelStandingOrder)
 	// Not needed. We don't want users to update accounts
 	//api.Put("/:id", withCollection("account", UpdateAccountByID))
 	return app
@@ -0,0 +1,39 @@
+// Package scheduler runs the background jobs of the server process
+package scheduler
+
+import (
+	"context"
+	"github.com/gofiber/fiber/v2/log"
+	"time"
+)
+
+// Job is run every Interval, Run gets the time of the tick
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context, now time.Time) error
+}
+
+// Start runs every job in its own goroutine until ctx is done. A job is never run twice at the same time,
+// a slow run simply delays the next one
+func Start(ctx context.Context, jobs ...Job) {
+	for _, job := range jobs {
+		go run(ctx, job)
+	}
+}
+
+func run(ctx context.Context, job Job) {
+	log.Infof("Scheduling job %s every %s", job.Name, job.Interval)
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := job.Run(ctx, now); err != nil {
+				log.Errorf("Job %s failed: %v", job.Name, err)
+			}
+		}
+	}
+}
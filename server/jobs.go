@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/vovamod/BankAPI/entities"
+	"github.com/vovamod/BankAPI/router"
+	"github.com/vovamod/BankAPI/scheduler"
+	"github.com/vovamod/BankAPI/store"
+	"github.com/vovamod/BankAPI/utils"
+	"time"
+)
+
+// startJobs runs the background jobs on s for as long as the server lives
+func startJobs(s store.Store) {
+	every, err := time.ParseDuration(utils.GetEnv("SCHEDULER_INTERVAL", "1m"))
+	if err != nil || every <= 0 {
+		log.Fatal("SCHEDULER_INTERVAL must be a positive duration like 30s or 1m")
+	}
+	retention, err := time.ParseDuration(utils.GetEnv("RETENTION_PERIOD", "720h"))
+	if err != nil || retention <= 0 {
+		log.Fatal("RETENTION_PERIOD must be a positive duration like 720h")
+	}
+	scheduler.Start(context.Background(),
+		scheduler.Job{Name: "standing orders", Interval: every, Run: entities.RunStandingOrders},
+		scheduler.Job{Name: "interest", Interval: every, Run: entities.AccrueInterest},
+		scheduler.Job{Name: "expired holds", Interval: every, Run: entities.ReleaseExpiredHolds},
+		scheduler.Job{Name: "retention", Interval: every, Run: func(ctx context.Context, now time.Time) error {
+			return entities.PurgeDeleted(ctx, now.Add(-retention))
+		}},
+		scheduler.Job{Name: "expired tokens", Interval: every, Run: entities.PurgeExpiredTokens},
+		scheduler.Job{Name: "expired idempotency keys", Interval: every, Run: router.PurgeIdempotency(s)},
+	)
+}
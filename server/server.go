@@ -6,6 +6,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/joho/godotenv"
 	"github.com/vovamod/BankAPI/entities"
+	"github.com/vovamod/BankAPI/money"
 	"github.com/vovamod/BankAPI/router"
 	"github.com/vovamod/BankAPI/utils"
 	"os"
@@ -26,7 +27,12 @@ func New(app *fiber.App) *fiber.App {
 	}
 	// check env. If missing stop process with fatal log
 	utils.CheckEnv()
-	// Init Mongo Client and pass to others!
+	if err := utils.LoadKeys(); err != nil {
+		log.Fatalf("Invalid signing keys: %v", err)
+	}
+	if err := money.Configure(utils.GetEnv("CURRENCIES", ""), utils.GetEnv("DEFAULT_CURRENCY", "COIN")); err != nil {
+		log.Fatalf("Invalid CURRENCIES: %v", err)
+	}
 	log.Info("Last phase, configuring routes to server")
 	loadRoutes(app)
 	return app
@@ -45,7 +51,10 @@ func (a *App) Start() error {
 }
 
 func loadRoutes(app *fiber.App) *fiber.App {
-	entities.Init()
-	router.Configure(app)
+	// Init the store and pass to others!
+	s := openStore()
+	entities.Init(s)
+	router.Configure(app, s)
+	startJobs(s)
 	return app
 }
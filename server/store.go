@@ -0,0 +1,40 @@
+package server
+
+import (
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/vovamod/BankAPI/store"
+	"github.com/vovamod/BankAPI/store/memstore"
+	"github.com/vovamod/BankAPI/store/mongostore"
+	"github.com/vovamod/BankAPI/store/sqlstore"
+	"github.com/vovamod/BankAPI/utils"
+	"os"
+)
+
+// openStore picks the storage backend from STORE_BACKEND, mongo is the default
+func openStore() store.Store {
+	switch backend := os.Getenv("STORE_BACKEND"); backend {
+	case "", "mongo":
+		return mongostore.New(utils.MongoDatabase())
+	case "sqlite":
+		// SQLite works out of the box, the file is created next to the binary
+		return openSQL(sqlstore.SQLite, utils.GetEnv("SQL_DSN", "bankapi.db"))
+	case "postgres":
+		return openSQL(sqlstore.Postgres, os.Getenv("SQL_DSN"))
+	case "memory":
+		log.Warn("Using the in-memory store, everything is lost on shutdown")
+		return memstore.New()
+	default:
+		log.Fatalf("Unknown STORE_BACKEND %q, expected mongo, sqlite, postgres or memory", backend)
+		return nil
+	}
+}
+
+func openSQL(dialect, dsn string) store.Store {
+	log.Info("Connecting to SQL database")
+	s, err := sqlstore.Open(dialect, dsn)
+	if err != nil {
+		log.Fatal("Cannot connect to SQL database. Error: " + err.Error())
+	}
+	log.Info("Connected to SQL database")
+	return s
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"encoding/json"
+	"github.com/vovamod/BankAPI/money"
+)
+
+// Values are written to JSON as decimals of their currency, 1250 minor units of COIN as "12.50"
+
+func amount(v int, currency string) money.Money {
+	return money.New(int64(v), currency)
+}
+
+func (t Transaction) MarshalJSON() ([]byte, error) {
+	type plain Transaction
+	type fee struct {
+		Fee
+		Value money.Money
+	}
+	fees := make([]fee, len(t.Fees))
+	for i, f := range t.Fees {
+		fees[i] = fee{f, amount(f.Value, t.Currency)}
+	}
+	return json.Marshal(struct {
+		plain
+		Value    money.Money
+		Refunded money.Money
+		ToValue  money.Money
+		Fees     []fee
+	}{plain(t), amount(t.Value, t.Currency), amount(t.Refunded, t.Currency), amount(t.ToValue, t.ToCurrency), fees})
+}
+
+func (r FeeRule) MarshalJSON() ([]byte, error) {
+	type plain FeeRule
+	type tier struct {
+		FeeTier
+		From money.Money
+		Flat money.Money
+	}
+	tiers := make([]tier, len(r.Tiers))
+	for i, t := range r.Tiers {
+		tiers[i] = tier{t, amount(t.From, r.Currency), amount(t.Flat, r.Currency)}
+	}
+	return json.Marshal(struct {
+		plain
+		Flat  money.Money
+		Min   money.Money
+		Max   money.Money
+		Tiers []tier
+	}{plain(r), amount(r.Flat, r.Currency), amount(r.Min, r.Currency), amount(r.Max, r.Currency), tiers})
+}
+
+// limitsJSON is l with the amounts given by decimal
+type limitsJSON struct {
+	PerTransaction money.Money
+	Daily          money.Money
+	Weekly         money.Money
+	Hourly         int
+}
+
+func (l Limits) json(decimal func(int) money.Money) limitsJSON {
+	return limitsJSON{decimal(l.PerTransaction), decimal(l.Daily), decimal(l.Weekly), l.Hourly}
+}
+
+func (a Account) MarshalJSON() ([]byte, error) {
+	type plain Account
+	return json.Marshal(struct {
+		plain
+		Value          money.Money
+		Held           money.Money
+		OverdraftLimit money.Money
+		Limits         limitsJSON
+	}{plain(a), amount(a.Value, a.Currency), amount(a.Held, a.Currency), amount(a.OverdraftLimit, a.Currency),
+		a.Limits.json(func(v int) money.Money { return amount(v, a.Currency) })})
+}
+
+func (o StandingOrder) MarshalJSON() ([]byte, error) {
+	type plain StandingOrder
+	return json.Marshal(struct {
+		plain
+		Value money.Money
+	}{plain(o), amount(o.Value, o.Currency)})
+}
+
+func (h Hold) MarshalJSON() ([]byte, error) {
+	type plain Hold
+	return json.Marshal(struct {
+		plain
+		Value    money.Money
+		Captured money.Money
+	}{plain(h), amount(h.Value, h.Currency), amount(h.Captured, h.Currency)})
+}
+
+func (e Escrow) MarshalJSON() ([]byte, error) {
+	type plain Escrow
+	return json.Marshal(struct {
+		plain
+		Value    money.Money
+		ToSeller money.Money
+		ToBuyer  money.Money
+	}{plain(e), amount(e.Value, e.Currency), amount(e.ToSeller, e.Currency), amount(e.ToBuyer, e.Currency)})
+}
+
+func (t AccountType) MarshalJSON() ([]byte, error) {
+	type plain AccountType
+	return json.Marshal(struct {
+		plain
+		Limits limitsJSON
+	}{plain(t), t.Limits.json(func(v int) money.Money { return money.Money{Amount: int64(v), Scale: money.DefaultScale} })})
+}
+
+func (r FraudRule) MarshalJSON() ([]byte, error) {
+	type plain FraudRule
+	return json.Marshal(struct {
+		plain
+		Value money.Money
+	}{plain(r), amount(r.Value, r.Currency)})
+}
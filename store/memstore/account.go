@@ -0,0 +1,82 @@
+package memstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"slices"
+	"strings"
+)
+
+func (s *Store) CreateAccount(_ context.Context, a *store.Account) error {
+	defer s.lock()()
+	if _, err := find(s.d.accounts, func(v store.Account) bool { return v.Name == a.Name }); err == nil {
+		return store.ErrDuplicate
+	}
+	if _, ok := s.d.accounts[a.Id]; ok {
+		return store.ErrDuplicate
+	}
+	s.d.accounts[a.Id] = *a
+	return nil
+}
+
+func (s *Store) GetAccount(_ context.Context, id primitive.ObjectID) (*store.Account, error) {
+	defer s.lock()()
+	return get(s.d.accounts, id)
+}
+
+func (s *Store) GetAccountByName(_ context.Context, name string) (*store.Account, error) {
+	defer s.lock()()
+	return find(s.d.accounts, func(v store.Account) bool { return v.Name == name })
+}
+
+func (s *Store) ListAccounts(_ context.Context, f store.AccountFilter, p store.Page) (*store.Result[store.Account], error) {
+	defer s.lock()()
+	accounts := list(s.d.accounts, func(a store.Account) bool {
+		return strings.HasPrefix(a.Name, f.NamePrefix) && (f.Type == "" || a.Type == f.Type) && (len(f.Ids) == 0 || slices.Contains(f.Ids, a.Id)) &&
+			a.DeletedAt.IsZero() != f.Deleted
+	})
+	return store.AccountSorts.Slice(p, accounts, func(a store.Account) primitive.ObjectID { return a.Id })
+}
+
+func (s *Store) UpdateAccount(_ context.Context, a *store.Account) error {
+	defer s.lock()()
+	old, ok := s.d.accounts[a.Id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if _, err := find(s.d.accounts, func(v store.Account) bool { return v.Name == a.Name && v.Id != a.Id }); err == nil {
+		return store.ErrDuplicate
+	}
+	updated := *a
+	updated.Value, updated.Held = old.Value, old.Held
+	s.d.accounts[a.Id] = updated
+	return nil
+}
+
+func (s *Store) IncAccountValue(_ context.Context, id primitive.ObjectID, delta int) error {
+	defer s.lock()()
+	a, ok := s.d.accounts[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	a.Value += delta
+	s.d.accounts[id] = a
+	return nil
+}
+
+func (s *Store) IncAccountHeld(_ context.Context, id primitive.ObjectID, delta int) error {
+	defer s.lock()()
+	a, ok := s.d.accounts[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	a.Held += delta
+	s.d.accounts[id] = a
+	return nil
+}
+
+func (s *Store) DeleteAccount(_ context.Context, id primitive.ObjectID) error {
+	defer s.lock()()
+	return remove(s.d.accounts, id)
+}
@@ -0,0 +1,39 @@
+package memstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) CreateAPIKey(_ context.Context, k *store.APIKey) error {
+	defer s.lock()()
+	if _, err := find(s.d.apiKeys, func(v store.APIKey) bool { return v.Name == k.Name || v.Hash == k.Hash }); err == nil {
+		return store.ErrDuplicate
+	}
+	if _, ok := s.d.apiKeys[k.Id]; ok {
+		return store.ErrDuplicate
+	}
+	s.d.apiKeys[k.Id] = *k
+	return nil
+}
+
+func (s *Store) GetAPIKey(_ context.Context, id primitive.ObjectID) (*store.APIKey, error) {
+	defer s.lock()()
+	return get(s.d.apiKeys, id)
+}
+
+func (s *Store) GetAPIKeyByHash(_ context.Context, hash string) (*store.APIKey, error) {
+	defer s.lock()()
+	return find(s.d.apiKeys, func(k store.APIKey) bool { return k.Hash == hash })
+}
+
+func (s *Store) ListAPIKeys(_ context.Context, p store.Page) (*store.Result[store.APIKey], error) {
+	defer s.lock()()
+	return store.APIKeySorts.Slice(p, list(s.d.apiKeys, nil), func(k store.APIKey) primitive.ObjectID { return k.Id })
+}
+
+func (s *Store) DeleteAPIKey(_ context.Context, id primitive.ObjectID) error {
+	defer s.lock()()
+	return remove(s.d.apiKeys, id)
+}
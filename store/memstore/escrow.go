@@ -0,0 +1,38 @@
+package memstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) CreateEscrow(_ context.Context, e *store.Escrow) error {
+	defer s.lock()()
+	if _, ok := s.d.escrows[e.Id]; ok {
+		return store.ErrDuplicate
+	}
+	s.d.escrows[e.Id] = *e
+	return nil
+}
+
+func (s *Store) GetEscrow(_ context.Context, id primitive.ObjectID) (*store.Escrow, error) {
+	defer s.lock()()
+	return get(s.d.escrows, id)
+}
+
+func (s *Store) ListEscrows(_ context.Context, f store.EscrowFilter, p store.Page) (*store.Result[store.Escrow], error) {
+	defer s.lock()()
+	escrows := list(s.d.escrows, func(e store.Escrow) bool {
+		return (f.Account == "" || e.Buyer == f.Account || e.Seller == f.Account) && (f.Status == "" || e.Status == f.Status)
+	})
+	return store.EscrowSorts.Slice(p, escrows, func(e store.Escrow) primitive.ObjectID { return e.Id })
+}
+
+func (s *Store) UpdateEscrow(_ context.Context, e *store.Escrow) error {
+	defer s.lock()()
+	if _, ok := s.d.escrows[e.Id]; !ok {
+		return store.ErrNotFound
+	}
+	s.d.escrows[e.Id] = *e
+	return nil
+}
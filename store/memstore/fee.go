@@ -0,0 +1,54 @@
+package memstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+)
+
+func (s *Store) CreateFeeRule(_ context.Context, r *store.FeeRule) error {
+	defer s.lock()()
+	if _, err := find(s.d.feeRules, func(v store.FeeRule) bool { return v.Name == r.Name }); err == nil {
+		return store.ErrDuplicate
+	}
+	if _, ok := s.d.feeRules[r.Id]; ok {
+		return store.ErrDuplicate
+	}
+	s.d.feeRules[r.Id] = *r
+	return nil
+}
+
+func (s *Store) GetFeeRule(_ context.Context, id primitive.ObjectID) (*store.FeeRule, error) {
+	defer s.lock()()
+	return get(s.d.feeRules, id)
+}
+
+func (s *Store) ListFeeRules(_ context.Context, p store.Page) (*store.Result[store.FeeRule], error) {
+	defer s.lock()()
+	return store.FeeRuleSorts.Slice(p, list(s.d.feeRules, nil), func(r store.FeeRule) primitive.ObjectID { return r.Id })
+}
+
+func (s *Store) FeeRulesFor(_ context.Context, t, currency string) ([]store.FeeRule, error) {
+	defer s.lock()()
+	rules := list(s.d.feeRules, func(r store.FeeRule) bool { return (r.Type == "" || r.Type == t) && r.Currency == currency })
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	return rules, nil
+}
+
+func (s *Store) UpdateFeeRule(_ context.Context, r *store.FeeRule) error {
+	defer s.lock()()
+	if _, ok := s.d.feeRules[r.Id]; !ok {
+		return store.ErrNotFound
+	}
+	if _, err := find(s.d.feeRules, func(v store.FeeRule) bool { return v.Name == r.Name && v.Id != r.Id }); err == nil {
+		return store.ErrDuplicate
+	}
+	s.d.feeRules[r.Id] = *r
+	return nil
+}
+
+func (s *Store) DeleteFeeRule(_ context.Context, id primitive.ObjectID) error {
+	defer s.lock()()
+	return remove(s.d.feeRules, id)
+}
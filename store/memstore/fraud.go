@@ -0,0 +1,54 @@
+package memstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+)
+
+func (s *Store) CreateFraudRule(_ context.Context, r *store.FraudRule) error {
+	defer s.lock()()
+	if _, err := find(s.d.fraudRules, func(v store.FraudRule) bool { return v.Name == r.Name }); err == nil {
+		return store.ErrDuplicate
+	}
+	if _, ok := s.d.fraudRules[r.Id]; ok {
+		return store.ErrDuplicate
+	}
+	s.d.fraudRules[r.Id] = *r
+	return nil
+}
+
+func (s *Store) GetFraudRule(_ context.Context, id primitive.ObjectID) (*store.FraudRule, error) {
+	defer s.lock()()
+	return get(s.d.fraudRules, id)
+}
+
+func (s *Store) ListFraudRules(_ context.Context, p store.Page) (*store.Result[store.FraudRule], error) {
+	defer s.lock()()
+	return store.FraudRuleSorts.Slice(p, list(s.d.fraudRules, nil), func(r store.FraudRule) primitive.ObjectID { return r.Id })
+}
+
+func (s *Store) FraudRulesFor(_ context.Context, currency string) ([]store.FraudRule, error) {
+	defer s.lock()()
+	rules := list(s.d.fraudRules, func(r store.FraudRule) bool { return r.Currency == currency })
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	return rules, nil
+}
+
+func (s *Store) UpdateFraudRule(_ context.Context, r *store.FraudRule) error {
+	defer s.lock()()
+	if _, ok := s.d.fraudRules[r.Id]; !ok {
+		return store.ErrNotFound
+	}
+	if _, err := find(s.d.fraudRules, func(v store.FraudRule) bool { return v.Name == r.Name && v.Id != r.Id }); err == nil {
+		return store.ErrDuplicate
+	}
+	s.d.fraudRules[r.Id] = *r
+	return nil
+}
+
+func (s *Store) DeleteFraudRule(_ context.Context, id primitive.ObjectID) error {
+	defer s.lock()()
+	return remove(s.d.fraudRules, id)
+}
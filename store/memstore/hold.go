@@ -0,0 +1,50 @@
+package memstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"time"
+)
+
+func (s *Store) CreateHold(_ context.Context, h *store.Hold) error {
+	defer s.lock()()
+	if _, ok := s.d.holds[h.Id]; ok {
+		return store.ErrDuplicate
+	}
+	s.d.holds[h.Id] = *h
+	return nil
+}
+
+func (s *Store) GetHold(_ context.Context, id primitive.ObjectID) (*store.Hold, error) {
+	defer s.lock()()
+	return get(s.d.holds, id)
+}
+
+func (s *Store) ListHolds(_ context.Context, f store.HoldFilter, p store.Page) (*store.Result[store.Hold], error) {
+	defer s.lock()()
+	holds := list(s.d.holds, func(h store.Hold) bool {
+		return (f.Account == "" || h.Account == f.Account) && (f.Status == "" || h.Status == f.Status)
+	})
+	return store.HoldSorts.Slice(p, holds, func(h store.Hold) primitive.ObjectID { return h.Id })
+}
+
+func (s *Store) ExpiredHolds(_ context.Context, status string, now time.Time, limit int) ([]store.Hold, error) {
+	defer s.lock()()
+	expired := list(s.d.holds, func(h store.Hold) bool { return h.Status == status && !h.ExpiresAt.After(now) })
+	sort.SliceStable(expired, func(i, j int) bool { return expired[i].ExpiresAt.Before(expired[j].ExpiresAt) })
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+	return expired, nil
+}
+
+func (s *Store) UpdateHold(_ context.Context, h *store.Hold) error {
+	defer s.lock()()
+	if _, ok := s.d.holds[h.Id]; !ok {
+		return store.ErrNotFound
+	}
+	s.d.holds[h.Id] = *h
+	return nil
+}
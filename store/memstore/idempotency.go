@@ -0,0 +1,60 @@
+package memstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"slices"
+	"time"
+)
+
+func (s *Store) CreateIdempotencyRecord(_ context.Context, r *store.IdempotencyRecord) error {
+	defer s.lock()()
+	if _, ok := s.d.idempotency[r.Key]; ok {
+		return store.ErrDuplicate
+	}
+	record := *r
+	record.Body = slices.Clone(r.Body)
+	s.d.idempotency[r.Key] = record
+	return nil
+}
+
+func (s *Store) GetIdempotencyRecord(_ context.Context, key string) (*store.IdempotencyRecord, error) {
+	defer s.lock()()
+	r, ok := s.d.idempotency[key]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	r.Body = slices.Clone(r.Body)
+	return &r, nil
+}
+
+func (s *Store) CompleteIdempotencyRecord(_ context.Context, key string, status int, body []byte) error {
+	defer s.lock()()
+	r, ok := s.d.idempotency[key]
+	if !ok {
+		return store.ErrNotFound
+	}
+	r.Status = status
+	r.Body = slices.Clone(body)
+	s.d.idempotency[key] = r
+	return nil
+}
+
+func (s *Store) DeleteIdempotencyRecord(_ context.Context, key string) error {
+	defer s.lock()()
+	if _, ok := s.d.idempotency[key]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.d.idempotency, key)
+	return nil
+}
+
+func (s *Store) DeleteIdempotencyRecordsBefore(_ context.Context, before time.Time) error {
+	defer s.lock()()
+	for key, r := range s.d.idempotency {
+		if r.CreatedAt.Before(before) {
+			delete(s.d.idempotency, key)
+		}
+	}
+	return nil
+}
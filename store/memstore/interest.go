@@ -0,0 +1,86 @@
+package memstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"time"
+)
+
+func (s *Store) CreateAccountType(_ context.Context, t *store.AccountType) error {
+	defer s.lock()()
+	if _, err := find(s.d.accountTypes, func(v store.AccountType) bool { return v.Name == t.Name }); err == nil {
+		return store.ErrDuplicate
+	}
+	if _, ok := s.d.accountTypes[t.Id]; ok {
+		return store.ErrDuplicate
+	}
+	s.d.accountTypes[t.Id] = *t
+	return nil
+}
+
+func (s *Store) GetAccountType(_ context.Context, id primitive.ObjectID) (*store.AccountType, error) {
+	defer s.lock()()
+	return get(s.d.accountTypes, id)
+}
+
+func (s *Store) GetAccountTypeByName(_ context.Context, name string) (*store.AccountType, error) {
+	defer s.lock()()
+	return find(s.d.accountTypes, func(v store.AccountType) bool { return v.Name == name })
+}
+
+func (s *Store) ListAccountTypes(_ context.Context, p store.Page) (*store.Result[store.AccountType], error) {
+	defer s.lock()()
+	return store.AccountTypeSorts.Slice(p, list(s.d.accountTypes, nil), func(t store.AccountType) primitive.ObjectID { return t.Id })
+}
+
+func (s *Store) UpdateAccountType(_ context.Context, t *store.AccountType) error {
+	defer s.lock()()
+	if _, ok := s.d.accountTypes[t.Id]; !ok {
+		return store.ErrNotFound
+	}
+	if _, err := find(s.d.accountTypes, func(v store.AccountType) bool { return v.Name == t.Name && v.Id != t.Id }); err == nil {
+		return store.ErrDuplicate
+	}
+	s.d.accountTypes[t.Id] = *t
+	return nil
+}
+
+func (s *Store) CreateAccrual(_ context.Context, a *store.Accrual) error {
+	defer s.lock()()
+	if _, ok := s.d.accruals[a.Account]; ok {
+		return store.ErrDuplicate
+	}
+	s.d.accruals[a.Account] = *a
+	return nil
+}
+
+func (s *Store) GetAccrual(_ context.Context, account primitive.ObjectID) (*store.Accrual, error) {
+	defer s.lock()()
+	return get(s.d.accruals, account)
+}
+
+func (s *Store) DueAccruals(_ context.Context, now time.Time, limit int) ([]store.Accrual, error) {
+	defer s.lock()()
+	due := list(s.d.accruals, func(a store.Accrual) bool { return !a.Due.After(now) })
+	sort.SliceStable(due, func(i, j int) bool { return due[i].Due.Before(due[j].Due) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (s *Store) UpdateAccrual(_ context.Context, a *store.Accrual) error {
+	defer s.lock()()
+	if _, ok := s.d.accruals[a.Account]; !ok {
+		return store.ErrNotFound
+	}
+	s.d.accruals[a.Account] = *a
+	return nil
+}
+
+func (s *Store) DeleteAccrual(_ context.Context, account primitive.ObjectID) error {
+	defer s.lock()()
+	return remove(s.d.accruals, account)
+}
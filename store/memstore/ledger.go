@@ -0,0 +1,65 @@
+package memstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"time"
+)
+
+func (s *Store) CreatePostings(_ context.Context, postings []store.Posting) error {
+	defer s.lock()()
+	for _, p := range postings {
+		if _, ok := s.d.postings[p.Id]; ok {
+			return store.ErrDuplicate
+		}
+	}
+	for _, p := range postings {
+		s.d.postings[p.Id] = p
+	}
+	return nil
+}
+
+func (s *Store) SumPostings(_ context.Context, account primitive.ObjectID) (int, int, error) {
+	defer s.lock()()
+	sum, count := 0, 0
+	for _, p := range s.d.postings {
+		if p.Account == account {
+			sum += p.Amount
+			count++
+		}
+	}
+	return sum, count, nil
+}
+
+func (s *Store) ListPostings(_ context.Context, account primitive.ObjectID) ([]store.Posting, error) {
+	defer s.lock()()
+	postings := list(s.d.postings, func(p store.Posting) bool { return p.Account == account })
+	sort.SliceStable(postings, func(i, j int) bool { return postings[i].Date.Before(postings[j].Date) })
+	return postings, nil
+}
+
+func (s *Store) ListLedger(_ context.Context, account primitive.ObjectID, after store.LedgerEntry, limit int) ([]store.LedgerEntry, error) {
+	defer s.lock()()
+	var entries []store.LedgerEntry
+	index := map[primitive.ObjectID]int{}
+	for _, p := range list(s.d.postings, func(p store.Posting) bool { return p.Account == account && entryAfter(p.Date, p.TransactionId, after) }) {
+		i, ok := index[p.TransactionId]
+		if !ok {
+			i = len(entries)
+			index[p.TransactionId] = i
+			entries = append(entries, store.LedgerEntry{TransactionId: p.TransactionId, Date: p.Date})
+		}
+		entries[i].Amount += p.Amount
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entryAfter(entries[j].Date, entries[j].TransactionId, entries[i])
+	})
+	return entries[:min(limit, len(entries))], nil
+}
+
+// entryAfter reports whether the entry of transaction id at date comes after the entry after
+func entryAfter(date time.Time, id primitive.ObjectID, after store.LedgerEntry) bool {
+	return date.After(after.Date) || date.Equal(after.Date) && id.Hex() > after.TransactionId.Hex()
+}
@@ -0,0 +1,163 @@
+// Package memstore is an in-memory backend of store.Store. Nothing is persisted, handy for development and tests
+package memstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"sync"
+)
+
+// data is one consistent snapshot of everything stored
+type data struct {
+	accounts     map[primitive.ObjectID]store.Account
+	users        map[primitive.ObjectID]store.User
+	transactions map[primitive.ObjectID]store.Transaction
+	postings     map[primitive.ObjectID]store.Posting
+	idempotency  map[string]store.IdempotencyRecord
+	orders       map[primitive.ObjectID]store.StandingOrder
+	orderRuns    map[primitive.ObjectID]store.StandingOrderRun
+	accountTypes map[primitive.ObjectID]store.AccountType
+	accruals     map[primitive.ObjectID]store.Accrual
+	holds        map[primitive.ObjectID]store.Hold
+	escrows      map[primitive.ObjectID]store.Escrow
+	rates        map[primitive.ObjectID]store.ExchangeRate
+	feeRules     map[primitive.ObjectID]store.FeeRule
+	fraudRules   map[primitive.ObjectID]store.FraudRule
+	refresh      map[string]store.RefreshToken
+	revocations  map[string]store.Revocation
+	apiKeys      map[primitive.ObjectID]store.APIKey
+}
+
+func (d *data) clone() *data {
+	return &data{
+		accounts:     cloneMap(d.accounts),
+		users:        cloneMap(d.users),
+		transactions: cloneMap(d.transactions),
+		postings:     cloneMap(d.postings),
+		idempotency:  cloneMap(d.idempotency),
+		orders:       cloneMap(d.orders),
+		orderRuns:    cloneMap(d.orderRuns),
+		accountTypes: cloneMap(d.accountTypes),
+		accruals:     cloneMap(d.accruals),
+		holds:        cloneMap(d.holds),
+		escrows:      cloneMap(d.escrows),
+		rates:        cloneMap(d.rates),
+		feeRules:     cloneMap(d.feeRules),
+		fraudRules:   cloneMap(d.fraudRules),
+		refresh:      cloneMap(d.refresh),
+		revocations:  cloneMap(d.revocations),
+		apiKeys:      cloneMap(d.apiKeys),
+	}
+}
+
+var _ store.Store = (*Store)(nil)
+
+// Store guards data with a single mutex. Inside WithTx the lock is held by the transaction
+// and fn works on a copy of data that replaces the original only on success
+type Store struct {
+	mu   *sync.Mutex
+	d    *data
+	inTx bool
+}
+
+func New() *Store {
+	return &Store{
+		mu: &sync.Mutex{},
+		d: &data{
+			accounts:     map[primitive.ObjectID]store.Account{},
+			users:        map[primitive.ObjectID]store.User{},
+			transactions: map[primitive.ObjectID]store.Transaction{},
+			postings:     map[primitive.ObjectID]store.Posting{},
+			idempotency:  map[string]store.IdempotencyRecord{},
+			orders:       map[primitive.ObjectID]store.StandingOrder{},
+			orderRuns:    map[primitive.ObjectID]store.StandingOrderRun{},
+			accountTypes: map[primitive.ObjectID]store.AccountType{},
+			accruals:     map[primitive.ObjectID]store.Accrual{},
+			holds:        map[primitive.ObjectID]store.Hold{},
+			escrows:      map[primitive.ObjectID]store.Escrow{},
+			rates:        map[primitive.ObjectID]store.ExchangeRate{},
+			feeRules:     map[primitive.ObjectID]store.FeeRule{},
+			fraudRules:   map[primitive.ObjectID]store.FraudRule{},
+			refresh:      map[string]store.RefreshToken{},
+			revocations:  map[string]store.Revocation{},
+			apiKeys:      map[primitive.ObjectID]store.APIKey{},
+		},
+	}
+}
+
+// lock takes the mutex unless it is already held by the running transaction, call the returned func to release it
+func (s *Store) lock() func() {
+	if s.inTx {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+func (s *Store) WithTx(ctx context.Context, fn func(ctx context.Context, s store.Store) error) error {
+	if s.inTx {
+		return fn(ctx, s)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx := &Store{mu: s.mu, d: s.d.clone(), inTx: true}
+	if err := fn(ctx, tx); err != nil {
+		return err
+	}
+	s.d = tx.d
+	return nil
+}
+
+func (s *Store) Close(context.Context) error {
+	return nil
+}
+
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// find returns the first value matching, ordered by id
+func find[V any](m map[primitive.ObjectID]V, match func(V) bool) (*V, error) {
+	for _, v := range list(m, match) {
+		return &v, nil
+	}
+	return nil, store.ErrNotFound
+}
+
+// list returns every value matching ordered by id, which for ObjectIDs is creation order
+func list[V any](m map[primitive.ObjectID]V, match func(V) bool) []V {
+	ids := make([]primitive.ObjectID, 0, len(m))
+	for id, v := range m {
+		if match == nil || match(v) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Hex() < ids[j].Hex() })
+	result := make([]V, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, m[id])
+	}
+	return result
+}
+
+func get[V any](m map[primitive.ObjectID]V, id primitive.ObjectID) (*V, error) {
+	v, ok := m[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &v, nil
+}
+
+func remove[V any](m map[primitive.ObjectID]V, id primitive.ObjectID) error {
+	if _, ok := m[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(m, id)
+	return nil
+}
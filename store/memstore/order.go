@@ -0,0 +1,62 @@
+package memstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"time"
+)
+
+func (s *Store) CreateStandingOrder(_ context.Context, o *store.StandingOrder) error {
+	defer s.lock()()
+	if _, ok := s.d.orders[o.Id]; ok {
+		return store.ErrDuplicate
+	}
+	s.d.orders[o.Id] = *o
+	return nil
+}
+
+func (s *Store) GetStandingOrder(_ context.Context, id primitive.ObjectID) (*store.StandingOrder, error) {
+	defer s.lock()()
+	return get(s.d.orders, id)
+}
+
+func (s *Store) ListStandingOrders(_ context.Context, p store.Page) (*store.Result[store.StandingOrder], error) {
+	defer s.lock()()
+	return store.StandingOrderSorts.Slice(p, list(s.d.orders, nil), func(o store.StandingOrder) primitive.ObjectID { return o.Id })
+}
+
+func (s *Store) DueStandingOrders(_ context.Context, status string, now time.Time, limit int) ([]store.StandingOrder, error) {
+	defer s.lock()()
+	due := list(s.d.orders, func(o store.StandingOrder) bool { return o.Status == status && !o.NextRun.After(now) })
+	sort.SliceStable(due, func(i, j int) bool { return due[i].NextRun.Before(due[j].NextRun) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (s *Store) UpdateStandingOrder(_ context.Context, o *store.StandingOrder) error {
+	defer s.lock()()
+	if _, ok := s.d.orders[o.Id]; !ok {
+		return store.ErrNotFound
+	}
+	s.d.orders[o.Id] = *o
+	return nil
+}
+
+func (s *Store) CreateStandingOrderRun(_ context.Context, r *store.StandingOrderRun) error {
+	defer s.lock()()
+	if _, ok := s.d.orderRuns[r.Id]; ok {
+		return store.ErrDuplicate
+	}
+	s.d.orderRuns[r.Id] = *r
+	return nil
+}
+
+func (s *Store) ListStandingOrderRuns(_ context.Context, order primitive.ObjectID, p store.Page) (*store.Result[store.StandingOrderRun], error) {
+	defer s.lock()()
+	runs := list(s.d.orderRuns, func(r store.StandingOrderRun) bool { return r.OrderId == order })
+	return store.StandingOrderRunSorts.Slice(p, runs, func(r store.StandingOrderRun) primitive.ObjectID { return r.Id })
+}
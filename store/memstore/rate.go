@@ -0,0 +1,44 @@
+package memstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) CreateExchangeRate(_ context.Context, r *store.ExchangeRate) error {
+	defer s.lock()()
+	if _, ok := s.d.rates[r.Id]; ok {
+		return store.ErrDuplicate
+	}
+	s.d.rates[r.Id] = *r
+	return nil
+}
+
+func (s *Store) GetExchangeRate(_ context.Context, id primitive.ObjectID) (*store.ExchangeRate, error) {
+	defer s.lock()()
+	return get(s.d.rates, id)
+}
+
+func (s *Store) LatestExchangeRate(_ context.Context, from, to string) (*store.ExchangeRate, error) {
+	defer s.lock()()
+	var latest *store.ExchangeRate
+	// list is in id order, the last of equal dates wins
+	for _, r := range list(s.d.rates, func(r store.ExchangeRate) bool { return r.From == from && r.To == to }) {
+		if latest == nil || !r.CreatedAt.Before(latest.CreatedAt) {
+			latest = &r
+		}
+	}
+	if latest == nil {
+		return nil, store.ErrNotFound
+	}
+	return latest, nil
+}
+
+func (s *Store) ListExchangeRates(_ context.Context, f store.ExchangeRateFilter, p store.Page) (*store.Result[store.ExchangeRate], error) {
+	defer s.lock()()
+	rates := list(s.d.rates, func(r store.ExchangeRate) bool {
+		return (f.From == "" || r.From == f.From) && (f.To == "" || r.To == f.To)
+	})
+	return store.ExchangeRateSorts.Slice(p, rates, func(r store.ExchangeRate) primitive.ObjectID { return r.Id })
+}
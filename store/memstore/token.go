@@ -0,0 +1,70 @@
+package memstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"time"
+)
+
+func (s *Store) CreateRefreshToken(_ context.Context, t *store.RefreshToken) error {
+	defer s.lock()()
+	if _, ok := s.d.refresh[t.Hash]; ok {
+		return store.ErrDuplicate
+	}
+	s.d.refresh[t.Hash] = *t
+	return nil
+}
+
+func (s *Store) GetRefreshToken(_ context.Context, hash string) (*store.RefreshToken, error) {
+	defer s.lock()()
+	t, ok := s.d.refresh[hash]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &t, nil
+}
+
+func (s *Store) UseRefreshToken(_ context.Context, hash string, at time.Time) error {
+	defer s.lock()()
+	t, ok := s.d.refresh[hash]
+	if !ok || !t.UsedAt.IsZero() {
+		return store.ErrNotFound
+	}
+	t.UsedAt = at
+	s.d.refresh[hash] = t
+	return nil
+}
+
+func (s *Store) Revoke(_ context.Context, r *store.Revocation) error {
+	defer s.lock()()
+	if old, ok := s.d.revocations[r.Id]; ok && old.ExpiresAt.After(r.ExpiresAt) {
+		return nil
+	}
+	s.d.revocations[r.Id] = *r
+	return nil
+}
+
+func (s *Store) Revoked(_ context.Context, ids ...string) (bool, error) {
+	defer s.lock()()
+	for _, id := range ids {
+		if _, ok := s.d.revocations[id]; ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) DeleteExpiredTokens(_ context.Context, now time.Time) error {
+	defer s.lock()()
+	for hash, t := range s.d.refresh {
+		if t.ExpiresAt.Before(now) {
+			delete(s.d.refresh, hash)
+		}
+	}
+	for id, r := range s.d.revocations {
+		if r.ExpiresAt.Before(now) {
+			delete(s.d.revocations, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package memstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"slices"
+)
+
+func (s *Store) CreateTransaction(_ context.Context, t *store.Transaction) error {
+	defer s.lock()()
+	if _, ok := s.d.transactions[t.Id]; ok {
+		return store.ErrDuplicate
+	}
+	s.d.transactions[t.Id] = *t
+	return nil
+}
+
+func (s *Store) GetTransaction(_ context.Context, id primitive.ObjectID) (*store.Transaction, error) {
+	defer s.lock()()
+	return get(s.d.transactions, id)
+}
+
+func (s *Store) ListTransactions(_ context.Context, f store.TransactionFilter, p store.Page) (*store.Result[store.Transaction], error) {
+	defer s.lock()()
+	transactions := list(s.d.transactions, func(t store.Transaction) bool { return matchTransaction(f, t) })
+	return store.TransactionSorts.Slice(p, transactions, func(t store.Transaction) primitive.ObjectID { return t.Id })
+}
+
+func matchTransaction(f store.TransactionFilter, t store.Transaction) bool {
+	switch {
+	case len(f.Ids) > 0 && !slices.Contains(f.Ids, t.Id),
+		f.ByWho != "" && t.ByWho != f.ByWho,
+		f.ToWho != "" && t.ToWho != f.ToWho,
+		f.Account != "" && t.ByWho != f.Account && t.ToWho != f.Account,
+		f.Status != "" && t.Status != f.Status,
+		slices.Contains(f.StatusNot, t.Status),
+		!f.From.IsZero() && t.Date.Before(f.From),
+		!f.To.IsZero() && !t.Date.Before(f.To):
+		return false
+	}
+	return true
+}
+
+func (s *Store) Spent(_ context.Context, f store.SpendingFilter) (int, int, error) {
+	defer s.lock()()
+	value, count := 0, 0
+	for _, t := range s.d.transactions {
+		payer, paid := t.ByWho, t.Value
+		if paid < 0 {
+			payer, paid = t.ToWho, -paid
+		}
+		if slices.Contains(f.Payers, payer) && slices.Contains(f.Types, t.Type) && !slices.Contains(f.StatusNot, t.Status) && !t.Date.Before(f.Since) {
+			value += paid
+			count++
+		}
+	}
+	return value, count, nil
+}
+
+func (s *Store) UpdateTransaction(_ context.Context, t *store.Transaction) error {
+	defer s.lock()()
+	if _, ok := s.d.transactions[t.Id]; !ok {
+		return store.ErrNotFound
+	}
+	s.d.transactions[t.Id] = *t
+	return nil
+}
@@ -0,0 +1,85 @@
+package memstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"slices"
+	"strings"
+)
+
+// copyUser detaches the Account slice so callers never share memory with the store
+func copyUser(u store.User) store.User {
+	u.Account = slices.Clone(u.Account)
+	return u
+}
+
+func (s *Store) CreateUser(_ context.Context, u *store.User) error {
+	defer s.lock()()
+	if _, err := find(s.d.users, func(v store.User) bool { return v.Name == u.Name }); err == nil {
+		return store.ErrDuplicate
+	}
+	if _, ok := s.d.users[u.Id]; ok {
+		return store.ErrDuplicate
+	}
+	s.d.users[u.Id] = copyUser(*u)
+	return nil
+}
+
+func (s *Store) GetUser(_ context.Context, id primitive.ObjectID) (*store.User, error) {
+	defer s.lock()()
+	u, err := get(s.d.users, id)
+	if err != nil {
+		return nil, err
+	}
+	*u = copyUser(*u)
+	return u, nil
+}
+
+func (s *Store) GetUserByName(_ context.Context, name string) (*store.User, error) {
+	defer s.lock()()
+	u, err := find(s.d.users, func(v store.User) bool { return v.Name == name })
+	if err != nil {
+		return nil, err
+	}
+	*u = copyUser(*u)
+	return u, nil
+}
+
+func (s *Store) GetUserByAccount(_ context.Context, accountID string) (*store.User, error) {
+	defer s.lock()()
+	u, err := find(s.d.users, func(v store.User) bool { return slices.Contains(v.Account, accountID) })
+	if err != nil {
+		return nil, err
+	}
+	*u = copyUser(*u)
+	return u, nil
+}
+
+func (s *Store) ListUsers(_ context.Context, f store.UserFilter, p store.Page) (*store.Result[store.User], error) {
+	defer s.lock()()
+	users := list(s.d.users, func(u store.User) bool {
+		return strings.HasPrefix(u.Name, f.NamePrefix) && u.DeletedAt.IsZero() != f.Deleted
+	})
+	for i := range users {
+		users[i] = copyUser(users[i])
+	}
+	return store.UserSorts.Slice(p, users, func(u store.User) primitive.ObjectID { return u.Id })
+}
+
+func (s *Store) UpdateUser(_ context.Context, u *store.User) error {
+	defer s.lock()()
+	if _, ok := s.d.users[u.Id]; !ok {
+		return store.ErrNotFound
+	}
+	if _, err := find(s.d.users, func(v store.User) bool { return v.Name == u.Name && v.Id != u.Id }); err == nil {
+		return store.ErrDuplicate
+	}
+	s.d.users[u.Id] = copyUser(*u)
+	return nil
+}
+
+func (s *Store) DeleteUser(_ context.Context, id primitive.ObjectID) error {
+	defer s.lock()()
+	return remove(s.d.users, id)
+}
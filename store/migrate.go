@@ -0,0 +1,79 @@
+package store
+
+import (
+	"github.com/vovamod/BankAPI/money"
+	"slices"
+)
+
+// Amounts were whole units before they became minor units, the money migration of every backend scales them with these
+
+// WholeUnits scales whole units, it keeps the first error so a record is checked once for all its amounts
+type WholeUnits struct {
+	Err error
+}
+
+// In turns v whole units of currency into minor units of its scale, the empty currency being the default one
+func (w *WholeUnits) In(v int, currency string) int {
+	m, err := money.Money{Amount: int64(v)}.At(money.Lookup(currency).Scale)
+	if w.Err == nil {
+		w.Err = err
+	}
+	return int(m.Amount)
+}
+
+// FirstCurrency is the first of currencies that is set, the default one when none is. Records made before they had
+// a currency get the one of the accounts they involve this way, system accounts having none
+func FirstCurrency(currencies ...string) string {
+	for _, c := range currencies {
+		if c != "" {
+			return c
+		}
+	}
+	return money.Default
+}
+
+// systemAccounts are the accounts of the bank itself, they hold every currency
+var systemAccounts = []string{"BANK_ISSUER", "BANK_ESCROW", "BANK_FEES", "BANK_TAX"}
+
+// Leg is a posting as the money migration sees it, Currency being the one of its account, empty for system accounts
+type Leg struct {
+	Amount   int
+	Currency string
+}
+
+// LegOf is the leg of amount posted to the account named name holding currency
+func LegOf(amount int, name, currency string) Leg {
+	if slices.Contains(systemAccounts, name) {
+		return Leg{Amount: amount}
+	}
+	return Leg{Amount: amount, Currency: FirstCurrency(currency)}
+}
+
+// LegCurrencies returns the currency each of the legs of one transaction moved. Player accounts move their own, a system
+// account moves the one of the legs it offsets, those of the other sign: BANK_ISSUER takes the currency of the payer and
+// gives the one of the receiver in a conversion, the fee accounts take the one of the payer. Between system accounts only,
+// it is fallback
+func LegCurrencies(legs []Leg, fallback string) []string {
+	var paid, received string
+	for _, l := range legs {
+		switch {
+		case l.Currency == "":
+		case l.Amount < 0 && paid == "":
+			paid = l.Currency
+		case l.Amount > 0 && received == "":
+			received = l.Currency
+		}
+	}
+	currencies := make([]string, len(legs))
+	for i, l := range legs {
+		switch {
+		case l.Currency != "":
+			currencies[i] = l.Currency
+		case l.Amount > 0:
+			currencies[i] = FirstCurrency(paid, fallback)
+		default:
+			currencies[i] = FirstCurrency(received, fallback)
+		}
+	}
+	return currencies
+}
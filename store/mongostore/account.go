@@ -0,0 +1,69 @@
+package mongostore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) CreateAccount(ctx context.Context, a *store.Account) error {
+	return insertOne(ctx, s.collection(accountCollection), a)
+}
+
+func (s *Store) GetAccount(ctx context.Context, id primitive.ObjectID) (*store.Account, error) {
+	return findOne[store.Account](ctx, s.collection(accountCollection), bson.M{"_id": id})
+}
+
+func (s *Store) GetAccountByName(ctx context.Context, name string) (*store.Account, error) {
+	return findOne[store.Account](ctx, s.collection(accountCollection), bson.M{"name": name})
+}
+
+func (s *Store) ListAccounts(ctx context.Context, f store.AccountFilter, p store.Page) (*store.Result[store.Account], error) {
+	filter := bson.M{}
+	if f.NamePrefix != "" {
+		filter["name"] = prefix(f.NamePrefix)
+	}
+	if f.Type != "" {
+		filter["type"] = f.Type
+	}
+	if len(f.Ids) > 0 {
+		filter["_id"] = bson.M{"$in": f.Ids}
+	}
+	filter["deletedAt"] = bson.M{"$exists": f.Deleted}
+	return findPage(ctx, s.collection(accountCollection), store.AccountSorts, filter, p, func(a store.Account) primitive.ObjectID { return a.Id })
+}
+
+func (s *Store) UpdateAccount(ctx context.Context, a *store.Account) error {
+	set := bson.M{
+		"name":           a.Name,
+		"accountId":      a.AccountId,
+		"type":           a.Type,
+		"currency":       a.Currency,
+		"overdraft":      a.Overdraft,
+		"overdraftLimit": a.OverdraftLimit,
+		"overdraftRate":  a.OverdraftRate,
+		"limits":         a.Limits,
+		"status":         a.Status,
+	}
+	update := bson.M{"$set": set}
+	// Restored accounts lose the fields, so lists can tell deleted ones by deletedAt existing
+	if a.DeletedAt.IsZero() {
+		update["$unset"] = bson.M{"deletedAt": "", "deletedBy": ""}
+	} else {
+		set["deletedAt"], set["deletedBy"] = a.DeletedAt, a.DeletedBy
+	}
+	return matched(s.collection(accountCollection).UpdateOne(ctx, bson.M{"_id": a.Id}, update))
+}
+
+func (s *Store) IncAccountValue(ctx context.Context, id primitive.ObjectID, delta int) error {
+	return matched(s.collection(accountCollection).UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$inc": bson.M{"value": delta}}))
+}
+
+func (s *Store) IncAccountHeld(ctx context.Context, id primitive.ObjectID, delta int) error {
+	return matched(s.collection(accountCollection).UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$inc": bson.M{"held": delta}}))
+}
+
+func (s *Store) DeleteAccount(ctx context.Context, id primitive.ObjectID) error {
+	return deleted(s.collection(accountCollection).DeleteOne(ctx, bson.M{"_id": id}))
+}
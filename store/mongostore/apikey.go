@@ -0,0 +1,28 @@
+package mongostore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) CreateAPIKey(ctx context.Context, k *store.APIKey) error {
+	return insertOne(ctx, s.collection(apiKeyCollection), k)
+}
+
+func (s *Store) GetAPIKey(ctx context.Context, id primitive.ObjectID) (*store.APIKey, error) {
+	return findOne[store.APIKey](ctx, s.collection(apiKeyCollection), bson.M{"_id": id})
+}
+
+func (s *Store) GetAPIKeyByHash(ctx context.Context, hash string) (*store.APIKey, error) {
+	return findOne[store.APIKey](ctx, s.collection(apiKeyCollection), bson.M{"hash": hash})
+}
+
+func (s *Store) ListAPIKeys(ctx context.Context, p store.Page) (*store.Result[store.APIKey], error) {
+	return findPage(ctx, s.collection(apiKeyCollection), store.APIKeySorts, bson.M{}, p, func(k store.APIKey) primitive.ObjectID { return k.Id })
+}
+
+func (s *Store) DeleteAPIKey(ctx context.Context, id primitive.ObjectID) error {
+	return deleted(s.collection(apiKeyCollection).DeleteOne(ctx, bson.M{"_id": id}))
+}
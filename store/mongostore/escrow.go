@@ -0,0 +1,31 @@
+package mongostore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) CreateEscrow(ctx context.Context, e *store.Escrow) error {
+	return insertOne(ctx, s.collection(escrowCollection), e)
+}
+
+func (s *Store) GetEscrow(ctx context.Context, id primitive.ObjectID) (*store.Escrow, error) {
+	return findOne[store.Escrow](ctx, s.collection(escrowCollection), bson.M{"_id": id})
+}
+
+func (s *Store) ListEscrows(ctx context.Context, f store.EscrowFilter, p store.Page) (*store.Result[store.Escrow], error) {
+	filter := bson.M{}
+	if f.Account != "" {
+		filter["$or"] = bson.A{bson.M{"buyer": f.Account}, bson.M{"seller": f.Account}}
+	}
+	if f.Status != "" {
+		filter["status"] = f.Status
+	}
+	return findPage(ctx, s.collection(escrowCollection), store.EscrowSorts, filter, p, func(e store.Escrow) primitive.ObjectID { return e.Id })
+}
+
+func (s *Store) UpdateEscrow(ctx context.Context, e *store.Escrow) error {
+	return matched(s.collection(escrowCollection).ReplaceOne(ctx, bson.M{"_id": e.Id}, e))
+}
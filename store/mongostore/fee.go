@@ -0,0 +1,34 @@
+package mongostore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (s *Store) CreateFeeRule(ctx context.Context, r *store.FeeRule) error {
+	return insertOne(ctx, s.collection(feeRuleCollection), r)
+}
+
+func (s *Store) GetFeeRule(ctx context.Context, id primitive.ObjectID) (*store.FeeRule, error) {
+	return findOne[store.FeeRule](ctx, s.collection(feeRuleCollection), bson.M{"_id": id})
+}
+
+func (s *Store) ListFeeRules(ctx context.Context, p store.Page) (*store.Result[store.FeeRule], error) {
+	return findPage(ctx, s.collection(feeRuleCollection), store.FeeRuleSorts, bson.M{}, p, func(r store.FeeRule) primitive.ObjectID { return r.Id })
+}
+
+func (s *Store) FeeRulesFor(ctx context.Context, t, currency string) ([]store.FeeRule, error) {
+	return findAll[store.FeeRule](ctx, s.collection(feeRuleCollection), bson.M{"type": bson.M{"$in": bson.A{"", t}}, "currency": currency},
+		options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+}
+
+func (s *Store) UpdateFeeRule(ctx context.Context, r *store.FeeRule) error {
+	return matched(s.collection(feeRuleCollection).ReplaceOne(ctx, bson.M{"_id": r.Id}, r))
+}
+
+func (s *Store) DeleteFeeRule(ctx context.Context, id primitive.ObjectID) error {
+	return deleted(s.collection(feeRuleCollection).DeleteOne(ctx, bson.M{"_id": id}))
+}
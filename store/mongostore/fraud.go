@@ -0,0 +1,34 @@
+package mongostore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (s *Store) CreateFraudRule(ctx context.Context, r *store.FraudRule) error {
+	return insertOne(ctx, s.collection(fraudRuleCollection), r)
+}
+
+func (s *Store) GetFraudRule(ctx context.Context, id primitive.ObjectID) (*store.FraudRule, error) {
+	return findOne[store.FraudRule](ctx, s.collection(fraudRuleCollection), bson.M{"_id": id})
+}
+
+func (s *Store) ListFraudRules(ctx context.Context, p store.Page) (*store.Result[store.FraudRule], error) {
+	return findPage(ctx, s.collection(fraudRuleCollection), store.FraudRuleSorts, bson.M{}, p, func(r store.FraudRule) primitive.ObjectID { return r.Id })
+}
+
+func (s *Store) FraudRulesFor(ctx context.Context, currency string) ([]store.FraudRule, error) {
+	return findAll[store.FraudRule](ctx, s.collection(fraudRuleCollection), bson.M{"currency": currency},
+		options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+}
+
+func (s *Store) UpdateFraudRule(ctx context.Context, r *store.FraudRule) error {
+	return matched(s.collection(fraudRuleCollection).ReplaceOne(ctx, bson.M{"_id": r.Id}, r))
+}
+
+func (s *Store) DeleteFraudRule(ctx context.Context, id primitive.ObjectID) error {
+	return deleted(s.collection(fraudRuleCollection).DeleteOne(ctx, bson.M{"_id": id}))
+}
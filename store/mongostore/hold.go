@@ -0,0 +1,38 @@
+package mongostore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+func (s *Store) CreateHold(ctx context.Context, h *store.Hold) error {
+	return insertOne(ctx, s.collection(holdCollection), h)
+}
+
+func (s *Store) GetHold(ctx context.Context, id primitive.ObjectID) (*store.Hold, error) {
+	return findOne[store.Hold](ctx, s.collection(holdCollection), bson.M{"_id": id})
+}
+
+func (s *Store) ListHolds(ctx context.Context, f store.HoldFilter, p store.Page) (*store.Result[store.Hold], error) {
+	filter := bson.M{}
+	if f.Account != "" {
+		filter["account"] = f.Account
+	}
+	if f.Status != "" {
+		filter["status"] = f.Status
+	}
+	return findPage(ctx, s.collection(holdCollection), store.HoldSorts, filter, p, func(h store.Hold) primitive.ObjectID { return h.Id })
+}
+
+func (s *Store) ExpiredHolds(ctx context.Context, status string, now time.Time, limit int) ([]store.Hold, error) {
+	return findAll[store.Hold](ctx, s.collection(holdCollection), bson.M{"status": status, "expiresAt": bson.M{"$lte": now}},
+		options.Find().SetSort(bson.D{{Key: "expiresAt", Value: 1}}).SetLimit(int64(limit)))
+}
+
+func (s *Store) UpdateHold(ctx context.Context, h *store.Hold) error {
+	return matched(s.collection(holdCollection).ReplaceOne(ctx, bson.M{"_id": h.Id}, h))
+}
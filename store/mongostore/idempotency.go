@@ -0,0 +1,29 @@
+package mongostore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+func (s *Store) CreateIdempotencyRecord(ctx context.Context, r *store.IdempotencyRecord) error {
+	return insertOne(ctx, s.collection(idempotencyCollection), r)
+}
+
+func (s *Store) GetIdempotencyRecord(ctx context.Context, key string) (*store.IdempotencyRecord, error) {
+	return findOne[store.IdempotencyRecord](ctx, s.collection(idempotencyCollection), bson.M{"_id": key})
+}
+
+func (s *Store) CompleteIdempotencyRecord(ctx context.Context, key string, status int, body []byte) error {
+	return matched(s.collection(idempotencyCollection).UpdateOne(ctx, bson.M{"_id": key}, bson.M{"$set": bson.M{"status": status, "body": body}}))
+}
+
+func (s *Store) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	return deleted(s.collection(idempotencyCollection).DeleteOne(ctx, bson.M{"_id": key}))
+}
+
+func (s *Store) DeleteIdempotencyRecordsBefore(ctx context.Context, before time.Time) error {
+	_, err := s.collection(idempotencyCollection).DeleteMany(ctx, bson.M{"createdAt": bson.M{"$lt": before}})
+	return err
+}
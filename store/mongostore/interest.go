@@ -0,0 +1,51 @@
+package mongostore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+func (s *Store) CreateAccountType(ctx context.Context, t *store.AccountType) error {
+	return insertOne(ctx, s.collection(accountTypeCollection), t)
+}
+
+func (s *Store) GetAccountType(ctx context.Context, id primitive.ObjectID) (*store.AccountType, error) {
+	return findOne[store.AccountType](ctx, s.collection(accountTypeCollection), bson.M{"_id": id})
+}
+
+func (s *Store) GetAccountTypeByName(ctx context.Context, name string) (*store.AccountType, error) {
+	return findOne[store.AccountType](ctx, s.collection(accountTypeCollection), bson.M{"name": name})
+}
+
+func (s *Store) ListAccountTypes(ctx context.Context, p store.Page) (*store.Result[store.AccountType], error) {
+	return findPage(ctx, s.collection(accountTypeCollection), store.AccountTypeSorts, bson.M{}, p, func(t store.AccountType) primitive.ObjectID { return t.Id })
+}
+
+func (s *Store) UpdateAccountType(ctx context.Context, t *store.AccountType) error {
+	return matched(s.collection(accountTypeCollection).ReplaceOne(ctx, bson.M{"_id": t.Id}, t))
+}
+
+func (s *Store) CreateAccrual(ctx context.Context, a *store.Accrual) error {
+	return insertOne(ctx, s.collection(accrualCollection), a)
+}
+
+func (s *Store) GetAccrual(ctx context.Context, account primitive.ObjectID) (*store.Accrual, error) {
+	return findOne[store.Accrual](ctx, s.collection(accrualCollection), bson.M{"_id": account})
+}
+
+func (s *Store) DueAccruals(ctx context.Context, now time.Time, limit int) ([]store.Accrual, error) {
+	return findAll[store.Accrual](ctx, s.collection(accrualCollection), bson.M{"due": bson.M{"$lte": now}},
+		options.Find().SetSort(bson.D{{Key: "due", Value: 1}}).SetLimit(int64(limit)))
+}
+
+func (s *Store) UpdateAccrual(ctx context.Context, a *store.Accrual) error {
+	return matched(s.collection(accrualCollection).ReplaceOne(ctx, bson.M{"_id": a.Account}, a))
+}
+
+func (s *Store) DeleteAccrual(ctx context.Context, account primitive.ObjectID) error {
+	return deleted(s.collection(accrualCollection).DeleteOne(ctx, bson.M{"_id": account}))
+}
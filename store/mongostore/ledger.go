@@ -0,0 +1,49 @@
+package mongostore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (s *Store) CreatePostings(ctx context.Context, postings []store.Posting) error {
+	docs := make([]interface{}, len(postings))
+	for i := range postings {
+		docs[i] = postings[i]
+	}
+	_, err := s.collection(postingCollection).InsertMany(ctx, docs)
+	return err
+}
+
+func (s *Store) SumPostings(ctx context.Context, account primitive.ObjectID) (int, int, error) {
+	results, err := aggregate[struct {
+		Sum   int `bson:"sum"`
+		Count int `bson:"count"`
+	}](ctx, s.collection(postingCollection), bson.A{
+		bson.M{"$match": bson.M{"account": account}},
+		bson.M{"$group": bson.M{"_id": nil, "sum": bson.M{"$sum": "$amount"}, "count": bson.M{"$sum": 1}}},
+	})
+	if err != nil || len(results) == 0 {
+		return 0, 0, err
+	}
+	return results[0].Sum, results[0].Count, nil
+}
+
+func (s *Store) ListPostings(ctx context.Context, account primitive.ObjectID) ([]store.Posting, error) {
+	return findAll[store.Posting](ctx, s.collection(postingCollection), bson.M{"account": account},
+		options.Find().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "_id", Value: 1}}))
+}
+
+func (s *Store) ListLedger(ctx context.Context, account primitive.ObjectID, after store.LedgerEntry, limit int) ([]store.LedgerEntry, error) {
+	return aggregate[store.LedgerEntry](ctx, s.collection(postingCollection), bson.A{
+		bson.M{"$match": bson.M{"account": account, "$or": bson.A{
+			bson.M{"date": bson.M{"$gt": after.Date}},
+			bson.M{"date": after.Date, "transactionId": bson.M{"$gt": after.TransactionId}},
+		}}},
+		bson.M{"$group": bson.M{"_id": "$transactionId", "date": bson.M{"$min": "$date"}, "amount": bson.M{"$sum": "$amount"}}},
+		bson.M{"$sort": bson.D{{Key: "date", Value: 1}, {Key: "_id", Value: 1}}},
+		bson.M{"$limit": limit},
+	})
+}
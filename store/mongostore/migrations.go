@@ -0,0 +1,181 @@
+package mongostore
+
+import (
+	"context"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// migrationCollection records the name of every migration applied
+const migrationCollection = "schemaMigrations"
+
+// migration changes existing documents once, like the SQL migrations of sqlstore
+type migration struct {
+	name  string
+	apply func(ctx context.Context, s *Store) error
+}
+
+var migrations = []migration{
+	{"money", scaleMoney},
+}
+
+// migrate applies every migration not recorded yet, each in its own transaction
+func (s *Store) migrate(ctx context.Context) error {
+	for _, m := range migrations {
+		err := s.WithTx(ctx, func(ctx context.Context, ts store.Store) error {
+			tx := ts.(*Store)
+			n, err := tx.collection(migrationCollection).CountDocuments(ctx, bson.M{"_id": m.name})
+			if err != nil || n > 0 {
+				return err
+			}
+			if err := m.apply(ctx, tx); err != nil {
+				return err
+			}
+			_, err = tx.collection(migrationCollection).InsertOne(ctx, bson.M{"_id": m.name})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	log.Debugf("Mongo migrations are up to date")
+	return nil
+}
+
+// scaleMoney turns the whole units stored before values were minor units into minor units of the scale of their
+// currency. Postings are in the currency of their account, or of the legs they offset for system accounts, and the
+// balances are rebuilt from them. Standing orders, holds and escrows get the currency of their accounts
+func scaleMoney(ctx context.Context, s *Store) error {
+	var w store.WholeUnits
+	set := func(collection string, id any, fields bson.M) error {
+		_, err := s.collection(collection).UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": fields})
+		return err
+	}
+
+	accounts, err := findAll[store.Account](ctx, s.collection(accountCollection), bson.M{})
+	if err != nil {
+		return err
+	}
+	byID, byName := map[primitive.ObjectID]*store.Account{}, map[string]string{}
+	for i := range accounts {
+		byID[accounts[i].Id], byName[accounts[i].Name] = &accounts[i], accounts[i].Currency
+	}
+
+	transactions, err := findAll[store.Transaction](ctx, s.collection(transactionCollection), bson.M{})
+	if err != nil {
+		return err
+	}
+	byTransaction := map[primitive.ObjectID]*store.Transaction{}
+	for i := range transactions {
+		t := &transactions[i]
+		byTransaction[t.Id] = t
+		currency := store.FirstCurrency(t.Currency)
+		err := set(transactionCollection, t.Id, bson.M{"value": w.In(t.Value, currency), "refunded": w.In(t.Refunded, currency), "toValue": w.In(t.ToValue, t.ToCurrency)})
+		if err != nil {
+			return err
+		}
+	}
+
+	postings, err := findAll[store.Posting](ctx, s.collection(postingCollection), bson.M{})
+	if err != nil {
+		return err
+	}
+	// The postings of a transaction are scaled together, system accounts in the currency of the legs they offset
+	groups := map[primitive.ObjectID][]store.Posting{}
+	for _, p := range postings {
+		groups[p.TransactionId] = append(groups[p.TransactionId], p)
+	}
+	posted, scaled := map[primitive.ObjectID]int{}, map[primitive.ObjectID]int{}
+	for id, group := range groups {
+		legs := make([]store.Leg, len(group))
+		for i, p := range group {
+			var a store.Account
+			if acc, ok := byID[p.Account]; ok {
+				a = *acc
+			}
+			legs[i] = store.LegOf(p.Amount, a.Name, a.Currency)
+		}
+		fallback := ""
+		if t, ok := byTransaction[id]; ok {
+			fallback = t.Currency
+		}
+		for i, currency := range store.LegCurrencies(legs, fallback) {
+			p := group[i]
+			amount := w.In(p.Amount, currency)
+			posted[p.Account] += p.Amount
+			scaled[p.Account] += amount
+			if err := set(postingCollection, p.Id, bson.M{"amount": amount}); err != nil {
+				return err
+			}
+		}
+	}
+
+	// What the postings do not explain was there before the ledger, in the currency of the account
+	for _, a := range accounts {
+		err := set(accountCollection, a.Id, bson.M{
+			"value":          w.In(a.Value-posted[a.Id], a.Currency) + scaled[a.Id],
+			"held":           w.In(a.Held, a.Currency),
+			"overdraftLimit": w.In(a.OverdraftLimit, a.Currency),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	accruals, err := findAll[store.Accrual](ctx, s.collection(accrualCollection), bson.M{})
+	if err != nil {
+		return err
+	}
+	for _, acc := range accruals {
+		currency := ""
+		if a, ok := byID[acc.Account]; ok {
+			currency = a.Currency
+		}
+		if err := set(accrualCollection, acc.Account, bson.M{"paid": w.In(acc.Paid, currency), "charged": w.In(acc.Charged, currency)}); err != nil {
+			return err
+		}
+	}
+
+	orders, err := findAll[store.StandingOrder](ctx, s.collection(orderCollection), bson.M{})
+	if err != nil {
+		return err
+	}
+	for _, o := range orders {
+		currency := store.FirstCurrency(byName[o.ByWho], byName[o.ToWho])
+		if err := set(orderCollection, o.Id, bson.M{"currency": currency, "value": w.In(o.Value, currency)}); err != nil {
+			return err
+		}
+	}
+
+	holds, err := findAll[store.Hold](ctx, s.collection(holdCollection), bson.M{})
+	if err != nil {
+		return err
+	}
+	for _, h := range holds {
+		currency := store.FirstCurrency(byName[h.Account])
+		if err := set(holdCollection, h.Id, bson.M{"currency": currency, "value": w.In(h.Value, currency), "captured": w.In(h.Captured, currency)}); err != nil {
+			return err
+		}
+	}
+
+	escrows, err := findAll[store.Escrow](ctx, s.collection(escrowCollection), bson.M{})
+	if err != nil {
+		return err
+	}
+	for _, e := range escrows {
+		currency := store.FirstCurrency(byName[e.Buyer], byName[e.Seller])
+		err := set(escrowCollection, e.Id, bson.M{
+			"currency": currency,
+			"value":    w.In(e.Value, currency),
+			"toSeller": w.In(e.ToSeller, currency),
+			"toBuyer":  w.In(e.ToBuyer, currency),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	// Amounts out of range fail the whole migration, nothing is kept of it
+	return w.Err
+}
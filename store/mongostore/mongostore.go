@@ -0,0 +1,239 @@
+// Package mongostore is the MongoDB backend of store.Store
+package mongostore
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"regexp"
+)
+
+// Collection names, kept as they were before the store abstraction so existing data keeps working
+const (
+	accountCollection     = "account"
+	userCollection        = "user"
+	transactionCollection = "transactions"
+	postingCollection     = "postings"
+	idempotencyCollection = "idempotency"
+	orderCollection       = "standingOrders"
+	orderRunCollection    = "standingOrderRuns"
+	accountTypeCollection = "accountTypes"
+	accrualCollection     = "accruals"
+	holdCollection        = "holds"
+	escrowCollection      = "escrows"
+	rateCollection        = "exchangeRates"
+	feeRuleCollection     = "feeRules"
+	fraudRuleCollection   = "fraudRules"
+	refreshCollection     = "refreshTokens"
+	revocationCollection  = "revocations"
+	apiKeyCollection      = "apiKeys"
+)
+
+var _ store.Store = (*Store)(nil)
+
+// Store keeps every entity in its own collection of db
+type Store struct {
+	db *mongo.Database
+}
+
+// New wraps db, makes sure unique indexes exist and migrates old documents. Multi-document transactions need a replica set
+func New(db *mongo.Database) *Store {
+	s := &Store{db: db}
+	s.ensureIndexes(context.Background())
+	if err := s.migrate(context.Background()); err != nil {
+		log.Fatalf("Error migrating MongoDB: %v", err)
+	}
+	return s
+}
+
+func (s *Store) ensureIndexes(ctx context.Context) {
+	indexes := map[string][]mongo.IndexModel{
+		accountCollection: {
+			{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "type", Value: 1}}},
+		},
+		userCollection:        {{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)}},
+		accountTypeCollection: {{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)}},
+		feeRuleCollection:     {{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)}},
+		fraudRuleCollection:   {{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)}},
+		refreshCollection:     {{Keys: bson.D{{Key: "expiresAt", Value: 1}}}},
+		revocationCollection:  {{Keys: bson.D{{Key: "expiresAt", Value: 1}}}},
+		idempotencyCollection: {{Keys: bson.D{{Key: "createdAt", Value: 1}}}},
+		apiKeyCollection: {
+			{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		accrualCollection:  {{Keys: bson.D{{Key: "due", Value: 1}}}},
+		postingCollection:  {{Keys: bson.D{{Key: "account", Value: 1}, {Key: "date", Value: 1}}}},
+		orderCollection:    {{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextRun", Value: 1}}}},
+		orderRunCollection: {{Keys: bson.D{{Key: "orderId", Value: 1}, {Key: "date", Value: 1}}}},
+		holdCollection: {
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expiresAt", Value: 1}}},
+			{Keys: bson.D{{Key: "account", Value: 1}, {Key: "status", Value: 1}}},
+		},
+		escrowCollection: {
+			{Keys: bson.D{{Key: "buyer", Value: 1}}},
+			{Keys: bson.D{{Key: "seller", Value: 1}}},
+		},
+		rateCollection: {{Keys: bson.D{{Key: "from", Value: 1}, {Key: "to", Value: 1}, {Key: "createdAt", Value: -1}}}},
+		transactionCollection: {
+			{Keys: bson.D{{Key: "date", Value: 1}}},
+			{Keys: bson.D{{Key: "byWho", Value: 1}, {Key: "date", Value: 1}}},
+			{Keys: bson.D{{Key: "toWho", Value: 1}, {Key: "date", Value: 1}}},
+		},
+	}
+	for collection, models := range indexes {
+		if _, err := s.db.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			// Not fatal, old databases may hold duplicates. Handlers still check names before inserting
+			log.Warnf("Could not create indexes on %s: %v", collection, err)
+		}
+	}
+}
+
+func (s *Store) collection(name string) *mongo.Collection {
+	return s.db.Collection(name)
+}
+
+// WithTx runs fn inside a session transaction. WithTransaction retries fn on TransientTransactionError
+// and the commit on UnknownTransactionCommitResult
+func (s *Store) WithTx(ctx context.Context, fn func(ctx context.Context, s store.Store) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx, s)
+	}
+	session, err := s.db.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc, s)
+	})
+	return err
+}
+
+func (s *Store) Close(ctx context.Context) error {
+	return s.db.Client().Disconnect(ctx)
+}
+
+// findOne decodes the first document matching filter, mongo.ErrNoDocuments becomes store.ErrNotFound
+func findOne[T any](ctx context.Context, c *mongo.Collection, filter interface{}, opts ...*options.FindOneOptions) (*T, error) {
+	var result T
+	if err := c.FindOne(ctx, filter, opts...).Decode(&result); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, store.ErrNotFound
+		}
+		return nil, err
+	}
+	return &result, nil
+}
+
+// findAll decodes every document matching filter
+func findAll[T any](ctx context.Context, c *mongo.Collection, filter interface{}, opts ...*options.FindOptions) ([]T, error) {
+	cursor, err := c.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	// Ensure the cursor is closed after processing
+	defer func() {
+		if err := cursor.Close(ctx); err != nil {
+			log.Info("Error closing Mongo cursor, Error: " + err.Error())
+		}
+	}()
+	results := []T{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// findPage fetches the page p of the documents matching filter, keyset style: the cursor turns into a condition
+// on the sort field and _id so no document is skipped or repeated when others are inserted meanwhile
+func findPage[T any](ctx context.Context, c *mongo.Collection, sorts store.Sorts[T], filter bson.M, p store.Page, id func(T) primitive.ObjectID) (*store.Result[T], error) {
+	cursor, err := sorts.Check(&p)
+	if err != nil {
+		return nil, err
+	}
+	dir, op := 1, "$gt"
+	if p.Desc {
+		dir, op = -1, "$lt"
+	}
+	order := bson.D{{Key: "_id", Value: dir}}
+	if p.Sort != "" {
+		order = bson.D{{Key: p.Sort, Value: dir}, {Key: "_id", Value: dir}}
+	}
+	if cursor != nil {
+		after := bson.M{"_id": bson.M{op: cursor.Id}}
+		if p.Sort != "" {
+			after = bson.M{"$or": bson.A{
+				bson.M{p.Sort: bson.M{op: cursor.Key}},
+				bson.M{p.Sort: cursor.Key, "_id": bson.M{op: cursor.Id}},
+			}}
+		}
+		filter = bson.M{"$and": bson.A{filter, after}}
+	}
+	records, err := findAll[T](ctx, c, filter, options.Find().SetSort(order).SetLimit(int64(p.Limit+1)))
+	if err != nil {
+		return nil, err
+	}
+	return sorts.Page(p, records, id)
+}
+
+// prefix matches strings starting with value
+func prefix(value string) bson.M {
+	return bson.M{"$regex": "^" + regexp.QuoteMeta(value)}
+}
+
+// aggregate decodes every document the pipeline outputs
+func aggregate[T any](ctx context.Context, c *mongo.Collection, pipeline interface{}) ([]T, error) {
+	cursor, err := c.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	results := []T{}
+	// All closes the cursor
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// insertOne maps duplicate key errors to store.ErrDuplicate
+func insertOne(ctx context.Context, c *mongo.Collection, doc interface{}) error {
+	if _, err := c.InsertOne(ctx, doc); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return store.ErrDuplicate
+		}
+		return err
+	}
+	return nil
+}
+
+// matched turns an update without any matched document into store.ErrNotFound
+func matched(res *mongo.UpdateResult, err error) error {
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return store.ErrDuplicate
+		}
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// deleted turns a delete without any removed document into store.ErrNotFound
+func deleted(res *mongo.DeleteResult, err error) error {
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
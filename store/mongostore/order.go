@@ -0,0 +1,39 @@
+package mongostore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+func (s *Store) CreateStandingOrder(ctx context.Context, o *store.StandingOrder) error {
+	return insertOne(ctx, s.collection(orderCollection), o)
+}
+
+func (s *Store) GetStandingOrder(ctx context.Context, id primitive.ObjectID) (*store.StandingOrder, error) {
+	return findOne[store.StandingOrder](ctx, s.collection(orderCollection), bson.M{"_id": id})
+}
+
+func (s *Store) ListStandingOrders(ctx context.Context, p store.Page) (*store.Result[store.StandingOrder], error) {
+	return findPage(ctx, s.collection(orderCollection), store.StandingOrderSorts, bson.M{}, p, func(o store.StandingOrder) primitive.ObjectID { return o.Id })
+}
+
+func (s *Store) DueStandingOrders(ctx context.Context, status string, now time.Time, limit int) ([]store.StandingOrder, error) {
+	return findAll[store.StandingOrder](ctx, s.collection(orderCollection), bson.M{"status": status, "nextRun": bson.M{"$lte": now}},
+		options.Find().SetSort(bson.D{{Key: "nextRun", Value: 1}}).SetLimit(int64(limit)))
+}
+
+func (s *Store) UpdateStandingOrder(ctx context.Context, o *store.StandingOrder) error {
+	return matched(s.collection(orderCollection).ReplaceOne(ctx, bson.M{"_id": o.Id}, o))
+}
+
+func (s *Store) CreateStandingOrderRun(ctx context.Context, r *store.StandingOrderRun) error {
+	return insertOne(ctx, s.collection(orderRunCollection), r)
+}
+
+func (s *Store) ListStandingOrderRuns(ctx context.Context, order primitive.ObjectID, p store.Page) (*store.Result[store.StandingOrderRun], error) {
+	return findPage(ctx, s.collection(orderRunCollection), store.StandingOrderRunSorts, bson.M{"orderId": order}, p, func(r store.StandingOrderRun) primitive.ObjectID { return r.Id })
+}
@@ -0,0 +1,33 @@
+package mongostore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (s *Store) CreateExchangeRate(ctx context.Context, r *store.ExchangeRate) error {
+	return insertOne(ctx, s.collection(rateCollection), r)
+}
+
+func (s *Store) GetExchangeRate(ctx context.Context, id primitive.ObjectID) (*store.ExchangeRate, error) {
+	return findOne[store.ExchangeRate](ctx, s.collection(rateCollection), bson.M{"_id": id})
+}
+
+func (s *Store) LatestExchangeRate(ctx context.Context, from, to string) (*store.ExchangeRate, error) {
+	return findOne[store.ExchangeRate](ctx, s.collection(rateCollection), bson.M{"from": from, "to": to},
+		options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}))
+}
+
+func (s *Store) ListExchangeRates(ctx context.Context, f store.ExchangeRateFilter, p store.Page) (*store.Result[store.ExchangeRate], error) {
+	filter := bson.M{}
+	if f.From != "" {
+		filter["from"] = f.From
+	}
+	if f.To != "" {
+		filter["to"] = f.To
+	}
+	return findPage(ctx, s.collection(rateCollection), store.ExchangeRateSorts, filter, p, func(r store.ExchangeRate) primitive.ObjectID { return r.Id })
+}
@@ -0,0 +1,45 @@
+package mongostore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+func (s *Store) CreateRefreshToken(ctx context.Context, t *store.RefreshToken) error {
+	return insertOne(ctx, s.collection(refreshCollection), t)
+}
+
+func (s *Store) GetRefreshToken(ctx context.Context, hash string) (*store.RefreshToken, error) {
+	return findOne[store.RefreshToken](ctx, s.collection(refreshCollection), bson.M{"_id": hash})
+}
+
+func (s *Store) UseRefreshToken(ctx context.Context, hash string, at time.Time) error {
+	return matched(s.collection(refreshCollection).UpdateOne(ctx,
+		bson.M{"_id": hash, "usedAt": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"usedAt": at}}))
+}
+
+func (s *Store) Revoke(ctx context.Context, r *store.Revocation) error {
+	_, err := s.collection(revocationCollection).UpdateOne(ctx, bson.M{"_id": r.Id},
+		bson.M{"$max": bson.M{"expiresAt": r.ExpiresAt}}, options.Update().SetUpsert(true))
+	return err
+}
+
+func (s *Store) Revoked(ctx context.Context, ids ...string) (bool, error) {
+	if len(ids) == 0 {
+		return false, nil
+	}
+	n, err := s.collection(revocationCollection).CountDocuments(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	return n > 0, err
+}
+
+func (s *Store) DeleteExpiredTokens(ctx context.Context, now time.Time) error {
+	expired := bson.M{"expiresAt": bson.M{"$lt": now}}
+	if _, err := s.collection(refreshCollection).DeleteMany(ctx, expired); err != nil {
+		return err
+	}
+	_, err := s.collection(revocationCollection).DeleteMany(ctx, expired)
+	return err
+}
@@ -0,0 +1,89 @@
+package mongostore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) CreateTransaction(ctx context.Context, t *store.Transaction) error {
+	return insertOne(ctx, s.collection(transactionCollection), t)
+}
+
+func (s *Store) GetTransaction(ctx context.Context, id primitive.ObjectID) (*store.Transaction, error) {
+	return findOne[store.Transaction](ctx, s.collection(transactionCollection), bson.M{"_id": id})
+}
+
+func (s *Store) ListTransactions(ctx context.Context, f store.TransactionFilter, p store.Page) (*store.Result[store.Transaction], error) {
+	return findPage(ctx, s.collection(transactionCollection), store.TransactionSorts, transactionFilter(f), p, func(t store.Transaction) primitive.ObjectID { return t.Id })
+}
+
+func transactionFilter(f store.TransactionFilter) bson.M {
+	filter := bson.M{}
+	if len(f.Ids) > 0 {
+		filter["_id"] = bson.M{"$in": f.Ids}
+	}
+	if f.ByWho != "" {
+		filter["byWho"] = f.ByWho
+	}
+	if f.ToWho != "" {
+		filter["toWho"] = f.ToWho
+	}
+	if f.Account != "" {
+		filter["$or"] = bson.A{bson.M{"byWho": f.Account}, bson.M{"toWho": f.Account}}
+	}
+	status := bson.M{}
+	if f.Status != "" {
+		status["$eq"] = f.Status
+	}
+	if len(f.StatusNot) > 0 {
+		status["$nin"] = f.StatusNot
+	}
+	if len(status) > 0 {
+		filter["status"] = status
+	}
+	date := bson.M{}
+	if !f.From.IsZero() {
+		date["$gte"] = f.From
+	}
+	if !f.To.IsZero() {
+		date["$lt"] = f.To
+	}
+	if len(date) > 0 {
+		filter["date"] = date
+	}
+	return filter
+}
+
+func (s *Store) Spent(ctx context.Context, f store.SpendingFilter) (int, int, error) {
+	if len(f.Payers) == 0 || len(f.Types) == 0 {
+		return 0, 0, nil
+	}
+	match := bson.M{
+		"$or": bson.A{
+			bson.M{"value": bson.M{"$gte": 0}, "byWho": bson.M{"$in": f.Payers}},
+			bson.M{"value": bson.M{"$lt": 0}, "toWho": bson.M{"$in": f.Payers}},
+		},
+		"type": bson.M{"$in": f.Types},
+		"date": bson.M{"$gte": f.Since},
+	}
+	if len(f.StatusNot) > 0 {
+		match["status"] = bson.M{"$nin": f.StatusNot}
+	}
+	results, err := aggregate[struct {
+		Value int `bson:"value"`
+		Count int `bson:"count"`
+	}](ctx, s.collection(transactionCollection), bson.A{
+		bson.M{"$match": match},
+		bson.M{"$group": bson.M{"_id": nil, "value": bson.M{"$sum": bson.M{"$abs": "$value"}}, "count": bson.M{"$sum": 1}}},
+	})
+	if err != nil || len(results) == 0 {
+		return 0, 0, err
+	}
+	return results[0].Value, results[0].Count, nil
+}
+
+func (s *Store) UpdateTransaction(ctx context.Context, t *store.Transaction) error {
+	return matched(s.collection(transactionCollection).ReplaceOne(ctx, bson.M{"_id": t.Id}, t))
+}
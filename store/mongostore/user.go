@@ -0,0 +1,42 @@
+package mongostore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Store) CreateUser(ctx context.Context, u *store.User) error {
+	return insertOne(ctx, s.collection(userCollection), u)
+}
+
+func (s *Store) GetUser(ctx context.Context, id primitive.ObjectID) (*store.User, error) {
+	return findOne[store.User](ctx, s.collection(userCollection), bson.M{"_id": id})
+}
+
+func (s *Store) GetUserByName(ctx context.Context, name string) (*store.User, error) {
+	return findOne[store.User](ctx, s.collection(userCollection), bson.M{"name": name})
+}
+
+func (s *Store) GetUserByAccount(ctx context.Context, accountID string) (*store.User, error) {
+	// account is an array, equality matches any of its elements
+	return findOne[store.User](ctx, s.collection(userCollection), bson.M{"account": accountID})
+}
+
+func (s *Store) ListUsers(ctx context.Context, f store.UserFilter, p store.Page) (*store.Result[store.User], error) {
+	filter := bson.M{}
+	if f.NamePrefix != "" {
+		filter["name"] = prefix(f.NamePrefix)
+	}
+	filter["deletedAt"] = bson.M{"$exists": f.Deleted}
+	return findPage(ctx, s.collection(userCollection), store.UserSorts, filter, p, func(u store.User) primitive.ObjectID { return u.Id })
+}
+
+func (s *Store) UpdateUser(ctx context.Context, u *store.User) error {
+	return matched(s.collection(userCollection).ReplaceOne(ctx, bson.M{"_id": u.Id}, u))
+}
+
+func (s *Store) DeleteUser(ctx context.Context, id primitive.ObjectID) error {
+	return deleted(s.collection(userCollection).DeleteOne(ctx, bson.M{"_id": id}))
+}
@@ -0,0 +1,259 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Page size bounds
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// ErrInvalidPage is returned for an unknown sort field or a cursor that does not belong to the request
+var ErrInvalidPage = errors.New("store: invalid page")
+
+// Page selects at most Limit records following Cursor, ordered by Sort then by id. An empty Sort is creation order
+type Page struct {
+	Limit  int
+	Cursor string
+	Sort   string
+	Desc   bool
+}
+
+// Result is one page of records. Next is the cursor of the following page, empty on the last one
+type Result[T any] struct {
+	Data []T    `json:"data"`
+	Next string `json:"next,omitempty"`
+}
+
+// Filters of the lists, zero values match everything
+type (
+	// AccountFilter Ids keeps only those accounts when not empty
+	// AccountFilter and UserFilter leave deleted records out, Deleted lists only them instead
+	AccountFilter struct {
+		NamePrefix string
+		Type       string
+		Ids        []primitive.ObjectID
+		Deleted    bool
+	}
+	UserFilter struct {
+		NamePrefix string
+		Deleted    bool
+	}
+	// TransactionFilter matches From <= Date < To. Account matches either side of the transaction,
+	// StatusNot leaves out every status it lists and Ids keeps only those transactions when not empty
+	TransactionFilter struct {
+		Ids       []primitive.ObjectID
+		ByWho     string
+		ToWho     string
+		Account   string
+		Status    string
+		StatusNot []string
+		From      time.Time
+		To        time.Time
+	}
+	// SpendingFilter matches transactions of Types made since Since and paid by one of Payers, which is
+	// ByWho or ToWho for a negative value. StatusNot leaves out every status it lists
+	SpendingFilter struct {
+		Payers    []string
+		Types     []string
+		StatusNot []string
+		Since     time.Time
+	}
+	HoldFilter struct {
+		Account string
+		Status  string
+	}
+	// EscrowFilter Account matches either the buyer or the seller
+	EscrowFilter struct {
+		Account string
+		Status  string
+	}
+	ExchangeRateFilter struct {
+		From string
+		To   string
+	}
+)
+
+// Sorts maps the fields a list can be ordered by to the value they hold for a record: a string, an int or a time.Time.
+// Field names are the bson names, SQL columns are the same in snake_case
+type Sorts[T any] map[string]func(T) any
+
+var (
+	AccountSorts = Sorts[Account]{
+		"name":  func(a Account) any { return a.Name },
+		"value": func(a Account) any { return a.Value },
+	}
+	UserSorts = Sorts[User]{
+		"name": func(u User) any { return u.Name },
+	}
+	TransactionSorts = Sorts[Transaction]{
+		"date":  func(t Transaction) any { return t.Date },
+		"value": func(t Transaction) any { return t.Value },
+	}
+	AccountTypeSorts = Sorts[AccountType]{
+		"name": func(t AccountType) any { return t.Name },
+	}
+	HoldSorts = Sorts[Hold]{
+		"expiresAt": func(h Hold) any { return h.ExpiresAt },
+		"value":     func(h Hold) any { return h.Value },
+	}
+	EscrowSorts = Sorts[Escrow]{
+		"createdAt": func(e Escrow) any { return e.CreatedAt },
+		"value":     func(e Escrow) any { return e.Value },
+	}
+	FeeRuleSorts = Sorts[FeeRule]{
+		"name": func(r FeeRule) any { return r.Name },
+	}
+	FraudRuleSorts = Sorts[FraudRule]{
+		"name": func(r FraudRule) any { return r.Name },
+	}
+	APIKeySorts = Sorts[APIKey]{
+		"name":      func(k APIKey) any { return k.Name },
+		"createdAt": func(k APIKey) any { return k.CreatedAt },
+	}
+	ExchangeRateSorts = Sorts[ExchangeRate]{
+		"createdAt": func(r ExchangeRate) any { return r.CreatedAt },
+	}
+	StandingOrderSorts = Sorts[StandingOrder]{
+		"nextRun": func(o StandingOrder) any { return o.NextRun },
+	}
+	StandingOrderRunSorts = Sorts[StandingOrderRun]{
+		"date": func(r StandingOrderRun) any { return r.Date },
+	}
+)
+
+// Cursor is the position of the last record of a page, Key is nil when ordered by id only
+type Cursor struct {
+	Sort string             `json:"s,omitempty"`
+	Desc bool               `json:"d,omitempty"`
+	Key  any                `json:"k,omitempty"`
+	Id   primitive.ObjectID `json:"i"`
+}
+
+// Check validates p and normalizes its limit. The decoded cursor is nil on the first page
+func (sorts Sorts[T]) Check(p *Page) (*Cursor, error) {
+	if p.Limit <= 0 {
+		p.Limit = DefaultLimit
+	}
+	if p.Limit > MaxLimit {
+		p.Limit = MaxLimit
+	}
+	key, ok := sorts[p.Sort]
+	if p.Sort != "" && !ok {
+		return nil, ErrInvalidPage
+	}
+	if p.Cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(p.Cursor)
+	if err != nil {
+		return nil, ErrInvalidPage
+	}
+	var c struct {
+		Sort string             `json:"s"`
+		Desc bool               `json:"d"`
+		Key  json.RawMessage    `json:"k"`
+		Id   primitive.ObjectID `json:"i"`
+	}
+	if err := json.Unmarshal(raw, &c); err != nil || c.Sort != p.Sort || c.Desc != p.Desc {
+		return nil, ErrInvalidPage
+	}
+	cursor := &Cursor{Sort: c.Sort, Desc: c.Desc, Id: c.Id}
+	if p.Sort != "" {
+		// Decode the key into the type the field holds
+		var zero T
+		value := reflect.New(reflect.TypeOf(key(zero)))
+		if err := json.Unmarshal(c.Key, value.Interface()); err != nil {
+			return nil, ErrInvalidPage
+		}
+		cursor.Key = value.Elem().Interface()
+	}
+	return cursor, nil
+}
+
+// Page builds the result from the records a backend fetched, which may hold one record more than the limit
+// to tell whether another page follows
+func (sorts Sorts[T]) Page(p Page, records []T, id func(T) primitive.ObjectID) (*Result[T], error) {
+	if len(records) <= p.Limit {
+		return &Result[T]{Data: records}, nil
+	}
+	records = records[:p.Limit]
+	last := records[len(records)-1]
+	c := Cursor{Sort: p.Sort, Desc: p.Desc, Id: id(last)}
+	if p.Sort != "" {
+		c.Key = sorts[p.Sort](last)
+	}
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return &Result[T]{Data: records, Next: base64.RawURLEncoding.EncodeToString(raw)}, nil
+}
+
+// Slice orders records and cuts the page out of them in memory, for backends without a query language
+func (sorts Sorts[T]) Slice(p Page, records []T, id func(T) primitive.ObjectID) (*Result[T], error) {
+	cursor, err := sorts.Check(&p)
+	if err != nil {
+		return nil, err
+	}
+	position := func(r T) (any, primitive.ObjectID) {
+		if p.Sort == "" {
+			return nil, id(r)
+		}
+		return sorts[p.Sort](r), id(r)
+	}
+	less := func(aKey any, aID primitive.ObjectID, bKey any, bID primitive.ObjectID) bool {
+		c := CompareKeys(aKey, bKey)
+		if c == 0 {
+			c = strings.Compare(aID.Hex(), bID.Hex())
+		}
+		if p.Desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		aKey, aID := position(records[i])
+		bKey, bID := position(records[j])
+		return less(aKey, aID, bKey, bID)
+	})
+	if cursor != nil {
+		start := sort.Search(len(records), func(i int) bool {
+			key, recordID := position(records[i])
+			return less(cursor.Key, cursor.Id, key, recordID)
+		})
+		records = records[start:]
+	}
+	if len(records) > p.Limit+1 {
+		records = records[:p.Limit+1]
+	}
+	return sorts.Page(p, records, id)
+}
+
+// CompareKeys compares two sort keys of the same type, nil keys are equal
+func CompareKeys(a, b any) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case int:
+		b := b.(int)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	return 0
+}
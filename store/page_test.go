@@ -0,0 +1,137 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"slices"
+	"testing"
+	"time"
+)
+
+// accounts are 7 accounts with repeated values so ties are ordered by id
+func accounts() []Account {
+	values := []int{300, 100, 200, 100, 300, -50, 100}
+	list := make([]Account, len(values))
+	for i, v := range values {
+		list[i] = Account{Id: primitive.NewObjectID(), Name: fmt.Sprintf("acc%d", (i*5)%7), Value: v}
+	}
+	return list
+}
+
+func accountID(a Account) primitive.ObjectID { return a.Id }
+
+// names walks every page of the accounts and returns their names in the order they came
+func names(t *testing.T, p Page) []string {
+	t.Helper()
+	all := accounts()
+	// Backends hand their records in any order
+	slices.Reverse(all)
+	var got []string
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatalf("%+v does not end", p)
+		}
+		res, err := AccountSorts.Slice(p, slices.Clone(all), accountID)
+		if err != nil {
+			t.Fatalf("%+v: %v", p, err)
+		}
+		if len(res.Data) > p.Limit {
+			t.Fatalf("%+v answered %d records", p, len(res.Data))
+		}
+		for _, a := range res.Data {
+			got = append(got, a.Name)
+		}
+		if res.Next == "" {
+			return got
+		}
+		p.Cursor = res.Next
+	}
+}
+
+func TestSortsSlice(t *testing.T) {
+	tests := []struct {
+		sort  string
+		desc  bool
+		limit int
+		want  []string
+	}{
+		// Creation order is the order of the ids
+		{"", false, 3, []string{"acc0", "acc5", "acc3", "acc1", "acc6", "acc4", "acc2"}},
+		{"", true, 2, []string{"acc2", "acc4", "acc6", "acc1", "acc3", "acc5", "acc0"}},
+		{"name", false, 3, []string{"acc0", "acc1", "acc2", "acc3", "acc4", "acc5", "acc6"}},
+		{"name", true, 7, []string{"acc6", "acc5", "acc4", "acc3", "acc2", "acc1", "acc0"}},
+		// The 100s and the 300s tie, they keep the order of their ids both ways
+		{"value", false, 2, []string{"acc4", "acc5", "acc1", "acc2", "acc3", "acc0", "acc6"}},
+		{"value", true, 1, []string{"acc6", "acc0", "acc3", "acc2", "acc1", "acc5", "acc4"}},
+		{"value", false, 50, []string{"acc4", "acc5", "acc1", "acc2", "acc3", "acc0", "acc6"}},
+	}
+	for _, tt := range tests {
+		got := names(t, Page{Sort: tt.sort, Desc: tt.desc, Limit: tt.limit})
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("sort %q desc %v limit %d = %v, want %v", tt.sort, tt.desc, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestSortsTimeCursor(t *testing.T) {
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var list []Transaction
+	for i := 0; i < 5; i++ {
+		// Two transactions share every date but the last
+		list = append(list, Transaction{Id: primitive.NewObjectID(), Date: base.Add(time.Duration(i/2) * time.Nanosecond)})
+	}
+	p := Page{Sort: "date", Limit: 2}
+	var got []primitive.ObjectID
+	for {
+		res, err := TransactionSorts.Slice(p, slices.Clone(list), func(t Transaction) primitive.ObjectID { return t.Id })
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tr := range res.Data {
+			got = append(got, tr.Id)
+		}
+		if res.Next == "" {
+			break
+		}
+		p.Cursor = res.Next
+	}
+	want := []primitive.ObjectID{list[0].Id, list[1].Id, list[2].Id, list[3].Id, list[4].Id}
+	if !slices.Equal(got, want) {
+		t.Errorf("pages by date = %v, want %v", got, want)
+	}
+}
+
+func TestSortsCheck(t *testing.T) {
+	first, err := AccountSorts.Slice(Page{Sort: "value", Limit: 2}, accounts(), accountID)
+	if err != nil || first.Next == "" {
+		t.Fatalf("first page: %+v, %v", first, err)
+	}
+	tests := []struct {
+		name  string
+		page  Page
+		limit int
+		err   error
+	}{
+		{"defaults", Page{}, DefaultLimit, nil},
+		{"capped", Page{Limit: MaxLimit + 1}, MaxLimit, nil},
+		{"kept", Page{Limit: 10}, 10, nil},
+		{"next page", Page{Sort: "value", Limit: 2, Cursor: first.Next}, 2, nil},
+		{"unknown sort", Page{Sort: "accountId"}, 0, ErrInvalidPage},
+		{"other sort", Page{Sort: "name", Cursor: first.Next}, 0, ErrInvalidPage},
+		{"other direction", Page{Sort: "value", Desc: true, Cursor: first.Next}, 0, ErrInvalidPage},
+		{"not base64", Page{Sort: "value", Cursor: "!!"}, 0, ErrInvalidPage},
+		{"not json", Page{Sort: "value", Cursor: "bm9wZQ"}, 0, ErrInvalidPage},
+	}
+	for _, tt := range tests {
+		p := tt.page
+		_, err := AccountSorts.Check(&p)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err == nil && p.Limit != tt.limit {
+			t.Errorf("%s: limit = %d, want %d", tt.name, p.Limit, tt.limit)
+		}
+	}
+}
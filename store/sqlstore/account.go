@@ -0,0 +1,83 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const accountColumns = `id, name, value, held, currency, account_id, type, overdraft, overdraft_limit, overdraft_rate,
+	limit_per_transaction, limit_daily, limit_weekly, limit_hourly, status, deleted_at, deleted_by`
+
+func scanAccount(row scanner) (*store.Account, error) {
+	var a store.Account
+	var id string
+	var deletedAt sql.NullTime
+	if err := row.Scan(&id, &a.Name, &a.Value, &a.Held, &a.Currency, &a.AccountId, &a.Type, &a.Overdraft, &a.OverdraftLimit, &a.OverdraftRate,
+		&a.Limits.PerTransaction, &a.Limits.Daily, &a.Limits.Weekly, &a.Limits.Hourly, &a.Status, &deletedAt, &a.DeletedBy); err != nil {
+		return nil, err
+	}
+	a.DeletedAt = deletedAt.Time
+	var err error
+	a.Id, err = primitive.ObjectIDFromHex(id)
+	return &a, err
+}
+
+func (s *Store) CreateAccount(ctx context.Context, a *store.Account) error {
+	_, err := s.exec(ctx, `INSERT INTO accounts (`+accountColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.Id.Hex(), a.Name, a.Value, a.Held, a.Currency, a.AccountId, a.Type, a.Overdraft, a.OverdraftLimit, a.OverdraftRate,
+		a.Limits.PerTransaction, a.Limits.Daily, a.Limits.Weekly, a.Limits.Hourly, a.Status, nullTime(a.DeletedAt), a.DeletedBy)
+	return err
+}
+
+func (s *Store) GetAccount(ctx context.Context, id primitive.ObjectID) (*store.Account, error) {
+	return queryOne(ctx, s, scanAccount, `SELECT `+accountColumns+` FROM accounts WHERE id = ?`+s.forUpdate(), id.Hex())
+}
+
+func (s *Store) GetAccountByName(ctx context.Context, name string) (*store.Account, error) {
+	return queryOne(ctx, s, scanAccount, `SELECT `+accountColumns+` FROM accounts WHERE name = ?`+s.forUpdate(), name)
+}
+
+func (s *Store) ListAccounts(ctx context.Context, f store.AccountFilter, p store.Page) (*store.Result[store.Account], error) {
+	var w where
+	if f.NamePrefix != "" {
+		w.prefix("name", f.NamePrefix)
+	}
+	if f.Type != "" {
+		w.add("type = ?", f.Type)
+	}
+	if len(f.Ids) > 0 {
+		ids := make([]string, len(f.Ids))
+		for i, id := range f.Ids {
+			ids[i] = id.Hex()
+		}
+		w.add("id IN ("+placeholders(len(ids))+")", anys(ids)...)
+	}
+	if f.Deleted {
+		w.add("deleted_at IS NOT NULL")
+	} else {
+		w.add("deleted_at IS NULL")
+	}
+	return queryPage(ctx, s, store.AccountSorts, scanAccount, `SELECT `+accountColumns+` FROM accounts`, w, p, func(a store.Account) primitive.ObjectID { return a.Id })
+}
+
+func (s *Store) UpdateAccount(ctx context.Context, a *store.Account) error {
+	return s.execOne(ctx, `UPDATE accounts SET name = ?, currency = ?, account_id = ?, type = ?, overdraft = ?, overdraft_limit = ?, overdraft_rate = ?,
+		limit_per_transaction = ?, limit_daily = ?, limit_weekly = ?, limit_hourly = ?, status = ?,
+		deleted_at = ?, deleted_by = ? WHERE id = ?`,
+		a.Name, a.Currency, a.AccountId, a.Type, a.Overdraft, a.OverdraftLimit, a.OverdraftRate,
+		a.Limits.PerTransaction, a.Limits.Daily, a.Limits.Weekly, a.Limits.Hourly, a.Status, nullTime(a.DeletedAt), a.DeletedBy, a.Id.Hex())
+}
+
+func (s *Store) IncAccountValue(ctx context.Context, id primitive.ObjectID, delta int) error {
+	return s.execOne(ctx, `UPDATE accounts SET value = value + ? WHERE id = ?`, delta, id.Hex())
+}
+
+func (s *Store) IncAccountHeld(ctx context.Context, id primitive.ObjectID, delta int) error {
+	return s.execOne(ctx, `UPDATE accounts SET held = held + ? WHERE id = ?`, delta, id.Hex())
+}
+
+func (s *Store) DeleteAccount(ctx context.Context, id primitive.ObjectID) error {
+	return s.execOne(ctx, `DELETE FROM accounts WHERE id = ?`, id.Hex())
+}
@@ -0,0 +1,52 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+)
+
+const apiKeyColumns = `id, name, hash, scopes, allowed_ips, created_at, created_by, expires_at`
+
+func scanAPIKey(row scanner) (*store.APIKey, error) {
+	var k store.APIKey
+	var id, scopes, allowedIPs string
+	var expiresAt sql.NullTime
+	if err := row.Scan(&id, &k.Name, &k.Hash, &scopes, &allowedIPs, &k.CreatedAt, &k.CreatedBy, &expiresAt); err != nil {
+		return nil, err
+	}
+	if scopes != "" {
+		k.Scopes = strings.Split(scopes, ",")
+	}
+	if allowedIPs != "" {
+		k.AllowedIPs = strings.Split(allowedIPs, ",")
+	}
+	k.ExpiresAt = expiresAt.Time
+	return &k, parseIDs(hexID{id, &k.Id})
+}
+
+func (s *Store) CreateAPIKey(ctx context.Context, k *store.APIKey) error {
+	_, err := s.exec(ctx, `INSERT INTO api_keys (`+apiKeyColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		k.Id.Hex(), k.Name, k.Hash, strings.Join(k.Scopes, ","), strings.Join(k.AllowedIPs, ","), utc(k.CreatedAt),
+		k.CreatedBy, nullTime(k.ExpiresAt))
+	return err
+}
+
+func (s *Store) GetAPIKey(ctx context.Context, id primitive.ObjectID) (*store.APIKey, error) {
+	return queryOne(ctx, s, scanAPIKey, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = ?`, id.Hex())
+}
+
+func (s *Store) GetAPIKeyByHash(ctx context.Context, hash string) (*store.APIKey, error) {
+	return queryOne(ctx, s, scanAPIKey, `SELECT `+apiKeyColumns+` FROM api_keys WHERE hash = ?`, hash)
+}
+
+func (s *Store) ListAPIKeys(ctx context.Context, p store.Page) (*store.Result[store.APIKey], error) {
+	return queryPage(ctx, s, store.APIKeySorts, scanAPIKey, `SELECT `+apiKeyColumns+` FROM api_keys`, where{}, p,
+		func(k store.APIKey) primitive.ObjectID { return k.Id })
+}
+
+func (s *Store) DeleteAPIKey(ctx context.Context, id primitive.ObjectID) error {
+	return s.execOne(ctx, `DELETE FROM api_keys WHERE id = ?`, id.Hex())
+}
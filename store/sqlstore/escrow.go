@@ -0,0 +1,47 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const escrowColumns = `id, name, buyer, seller, value, currency, to_seller, to_buyer, transaction_id, status, created_at, closed_at`
+
+func scanEscrow(row scanner) (*store.Escrow, error) {
+	var e store.Escrow
+	var id, transactionID string
+	var closedAt sql.NullTime
+	if err := row.Scan(&id, &e.Name, &e.Buyer, &e.Seller, &e.Value, &e.Currency, &e.ToSeller, &e.ToBuyer, &transactionID, &e.Status, &e.CreatedAt, &closedAt); err != nil {
+		return nil, err
+	}
+	e.ClosedAt = closedAt.Time
+	return &e, parseIDs(hexID{id, &e.Id}, hexID{transactionID, &e.TransactionId})
+}
+
+func (s *Store) CreateEscrow(ctx context.Context, e *store.Escrow) error {
+	_, err := s.exec(ctx, `INSERT INTO escrows (`+escrowColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Id.Hex(), e.Name, e.Buyer, e.Seller, e.Value, e.Currency, e.ToSeller, e.ToBuyer, e.TransactionId.Hex(), e.Status, utc(e.CreatedAt), nullTime(e.ClosedAt))
+	return err
+}
+
+func (s *Store) GetEscrow(ctx context.Context, id primitive.ObjectID) (*store.Escrow, error) {
+	return queryOne(ctx, s, scanEscrow, `SELECT `+escrowColumns+` FROM escrows WHERE id = ?`+s.forUpdate(), id.Hex())
+}
+
+func (s *Store) ListEscrows(ctx context.Context, f store.EscrowFilter, p store.Page) (*store.Result[store.Escrow], error) {
+	var w where
+	if f.Account != "" {
+		w.add("(buyer = ? OR seller = ?)", f.Account, f.Account)
+	}
+	if f.Status != "" {
+		w.add("status = ?", f.Status)
+	}
+	return queryPage(ctx, s, store.EscrowSorts, scanEscrow, `SELECT `+escrowColumns+` FROM escrows`, w, p, func(e store.Escrow) primitive.ObjectID { return e.Id })
+}
+
+func (s *Store) UpdateEscrow(ctx context.Context, e *store.Escrow) error {
+	return s.execOne(ctx, `UPDATE escrows SET name = ?, buyer = ?, seller = ?, value = ?, currency = ?, to_seller = ?, to_buyer = ?, transaction_id = ?, status = ?, created_at = ?, closed_at = ? WHERE id = ?`,
+		e.Name, e.Buyer, e.Seller, e.Value, e.Currency, e.ToSeller, e.ToBuyer, e.TransactionId.Hex(), e.Status, utc(e.CreatedAt), nullTime(e.ClosedAt), e.Id.Hex())
+}
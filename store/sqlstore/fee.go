@@ -0,0 +1,129 @@
+package sqlstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const feeRuleColumns = `id, name, kind, type, currency, percent, flat, min_fee, max_fee`
+
+func scanFeeRule(row scanner) (*store.FeeRule, error) {
+	var r store.FeeRule
+	var id string
+	if err := row.Scan(&id, &r.Name, &r.Kind, &r.Type, &r.Currency, &r.Percent, &r.Flat, &r.Min, &r.Max); err != nil {
+		return nil, err
+	}
+	return &r, parseIDs(hexID{id, &r.Id})
+}
+
+// withTiers loads the tiers of every rule
+func (s *Store) withTiers(ctx context.Context, rules []store.FeeRule) ([]store.FeeRule, error) {
+	for i := range rules {
+		tiers, err := queryAll(ctx, s, func(row scanner) (*store.FeeTier, error) {
+			var t store.FeeTier
+			return &t, row.Scan(&t.From, &t.Percent, &t.Flat)
+		}, `SELECT from_value, percent, flat FROM fee_rule_tiers WHERE rule_id = ? ORDER BY position`, rules[i].Id.Hex())
+		if err != nil {
+			return nil, err
+		}
+		rules[i].Tiers = tiers
+	}
+	return rules, nil
+}
+
+// putTiers replaces the tiers of r
+func (s *Store) putTiers(ctx context.Context, r *store.FeeRule) error {
+	if _, err := s.exec(ctx, `DELETE FROM fee_rule_tiers WHERE rule_id = ?`, r.Id.Hex()); err != nil {
+		return err
+	}
+	for i, t := range r.Tiers {
+		if _, err := s.exec(ctx, `INSERT INTO fee_rule_tiers (rule_id, position, from_value, percent, flat) VALUES (?, ?, ?, ?, ?)`,
+			r.Id.Hex(), i, t.From, t.Percent, t.Flat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) CreateFeeRule(ctx context.Context, r *store.FeeRule) error {
+	return s.WithTx(ctx, func(ctx context.Context, ts store.Store) error {
+		tx := ts.(*Store)
+		if _, err := tx.exec(ctx, `INSERT INTO fee_rules (`+feeRuleColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			r.Id.Hex(), r.Name, r.Kind, r.Type, r.Currency, r.Percent, r.Flat, r.Min, r.Max); err != nil {
+			return err
+		}
+		return tx.putTiers(ctx, r)
+	})
+}
+
+func (s *Store) GetFeeRule(ctx context.Context, id primitive.ObjectID) (*store.FeeRule, error) {
+	r, err := queryOne(ctx, s, scanFeeRule, `SELECT `+feeRuleColumns+` FROM fee_rules WHERE id = ?`, id.Hex())
+	if err != nil {
+		return nil, err
+	}
+	rules, err := s.withTiers(ctx, []store.FeeRule{*r})
+	if err != nil {
+		return nil, err
+	}
+	return &rules[0], nil
+}
+
+func (s *Store) ListFeeRules(ctx context.Context, p store.Page) (*store.Result[store.FeeRule], error) {
+	result, err := queryPage(ctx, s, store.FeeRuleSorts, scanFeeRule, `SELECT `+feeRuleColumns+` FROM fee_rules`, where{}, p,
+		func(r store.FeeRule) primitive.ObjectID { return r.Id })
+	if err != nil {
+		return nil, err
+	}
+	result.Data, err = s.withTiers(ctx, result.Data)
+	return result, err
+}
+
+func (s *Store) FeeRulesFor(ctx context.Context, t, currency string) ([]store.FeeRule, error) {
+	rules, err := queryAll(ctx, s, scanFeeRule, `SELECT `+feeRuleColumns+` FROM fee_rules WHERE type IN ('', ?) AND currency = ? ORDER BY name`, t, currency)
+	if err != nil {
+		return nil, err
+	}
+	return s.withTiers(ctx, rules)
+}
+
+func (s *Store) UpdateFeeRule(ctx context.Context, r *store.FeeRule) error {
+	return s.WithTx(ctx, func(ctx context.Context, ts store.Store) error {
+		tx := ts.(*Store)
+		if err := tx.execOne(ctx, `UPDATE fee_rules SET name = ?, kind = ?, type = ?, currency = ?, percent = ?, flat = ?, min_fee = ?, max_fee = ? WHERE id = ?`,
+			r.Name, r.Kind, r.Type, r.Currency, r.Percent, r.Flat, r.Min, r.Max, r.Id.Hex()); err != nil {
+			return err
+		}
+		return tx.putTiers(ctx, r)
+	})
+}
+
+func (s *Store) DeleteFeeRule(ctx context.Context, id primitive.ObjectID) error {
+	return s.execOne(ctx, `DELETE FROM fee_rules WHERE id = ?`, id.Hex())
+}
+
+// withFees loads the fees paid with every transaction
+func (s *Store) withFees(ctx context.Context, transactions []store.Transaction) ([]store.Transaction, error) {
+	for i := range transactions {
+		fees, err := queryAll(ctx, s, func(row scanner) (*store.Fee, error) {
+			var f store.Fee
+			return &f, row.Scan(&f.Rule, &f.Kind, &f.Value, &f.Account)
+		}, `SELECT rule, kind, value, account FROM transaction_fees WHERE transaction_id = ? ORDER BY position`, transactions[i].Id.Hex())
+		if err != nil {
+			return nil, err
+		}
+		transactions[i].Fees = fees
+	}
+	return transactions, nil
+}
+
+// putFees records the fees paid with t, they never change afterwards
+func (s *Store) putFees(ctx context.Context, t *store.Transaction) error {
+	for i, f := range t.Fees {
+		if _, err := s.exec(ctx, `INSERT INTO transaction_fees (transaction_id, position, rule, kind, value, account) VALUES (?, ?, ?, ?, ?, ?)`,
+			t.Id.Hex(), i, f.Rule, f.Kind, f.Value, f.Account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package sqlstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const fraudRuleColumns = `id, name, "check", action, currency, value, count, "window"`
+
+func scanFraudRule(row scanner) (*store.FraudRule, error) {
+	var r store.FraudRule
+	var id string
+	if err := row.Scan(&id, &r.Name, &r.Check, &r.Action, &r.Currency, &r.Value, &r.Count, &r.Window); err != nil {
+		return nil, err
+	}
+	return &r, parseIDs(hexID{id, &r.Id})
+}
+
+func (s *Store) CreateFraudRule(ctx context.Context, r *store.FraudRule) error {
+	_, err := s.exec(ctx, `INSERT INTO fraud_rules (`+fraudRuleColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.Id.Hex(), r.Name, r.Check, r.Action, r.Currency, r.Value, r.Count, r.Window)
+	return err
+}
+
+func (s *Store) GetFraudRule(ctx context.Context, id primitive.ObjectID) (*store.FraudRule, error) {
+	return queryOne(ctx, s, scanFraudRule, `SELECT `+fraudRuleColumns+` FROM fraud_rules WHERE id = ?`, id.Hex())
+}
+
+func (s *Store) ListFraudRules(ctx context.Context, p store.Page) (*store.Result[store.FraudRule], error) {
+	return queryPage(ctx, s, store.FraudRuleSorts, scanFraudRule, `SELECT `+fraudRuleColumns+` FROM fraud_rules`, where{}, p,
+		func(r store.FraudRule) primitive.ObjectID { return r.Id })
+}
+
+func (s *Store) FraudRulesFor(ctx context.Context, currency string) ([]store.FraudRule, error) {
+	return queryAll(ctx, s, scanFraudRule, `SELECT `+fraudRuleColumns+` FROM fraud_rules WHERE currency = ? ORDER BY name`, currency)
+}
+
+func (s *Store) UpdateFraudRule(ctx context.Context, r *store.FraudRule) error {
+	return s.execOne(ctx, `UPDATE fraud_rules SET name = ?, "check" = ?, action = ?, currency = ?, value = ?, count = ?, "window" = ? WHERE id = ?`,
+		r.Name, r.Check, r.Action, r.Currency, r.Value, r.Count, r.Window, r.Id.Hex())
+}
+
+func (s *Store) DeleteFraudRule(ctx context.Context, id primitive.ObjectID) error {
+	return s.execOne(ctx, `DELETE FROM fraud_rules WHERE id = ?`, id.Hex())
+}
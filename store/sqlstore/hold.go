@@ -0,0 +1,50 @@
+package sqlstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+const holdColumns = `id, name, account, value, currency, captured, to_who, transaction_id, created_at, expires_at, status`
+
+func scanHold(row scanner) (*store.Hold, error) {
+	var h store.Hold
+	var id, transactionID string
+	if err := row.Scan(&id, &h.Name, &h.Account, &h.Value, &h.Currency, &h.Captured, &h.ToWho, &transactionID, &h.CreatedAt, &h.ExpiresAt, &h.Status); err != nil {
+		return nil, err
+	}
+	return &h, parseIDs(hexID{id, &h.Id}, hexID{transactionID, &h.TransactionId})
+}
+
+func (s *Store) CreateHold(ctx context.Context, h *store.Hold) error {
+	_, err := s.exec(ctx, `INSERT INTO holds (`+holdColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		h.Id.Hex(), h.Name, h.Account, h.Value, h.Currency, h.Captured, h.ToWho, optionalID(h.TransactionId), utc(h.CreatedAt), utc(h.ExpiresAt), h.Status)
+	return err
+}
+
+func (s *Store) GetHold(ctx context.Context, id primitive.ObjectID) (*store.Hold, error) {
+	return queryOne(ctx, s, scanHold, `SELECT `+holdColumns+` FROM holds WHERE id = ?`+s.forUpdate(), id.Hex())
+}
+
+func (s *Store) ListHolds(ctx context.Context, f store.HoldFilter, p store.Page) (*store.Result[store.Hold], error) {
+	var w where
+	if f.Account != "" {
+		w.add("account = ?", f.Account)
+	}
+	if f.Status != "" {
+		w.add("status = ?", f.Status)
+	}
+	return queryPage(ctx, s, store.HoldSorts, scanHold, `SELECT `+holdColumns+` FROM holds`, w, p, func(h store.Hold) primitive.ObjectID { return h.Id })
+}
+
+func (s *Store) ExpiredHolds(ctx context.Context, status string, now time.Time, limit int) ([]store.Hold, error) {
+	return queryAll(ctx, s, scanHold, `SELECT `+holdColumns+` FROM holds WHERE status = ? AND expires_at <= ? ORDER BY expires_at LIMIT ?`,
+		status, utc(now), limit)
+}
+
+func (s *Store) UpdateHold(ctx context.Context, h *store.Hold) error {
+	return s.execOne(ctx, `UPDATE holds SET name = ?, account = ?, value = ?, currency = ?, captured = ?, to_who = ?, transaction_id = ?, created_at = ?, expires_at = ?, status = ? WHERE id = ?`,
+		h.Name, h.Account, h.Value, h.Currency, h.Captured, h.ToWho, optionalID(h.TransactionId), utc(h.CreatedAt), utc(h.ExpiresAt), h.Status, h.Id.Hex())
+}
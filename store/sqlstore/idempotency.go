@@ -0,0 +1,42 @@
+package sqlstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"time"
+)
+
+const idempotencyColumns = `key, request_hash, status, body, created_at`
+
+func scanIdempotencyRecord(row scanner) (*store.IdempotencyRecord, error) {
+	var r store.IdempotencyRecord
+	var body string
+	if err := row.Scan(&r.Key, &r.RequestHash, &r.Status, &body, &r.CreatedAt); err != nil {
+		return nil, err
+	}
+	r.Body = []byte(body)
+	return &r, nil
+}
+
+func (s *Store) CreateIdempotencyRecord(ctx context.Context, r *store.IdempotencyRecord) error {
+	_, err := s.exec(ctx, `INSERT INTO idempotency_keys (`+idempotencyColumns+`) VALUES (?, ?, ?, ?, ?)`,
+		r.Key, r.RequestHash, r.Status, string(r.Body), utc(r.CreatedAt))
+	return err
+}
+
+func (s *Store) GetIdempotencyRecord(ctx context.Context, key string) (*store.IdempotencyRecord, error) {
+	return queryOne(ctx, s, scanIdempotencyRecord, `SELECT `+idempotencyColumns+` FROM idempotency_keys WHERE key = ?`, key)
+}
+
+func (s *Store) CompleteIdempotencyRecord(ctx context.Context, key string, status int, body []byte) error {
+	return s.execOne(ctx, `UPDATE idempotency_keys SET status = ?, body = ? WHERE key = ?`, status, string(body), key)
+}
+
+func (s *Store) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	return s.execOne(ctx, `DELETE FROM idempotency_keys WHERE key = ?`, key)
+}
+
+func (s *Store) DeleteIdempotencyRecordsBefore(ctx context.Context, before time.Time) error {
+	_, err := s.exec(ctx, `DELETE FROM idempotency_keys WHERE created_at < ?`, utc(before))
+	return err
+}
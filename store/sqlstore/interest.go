@@ -0,0 +1,77 @@
+package sqlstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+const accountTypeColumns = `id, name, rate, compound, posting, limit_per_transaction, limit_daily, limit_weekly, limit_hourly`
+
+func scanAccountType(row scanner) (*store.AccountType, error) {
+	var t store.AccountType
+	var id string
+	if err := row.Scan(&id, &t.Name, &t.Rate, &t.Compound, &t.Posting, &t.Limits.PerTransaction, &t.Limits.Daily, &t.Limits.Weekly, &t.Limits.Hourly); err != nil {
+		return nil, err
+	}
+	return &t, parseIDs(hexID{id, &t.Id})
+}
+
+func (s *Store) CreateAccountType(ctx context.Context, t *store.AccountType) error {
+	_, err := s.exec(ctx, `INSERT INTO account_types (`+accountTypeColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, t.Id.Hex(), t.Name, t.Rate, t.Compound, t.Posting,
+		t.Limits.PerTransaction, t.Limits.Daily, t.Limits.Weekly, t.Limits.Hourly)
+	return err
+}
+
+func (s *Store) GetAccountType(ctx context.Context, id primitive.ObjectID) (*store.AccountType, error) {
+	return queryOne(ctx, s, scanAccountType, `SELECT `+accountTypeColumns+` FROM account_types WHERE id = ?`, id.Hex())
+}
+
+func (s *Store) GetAccountTypeByName(ctx context.Context, name string) (*store.AccountType, error) {
+	return queryOne(ctx, s, scanAccountType, `SELECT `+accountTypeColumns+` FROM account_types WHERE name = ?`, name)
+}
+
+func (s *Store) ListAccountTypes(ctx context.Context, p store.Page) (*store.Result[store.AccountType], error) {
+	return queryPage(ctx, s, store.AccountTypeSorts, scanAccountType, `SELECT `+accountTypeColumns+` FROM account_types`, where{}, p,
+		func(t store.AccountType) primitive.ObjectID { return t.Id })
+}
+
+func (s *Store) UpdateAccountType(ctx context.Context, t *store.AccountType) error {
+	return s.execOne(ctx, `UPDATE account_types SET name = ?, rate = ?, compound = ?, posting = ?,
+		limit_per_transaction = ?, limit_daily = ?, limit_weekly = ?, limit_hourly = ? WHERE id = ?`,
+		t.Name, t.Rate, t.Compound, t.Posting, t.Limits.PerTransaction, t.Limits.Daily, t.Limits.Weekly, t.Limits.Hourly, t.Id.Hex())
+}
+
+const accrualColumns = `account_id, through, due, paid, charged`
+
+func scanAccrual(row scanner) (*store.Accrual, error) {
+	var a store.Accrual
+	var account string
+	if err := row.Scan(&account, &a.Through, &a.Due, &a.Paid, &a.Charged); err != nil {
+		return nil, err
+	}
+	return &a, parseIDs(hexID{account, &a.Account})
+}
+
+func (s *Store) CreateAccrual(ctx context.Context, a *store.Accrual) error {
+	_, err := s.exec(ctx, `INSERT INTO accruals (`+accrualColumns+`) VALUES (?, ?, ?, ?, ?)`, a.Account.Hex(), utc(a.Through), utc(a.Due), a.Paid, a.Charged)
+	return err
+}
+
+func (s *Store) GetAccrual(ctx context.Context, account primitive.ObjectID) (*store.Accrual, error) {
+	return queryOne(ctx, s, scanAccrual, `SELECT `+accrualColumns+` FROM accruals WHERE account_id = ?`+s.forUpdate(), account.Hex())
+}
+
+func (s *Store) DueAccruals(ctx context.Context, now time.Time, limit int) ([]store.Accrual, error) {
+	return queryAll(ctx, s, scanAccrual, `SELECT `+accrualColumns+` FROM accruals WHERE due <= ? ORDER BY due LIMIT ?`, utc(now), limit)
+}
+
+func (s *Store) UpdateAccrual(ctx context.Context, a *store.Accrual) error {
+	return s.execOne(ctx, `UPDATE accruals SET through = ?, due = ?, paid = ?, charged = ? WHERE account_id = ?`,
+		utc(a.Through), utc(a.Due), a.Paid, a.Charged, a.Account.Hex())
+}
+
+func (s *Store) DeleteAccrual(ctx context.Context, account primitive.ObjectID) error {
+	return s.execOne(ctx, `DELETE FROM accruals WHERE account_id = ?`, account.Hex())
+}
@@ -0,0 +1,58 @@
+package sqlstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const postingColumns = `id, transaction_id, account_id, amount, date`
+
+func scanPosting(row scanner) (*store.Posting, error) {
+	var p store.Posting
+	var id, transactionID, accountID string
+	if err := row.Scan(&id, &transactionID, &accountID, &p.Amount, &p.Date); err != nil {
+		return nil, err
+	}
+	return &p, parseIDs(hexID{id, &p.Id}, hexID{transactionID, &p.TransactionId}, hexID{accountID, &p.Account})
+}
+
+func (s *Store) CreatePostings(ctx context.Context, postings []store.Posting) error {
+	return s.WithTx(ctx, func(ctx context.Context, ts store.Store) error {
+		tx := ts.(*Store)
+		for _, p := range postings {
+			if _, err := tx.exec(ctx, `INSERT INTO postings (`+postingColumns+`) VALUES (?, ?, ?, ?, ?)`,
+				p.Id.Hex(), p.TransactionId.Hex(), p.Account.Hex(), p.Amount, utc(p.Date)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) SumPostings(ctx context.Context, account primitive.ObjectID) (int, int, error) {
+	var sum, count int
+	err := s.q.QueryRowContext(ctx, s.rebind(`SELECT COALESCE(SUM(amount), 0), COUNT(*) FROM postings WHERE account_id = ?`), account.Hex()).Scan(&sum, &count)
+	return sum, count, err
+}
+
+func (s *Store) ListPostings(ctx context.Context, account primitive.ObjectID) ([]store.Posting, error) {
+	return queryAll(ctx, s, scanPosting, `SELECT `+postingColumns+` FROM postings WHERE account_id = ? ORDER BY date, id`, account.Hex())
+}
+
+func scanLedgerEntry(row scanner) (*store.LedgerEntry, error) {
+	var e store.LedgerEntry
+	var transactionID string
+	if err := row.Scan(&transactionID, &e.Date, &e.Amount); err != nil {
+		return nil, err
+	}
+	return &e, parseIDs(hexID{transactionID, &e.TransactionId})
+}
+
+func (s *Store) ListLedger(ctx context.Context, account primitive.ObjectID, after store.LedgerEntry, limit int) ([]store.LedgerEntry, error) {
+	// The postings of a transaction share its date, so grouping by both keeps one entry per transaction
+	return queryAll(ctx, s, scanLedgerEntry, `SELECT transaction_id, date, SUM(amount) FROM postings
+		WHERE account_id = ? AND (date > ? OR (date = ? AND transaction_id > ?))
+		GROUP BY transaction_id, date ORDER BY date, transaction_id LIMIT ?`,
+		account.Hex(), utc(after.Date), utc(after.Date), after.TransactionId.Hex(), limit)
+}
@@ -0,0 +1,34 @@
+-- Accounts, users and transactions. Types are kept portable between SQLite and PostgreSQL
+CREATE TABLE accounts (
+    id         TEXT PRIMARY KEY,
+    name       TEXT   NOT NULL UNIQUE,
+    value      BIGINT NOT NULL DEFAULT 0,
+    account_id TEXT   NOT NULL
+);
+
+CREATE TABLE users (
+    id        TEXT PRIMARY KEY,
+    name      TEXT NOT NULL UNIQUE,
+    object_id TEXT NOT NULL
+);
+
+-- users.Account keeps its order through position
+CREATE TABLE user_accounts (
+    user_id    TEXT    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    position   INTEGER NOT NULL,
+    account_id TEXT    NOT NULL,
+    PRIMARY KEY (user_id, position)
+);
+CREATE INDEX user_accounts_account_id ON user_accounts (account_id);
+
+CREATE TABLE transactions (
+    id      TEXT PRIMARY KEY,
+    value   BIGINT    NOT NULL,
+    name_tz TEXT      NOT NULL,
+    date    TIMESTAMP NOT NULL,
+    status  TEXT      NOT NULL,
+    by_who  TEXT      NOT NULL,
+    to_who  TEXT      NOT NULL
+);
+CREATE INDEX transactions_by_who ON transactions (by_who);
+CREATE INDEX transactions_to_who ON transactions (to_who);
@@ -0,0 +1,10 @@
+-- Double-entry ledger, accounts.value becomes the cached sum of the account postings
+CREATE TABLE postings (
+    id             TEXT PRIMARY KEY,
+    transaction_id TEXT      NOT NULL,
+    account_id     TEXT      NOT NULL REFERENCES accounts (id),
+    amount         BIGINT    NOT NULL,
+    date           TIMESTAMP NOT NULL
+);
+CREATE INDEX postings_account_id ON postings (account_id, date);
+CREATE INDEX postings_transaction_id ON postings (transaction_id);
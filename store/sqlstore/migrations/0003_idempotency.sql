@@ -0,0 +1,8 @@
+-- Responses kept for requests sent with an Idempotency-Key header
+CREATE TABLE idempotency_keys (
+    key          TEXT PRIMARY KEY,
+    request_hash TEXT      NOT NULL,
+    status       INTEGER   NOT NULL,
+    body         TEXT      NOT NULL,
+    created_at   TIMESTAMP NOT NULL
+);
@@ -0,0 +1,5 @@
+-- Reversals and refunds link to the transaction they compensate
+ALTER TABLE transactions ADD COLUMN type TEXT NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN original_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN refunded BIGINT NOT NULL DEFAULT 0;
+CREATE INDEX transactions_original_id ON transactions (original_id);
@@ -0,0 +1,4 @@
+-- Indexes for the paginated and filtered lists
+CREATE INDEX transactions_date ON transactions (date, id);
+CREATE INDEX transactions_status ON transactions (status, date);
+CREATE INDEX accounts_value ON accounts (value, id);
@@ -0,0 +1,30 @@
+-- Scheduled and recurring transfers with a record of every execution
+CREATE TABLE standing_orders (
+    id         TEXT PRIMARY KEY,
+    name       TEXT      NOT NULL,
+    by_who     TEXT      NOT NULL,
+    to_who     TEXT      NOT NULL,
+    value      BIGINT    NOT NULL,
+    schedule_interval TEXT NOT NULL,
+    schedule_cron     TEXT NOT NULL,
+    start_at   TIMESTAMP NOT NULL,
+    end_at     TIMESTAMP,
+    max_runs   INTEGER   NOT NULL,
+    runs       INTEGER   NOT NULL,
+    retries    INTEGER   NOT NULL,
+    missed     INTEGER   NOT NULL,
+    next_run   TIMESTAMP NOT NULL,
+    status     TEXT      NOT NULL,
+    last_error TEXT      NOT NULL
+);
+CREATE INDEX standing_orders_due ON standing_orders (status, next_run);
+
+CREATE TABLE standing_order_runs (
+    id             TEXT PRIMARY KEY,
+    order_id       TEXT      NOT NULL REFERENCES standing_orders (id),
+    transaction_id TEXT      NOT NULL,
+    date           TIMESTAMP NOT NULL,
+    status         TEXT      NOT NULL,
+    error          TEXT      NOT NULL
+);
+CREATE INDEX standing_order_runs_order_id ON standing_order_runs (order_id, date);
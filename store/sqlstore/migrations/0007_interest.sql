@@ -0,0 +1,17 @@
+-- Account types with their interest rate and the interest paid so far to every account
+ALTER TABLE accounts ADD COLUMN type TEXT NOT NULL DEFAULT '';
+CREATE INDEX accounts_type ON accounts (type);
+
+CREATE TABLE account_types (
+    id       TEXT PRIMARY KEY,
+    name     TEXT    NOT NULL UNIQUE,
+    rate     INTEGER NOT NULL,
+    compound BOOLEAN NOT NULL,
+    posting  TEXT    NOT NULL
+);
+
+CREATE TABLE accruals (
+    account_id TEXT PRIMARY KEY,
+    through    TIMESTAMP NOT NULL,
+    paid       BIGINT    NOT NULL
+);
@@ -0,0 +1,8 @@
+-- Overdraft policy of every account and the overdraft interest charged so far
+ALTER TABLE accounts ADD COLUMN overdraft TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN overdraft_limit BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN overdraft_rate INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE accruals ADD COLUMN due TIMESTAMP;
+ALTER TABLE accruals ADD COLUMN charged BIGINT NOT NULL DEFAULT 0;
+UPDATE accruals SET due = through;
+CREATE INDEX accruals_due ON accruals (due);
@@ -0,0 +1,17 @@
+-- Funds reserved on accounts until they are captured, released or expire
+ALTER TABLE accounts ADD COLUMN held BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE holds (
+    id             TEXT PRIMARY KEY,
+    name           TEXT      NOT NULL,
+    account        TEXT      NOT NULL,
+    value          BIGINT    NOT NULL,
+    captured       BIGINT    NOT NULL,
+    to_who         TEXT      NOT NULL,
+    transaction_id TEXT      NOT NULL,
+    created_at     TIMESTAMP NOT NULL,
+    expires_at     TIMESTAMP NOT NULL,
+    status         TEXT      NOT NULL
+);
+CREATE INDEX holds_expiry ON holds (status, expires_at);
+CREATE INDEX holds_account ON holds (account, status);
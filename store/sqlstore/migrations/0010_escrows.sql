@@ -0,0 +1,16 @@
+-- Trades kept in the escrow account until they are released, refunded or split
+CREATE TABLE escrows (
+    id             TEXT PRIMARY KEY,
+    name           TEXT      NOT NULL,
+    buyer          TEXT      NOT NULL,
+    seller         TEXT      NOT NULL,
+    value          BIGINT    NOT NULL,
+    to_seller      BIGINT    NOT NULL,
+    to_buyer       BIGINT    NOT NULL,
+    transaction_id TEXT      NOT NULL,
+    status         TEXT      NOT NULL,
+    created_at     TIMESTAMP NOT NULL,
+    closed_at      TIMESTAMP
+);
+CREATE INDEX escrows_buyer ON escrows (buyer);
+CREATE INDEX escrows_seller ON escrows (seller);
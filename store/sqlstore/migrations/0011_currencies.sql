@@ -0,0 +1,16 @@
+-- Currency of every account and transaction, conversions and the exchange rates they used
+ALTER TABLE accounts ADD COLUMN currency TEXT NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN currency TEXT NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN to_currency TEXT NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN to_value BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN rate TEXT NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN rate_id TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE exchange_rates (
+    id            TEXT PRIMARY KEY,
+    from_currency TEXT      NOT NULL,
+    to_currency   TEXT      NOT NULL,
+    rate          TEXT      NOT NULL,
+    created_at    TIMESTAMP NOT NULL
+);
+CREATE INDEX exchange_rates_pair ON exchange_rates (from_currency, to_currency, created_at);
@@ -0,0 +1,5 @@
+-- Values are minor units from now on, the Go step of this migration scales the whole units stored before by the
+-- scale of their currency. Standing orders, holds and escrows get the currency of their accounts there too
+ALTER TABLE standing_orders ADD COLUMN currency TEXT NOT NULL DEFAULT '';
+ALTER TABLE holds ADD COLUMN currency TEXT NOT NULL DEFAULT '';
+ALTER TABLE escrows ADD COLUMN currency TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,32 @@
+-- Fee and tax rules and the fees paid with every transaction
+CREATE TABLE fee_rules (
+    id       TEXT PRIMARY KEY,
+    name     TEXT    NOT NULL UNIQUE,
+    kind     TEXT    NOT NULL,
+    type     TEXT    NOT NULL,
+    currency TEXT    NOT NULL,
+    percent  INTEGER NOT NULL,
+    flat     BIGINT  NOT NULL,
+    min_fee  BIGINT  NOT NULL,
+    max_fee  BIGINT  NOT NULL
+);
+
+-- fee_rules.Tiers keeps its order through position
+CREATE TABLE fee_rule_tiers (
+    rule_id    TEXT    NOT NULL REFERENCES fee_rules (id) ON DELETE CASCADE,
+    position   INTEGER NOT NULL,
+    from_value BIGINT  NOT NULL,
+    percent    INTEGER NOT NULL,
+    flat       BIGINT  NOT NULL,
+    PRIMARY KEY (rule_id, position)
+);
+
+CREATE TABLE transaction_fees (
+    transaction_id TEXT    NOT NULL REFERENCES transactions (id) ON DELETE CASCADE,
+    position       INTEGER NOT NULL,
+    rule           TEXT    NOT NULL,
+    kind           TEXT    NOT NULL,
+    value          BIGINT  NOT NULL,
+    account        TEXT    NOT NULL,
+    PRIMARY KEY (transaction_id, position)
+);
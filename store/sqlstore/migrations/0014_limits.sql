@@ -0,0 +1,9 @@
+-- Spending limits of account types and the overrides of single accounts, 0 is no limit
+ALTER TABLE account_types ADD COLUMN limit_per_transaction BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE account_types ADD COLUMN limit_daily BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE account_types ADD COLUMN limit_weekly BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE account_types ADD COLUMN limit_hourly INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN limit_per_transaction BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN limit_daily BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN limit_weekly BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN limit_hourly INTEGER NOT NULL DEFAULT 0;
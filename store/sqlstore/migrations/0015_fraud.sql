@@ -0,0 +1,13 @@
+-- Fraud rules and the rules that sent a transaction to review, kept comma separated
+CREATE TABLE fraud_rules (
+    id       TEXT PRIMARY KEY,
+    name     TEXT    NOT NULL UNIQUE,
+    "check"  TEXT    NOT NULL,
+    action   TEXT    NOT NULL,
+    currency TEXT    NOT NULL,
+    value    BIGINT  NOT NULL,
+    count    INTEGER NOT NULL,
+    "window" TEXT    NOT NULL
+);
+
+ALTER TABLE transactions ADD COLUMN flags TEXT NOT NULL DEFAULT '';
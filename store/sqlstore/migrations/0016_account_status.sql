@@ -0,0 +1,2 @@
+-- Lifecycle state of every account, empty for existing ones which are active
+ALTER TABLE accounts ADD COLUMN status TEXT NOT NULL DEFAULT '';
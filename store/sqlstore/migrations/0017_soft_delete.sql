@@ -0,0 +1,5 @@
+-- Users and accounts are deleted softly, then purged once nothing refers to them
+ALTER TABLE accounts ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE accounts ADD COLUMN deleted_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN deleted_by TEXT NOT NULL DEFAULT '';
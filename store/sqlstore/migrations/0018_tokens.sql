@@ -0,0 +1,17 @@
+-- Refresh tokens by the hash of their value, and the access tokens and families revoked before they expire
+CREATE TABLE refresh_tokens (
+    hash       TEXT PRIMARY KEY,
+    family     TEXT      NOT NULL,
+    user_id    TEXT      NOT NULL,
+    role       TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at    TIMESTAMP
+);
+CREATE INDEX refresh_tokens_expires ON refresh_tokens (expires_at);
+
+CREATE TABLE revocations (
+    id         TEXT PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
+CREATE INDEX revocations_expires ON revocations (expires_at);
@@ -0,0 +1,11 @@
+-- API keys by the hash of their value, scopes and allowed addresses kept comma separated
+CREATE TABLE api_keys (
+    id          TEXT PRIMARY KEY,
+    name        TEXT      NOT NULL UNIQUE,
+    hash        TEXT      NOT NULL UNIQUE,
+    scopes      TEXT      NOT NULL,
+    allowed_ips TEXT      NOT NULL DEFAULT '',
+    created_at  TIMESTAMP NOT NULL,
+    created_by  TEXT      NOT NULL,
+    expires_at  TIMESTAMP
+);
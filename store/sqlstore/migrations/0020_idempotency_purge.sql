@@ -0,0 +1,2 @@
+-- Expired idempotency keys are purged by the scheduler
+CREATE INDEX idempotency_keys_created ON idempotency_keys (created_at);
@@ -0,0 +1,160 @@
+package sqlstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+)
+
+// scaleMoney turns the whole units stored before values were minor units into minor units of the scale of their
+// currency. It reads only the columns of 0012, later migrations add the others. Postings are in the currency of their
+// account, or of the legs they offset for system accounts, and the balances are rebuilt from them
+func scaleMoney(ctx context.Context, s *Store) error {
+	var w store.WholeUnits
+
+	type account struct {
+		id, name, currency          string
+		value, held, overdraftLimit int
+		posted, scaled              int
+	}
+	accounts, err := queryAll(ctx, s, func(row scanner) (*account, error) {
+		var a account
+		return &a, row.Scan(&a.id, &a.name, &a.currency, &a.value, &a.held, &a.overdraftLimit)
+	}, `SELECT id, name, currency, value, held, overdraft_limit FROM accounts`)
+	if err != nil {
+		return err
+	}
+	byID, byName := map[string]*account{}, map[string]string{}
+	for i := range accounts {
+		byID[accounts[i].id], byName[accounts[i].name] = &accounts[i], accounts[i].currency
+	}
+
+	type transaction struct {
+		id string
+		store.Transaction
+	}
+	transactions, err := queryAll(ctx, s, func(row scanner) (*transaction, error) {
+		var t transaction
+		return &t, row.Scan(&t.id, &t.Currency, &t.ToCurrency, &t.Value, &t.Refunded, &t.ToValue)
+	}, `SELECT id, currency, to_currency, value, refunded, to_value FROM transactions`)
+	if err != nil {
+		return err
+	}
+	currencies := map[string]*store.Transaction{}
+	for i := range transactions {
+		t := &transactions[i]
+		currencies[t.id] = &t.Transaction
+		currency := store.FirstCurrency(t.Currency)
+		_, err := s.exec(ctx, `UPDATE transactions SET value = ?, refunded = ?, to_value = ? WHERE id = ?`,
+			w.In(t.Value, currency), w.In(t.Refunded, currency), w.In(t.ToValue, t.ToCurrency), t.id)
+		if err != nil {
+			return err
+		}
+	}
+
+	type posting struct {
+		id, transaction, account string
+		amount                   int
+	}
+	postings, err := queryAll(ctx, s, func(row scanner) (*posting, error) {
+		var p posting
+		return &p, row.Scan(&p.id, &p.transaction, &p.account, &p.amount)
+	}, `SELECT id, transaction_id, account_id, amount FROM postings ORDER BY transaction_id, id`)
+	if err != nil {
+		return err
+	}
+	// The postings of a transaction are scaled together, system accounts in the currency of the legs they offset
+	for start := 0; start < len(postings); {
+		end := start + 1
+		for end < len(postings) && postings[end].transaction == postings[start].transaction {
+			end++
+		}
+		group := postings[start:end]
+		start = end
+		legs := make([]store.Leg, len(group))
+		for i, p := range group {
+			a := byID[p.account]
+			legs[i] = store.LegOf(p.amount, a.name, a.currency)
+		}
+		fallback := ""
+		if t, ok := currencies[group[0].transaction]; ok {
+			fallback = t.Currency
+		}
+		for i, currency := range store.LegCurrencies(legs, fallback) {
+			p, a := group[i], byID[group[i].account]
+			amount := w.In(p.amount, currency)
+			a.posted, a.scaled = a.posted+p.amount, a.scaled+amount
+			_, err := s.exec(ctx, `UPDATE postings SET amount = ? WHERE id = ?`, amount, p.id)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// What the postings do not explain was there before the ledger, in the currency of the account
+	for _, a := range accounts {
+		_, err := s.exec(ctx, `UPDATE accounts SET value = ?, held = ?, overdraft_limit = ? WHERE id = ?`,
+			w.In(a.value-a.posted, a.currency)+a.scaled, w.In(a.held, a.currency), w.In(a.overdraftLimit, a.currency), a.id)
+		if err != nil {
+			return err
+		}
+	}
+
+	type accrual struct {
+		account       string
+		paid, charged int
+	}
+	accruals, err := queryAll(ctx, s, func(row scanner) (*accrual, error) {
+		var a accrual
+		return &a, row.Scan(&a.account, &a.paid, &a.charged)
+	}, `SELECT account_id, paid, charged FROM accruals`)
+	if err != nil {
+		return err
+	}
+	for _, a := range accruals {
+		currency := ""
+		if acc, ok := byID[a.account]; ok {
+			currency = acc.currency
+		}
+		_, err := s.exec(ctx, `UPDATE accruals SET paid = ?, charged = ? WHERE account_id = ?`,
+			w.In(a.paid, currency), w.In(a.charged, currency), a.account)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Standing orders, holds and escrows are in the currency of the accounts they name
+	type record struct {
+		id, payer, payee string
+		values           [3]int
+	}
+	records := []struct {
+		query, update string
+		values        int
+	}{
+		{`SELECT id, by_who, to_who, value, 0, 0 FROM standing_orders`, `UPDATE standing_orders SET currency = ?, value = ? WHERE id = ?`, 1},
+		{`SELECT id, account, '', value, captured, 0 FROM holds`, `UPDATE holds SET currency = ?, value = ?, captured = ? WHERE id = ?`, 2},
+		{`SELECT id, buyer, seller, value, to_seller, to_buyer FROM escrows`, `UPDATE escrows SET currency = ?, value = ?, to_seller = ?, to_buyer = ? WHERE id = ?`, 3},
+	}
+	for _, r := range records {
+		rows, err := queryAll(ctx, s, func(row scanner) (*record, error) {
+			var rec record
+			return &rec, row.Scan(&rec.id, &rec.payer, &rec.payee, &rec.values[0], &rec.values[1], &rec.values[2])
+		}, r.query)
+		if err != nil {
+			return err
+		}
+		for _, rec := range rows {
+			currency := store.FirstCurrency(byName[rec.payer], byName[rec.payee])
+			args := []any{currency}
+			for _, v := range rec.values[:r.values] {
+				args = append(args, w.In(v, currency))
+			}
+			_, err := s.exec(ctx, r.update, append(args, rec.id)...)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	// Amounts out of range fail the whole migration, nothing is kept of it
+	return w.Err
+}
@@ -0,0 +1,121 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"github.com/vovamod/BankAPI/money"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+)
+
+// beforeMoney opens an SQLite database migrated up to the last version that kept whole units
+func beforeMoney(t *testing.T) *Store {
+	t.Helper()
+	db, err := sql.Open(SQLite, withSQLiteDefaults(t.TempDir()+"/bank.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	s := &Store{db: db, q: db, dialect: SQLite}
+
+	// Later versions are recorded as applied so migrate stops before them
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := []string{`CREATE TABLE schema_migrations (version TEXT PRIMARY KEY)`}
+	for _, file := range files {
+		if version := strings.TrimSuffix(strings.TrimPrefix(file, "migrations/"), ".sql"); version >= "0012" {
+			later = append(later, `INSERT INTO schema_migrations (version) VALUES ('`+version+`')`)
+		}
+	}
+	for _, statement := range later {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`DELETE FROM schema_migrations WHERE version >= '0012'`); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestScaleMoneyConversion(t *testing.T) {
+	if err := money.Configure("COIN:2,GEM:0", "COIN"); err != nil {
+		t.Fatal(err)
+	}
+	defer money.Configure("", "COIN")
+	s := beforeMoney(t)
+	ctx := context.Background()
+
+	// alice was funded with 100 COIN and converted 40 of them into 10 GEM for bob, paying 1 COIN of fee
+	statements := []string{
+		`INSERT INTO accounts (id, name, value, account_id, currency) VALUES ('issuer', 'BANK_ISSUER', -70, 'i', ''),
+			('fees', 'BANK_FEES', 1, 'f', ''), ('alice', 'alice', 59, 'a', 'COIN'), ('bob', 'bob', 10, 'b', 'GEM')`,
+		`INSERT INTO transactions (id, value, name_tz, date, status, by_who, to_who, currency, to_currency, to_value) VALUES
+			('open', 100, 'Opening', ?, 'Completed', 'BANK_ISSUER', 'alice', 'COIN', '', 0),
+			('convert', 40, 'Trade', ?, 'Completed', 'alice', 'bob', 'COIN', 'GEM', 10)`,
+		`INSERT INTO postings (id, transaction_id, account_id, amount, date) VALUES
+			('p1', 'open', 'issuer', -100, ?), ('p2', 'open', 'alice', 100, ?),
+			('p3', 'convert', 'alice', -40, ?), ('p4', 'convert', 'issuer', 40, ?), ('p5', 'convert', 'issuer', -10, ?),
+			('p6', 'convert', 'bob', 10, ?), ('p7', 'convert', 'alice', -1, ?), ('p8', 'convert', 'fees', 1, ?)`,
+	}
+	now := utc(time.Now())
+	for _, statement := range statements {
+		args := make([]any, strings.Count(statement, "?"))
+		for i := range args {
+			args[i] = now
+		}
+		if _, err := s.exec(ctx, statement, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	postings := []struct {
+		id, currency string
+		want         int
+	}{
+		{"p1", "COIN", -10000}, {"p2", "COIN", 10000},
+		{"p3", "COIN", -4000}, {"p4", "COIN", 4000}, {"p5", "GEM", -10}, {"p6", "GEM", 10}, {"p7", "COIN", -100}, {"p8", "COIN", 100},
+	}
+	sums := map[string]int{}
+	for _, p := range postings {
+		var amount int
+		if err := s.q.QueryRowContext(ctx, `SELECT amount FROM postings WHERE id = ?`, p.id).Scan(&amount); err != nil {
+			t.Fatal(err)
+		}
+		if amount != p.want {
+			t.Errorf("posting %s = %d, want %d", p.id, amount, p.want)
+		}
+		sums[p.currency] += amount
+	}
+	for currency, sum := range sums {
+		if sum != 0 {
+			t.Errorf("postings in %s sum to %d, want 0", currency, sum)
+		}
+	}
+
+	// Every balance is still the sum of its postings
+	rows, err := queryAll(ctx, s, func(row scanner) (*[2]int, error) {
+		var r [2]int
+		var id string
+		return &r, row.Scan(&id, &r[0], &r[1])
+	}, `SELECT a.id, a.value, COALESCE(SUM(p.amount), 0) FROM accounts a LEFT JOIN postings p ON p.account_id = a.id GROUP BY a.id, a.value`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rows {
+		if r[0] != r[1] {
+			t.Errorf("balance %d, postings sum to %d", r[0], r[1])
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+const orderColumns = `id, name, by_who, to_who, value, currency, schedule_interval, schedule_cron, start_at, end_at, max_runs, runs, retries, missed, next_run, status, last_error`
+
+func scanOrder(row scanner) (*store.StandingOrder, error) {
+	var o store.StandingOrder
+	var id string
+	var endAt sql.NullTime
+	if err := row.Scan(&id, &o.Name, &o.ByWho, &o.ToWho, &o.Value, &o.Currency, &o.Interval, &o.Cron, &o.StartAt, &endAt,
+		&o.MaxRuns, &o.Runs, &o.Retries, &o.Missed, &o.NextRun, &o.Status, &o.LastError); err != nil {
+		return nil, err
+	}
+	o.EndAt = endAt.Time
+	return &o, parseIDs(hexID{id, &o.Id})
+}
+
+// nullTime stores a zero time as NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: utc(t), Valid: !t.IsZero()}
+}
+
+func (s *Store) CreateStandingOrder(ctx context.Context, o *store.StandingOrder) error {
+	_, err := s.exec(ctx, `INSERT INTO standing_orders (`+orderColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		o.Id.Hex(), o.Name, o.ByWho, o.ToWho, o.Value, o.Currency, o.Interval, o.Cron, utc(o.StartAt), nullTime(o.EndAt),
+		o.MaxRuns, o.Runs, o.Retries, o.Missed, utc(o.NextRun), o.Status, o.LastError)
+	return err
+}
+
+func (s *Store) GetStandingOrder(ctx context.Context, id primitive.ObjectID) (*store.StandingOrder, error) {
+	return queryOne(ctx, s, scanOrder, `SELECT `+orderColumns+` FROM standing_orders WHERE id = ?`+s.forUpdate(), id.Hex())
+}
+
+func (s *Store) ListStandingOrders(ctx context.Context, p store.Page) (*store.Result[store.StandingOrder], error) {
+	return queryPage(ctx, s, store.StandingOrderSorts, scanOrder, `SELECT `+orderColumns+` FROM standing_orders`, where{}, p,
+		func(o store.StandingOrder) primitive.ObjectID { return o.Id })
+}
+
+func (s *Store) DueStandingOrders(ctx context.Context, status string, now time.Time, limit int) ([]store.StandingOrder, error) {
+	return queryAll(ctx, s, scanOrder, `SELECT `+orderColumns+` FROM standing_orders WHERE status = ? AND next_run <= ? ORDER BY next_run LIMIT ?`,
+		status, utc(now), limit)
+}
+
+func (s *Store) UpdateStandingOrder(ctx context.Context, o *store.StandingOrder) error {
+	return s.execOne(ctx, `UPDATE standing_orders SET name = ?, by_who = ?, to_who = ?, value = ?, currency = ?, schedule_interval = ?, schedule_cron = ?,
+		start_at = ?, end_at = ?, max_runs = ?, runs = ?, retries = ?, missed = ?, next_run = ?, status = ?, last_error = ? WHERE id = ?`,
+		o.Name, o.ByWho, o.ToWho, o.Value, o.Currency, o.Interval, o.Cron, utc(o.StartAt), nullTime(o.EndAt),
+		o.MaxRuns, o.Runs, o.Retries, o.Missed, utc(o.NextRun), o.Status, o.LastError, o.Id.Hex())
+}
+
+const orderRunColumns = `id, order_id, transaction_id, date, status, error`
+
+func scanOrderRun(row scanner) (*store.StandingOrderRun, error) {
+	var r store.StandingOrderRun
+	var id, orderID, transactionID string
+	if err := row.Scan(&id, &orderID, &transactionID, &r.Date, &r.Status, &r.Error); err != nil {
+		return nil, err
+	}
+	return &r, parseIDs(hexID{id, &r.Id}, hexID{orderID, &r.OrderId}, hexID{transactionID, &r.TransactionId})
+}
+
+func (s *Store) CreateStandingOrderRun(ctx context.Context, r *store.StandingOrderRun) error {
+	_, err := s.exec(ctx, `INSERT INTO standing_order_runs (`+orderRunColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+		r.Id.Hex(), r.OrderId.Hex(), optionalID(r.TransactionId), utc(r.Date), r.Status, r.Error)
+	return err
+}
+
+func (s *Store) ListStandingOrderRuns(ctx context.Context, order primitive.ObjectID, p store.Page) (*store.Result[store.StandingOrderRun], error) {
+	var w where
+	w.add("order_id = ?", order.Hex())
+	return queryPage(ctx, s, store.StandingOrderRunSorts, scanOrderRun, `SELECT `+orderRunColumns+` FROM standing_order_runs`, w, p,
+		func(r store.StandingOrderRun) primitive.ObjectID { return r.Id })
+}
@@ -0,0 +1,43 @@
+package sqlstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const rateColumns = `id, from_currency, to_currency, rate, created_at`
+
+func scanRate(row scanner) (*store.ExchangeRate, error) {
+	var r store.ExchangeRate
+	var id string
+	if err := row.Scan(&id, &r.From, &r.To, &r.Rate, &r.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &r, parseIDs(hexID{id, &r.Id})
+}
+
+func (s *Store) CreateExchangeRate(ctx context.Context, r *store.ExchangeRate) error {
+	_, err := s.exec(ctx, `INSERT INTO exchange_rates (`+rateColumns+`) VALUES (?, ?, ?, ?, ?)`, r.Id.Hex(), r.From, r.To, r.Rate, utc(r.CreatedAt))
+	return err
+}
+
+func (s *Store) GetExchangeRate(ctx context.Context, id primitive.ObjectID) (*store.ExchangeRate, error) {
+	return queryOne(ctx, s, scanRate, `SELECT `+rateColumns+` FROM exchange_rates WHERE id = ?`, id.Hex())
+}
+
+func (s *Store) LatestExchangeRate(ctx context.Context, from, to string) (*store.ExchangeRate, error) {
+	return queryOne(ctx, s, scanRate, `SELECT `+rateColumns+` FROM exchange_rates WHERE from_currency = ? AND to_currency = ?
+		ORDER BY created_at DESC, id DESC LIMIT 1`, from, to)
+}
+
+func (s *Store) ListExchangeRates(ctx context.Context, f store.ExchangeRateFilter, p store.Page) (*store.Result[store.ExchangeRate], error) {
+	var w where
+	if f.From != "" {
+		w.add("from_currency = ?", f.From)
+	}
+	if f.To != "" {
+		w.add("to_currency = ?", f.To)
+	}
+	return queryPage(ctx, s, store.ExchangeRateSorts, scanRate, `SELECT `+rateColumns+` FROM exchange_rates`, w, p, func(r store.ExchangeRate) primitive.ObjectID { return r.Id })
+}
@@ -0,0 +1,416 @@
+// Package sqlstore is the SQL backend of store.Store, SQLite and PostgreSQL are supported
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io/fs"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationSteps run in Go after the script of their version, in the same transaction, what SQL cannot do alone
+var migrationSteps = map[string]func(ctx context.Context, s *Store) error{
+	"0012_money": scaleMoney,
+}
+
+// Dialects, they are also the database/sql driver names
+const (
+	SQLite   = "sqlite"
+	Postgres = "pgx"
+)
+
+// maxTxAttempts bounds WithTx retries on deadlocks, serialization failures and busy databases
+const maxTxAttempts = 3
+
+var _ store.Store = (*Store)(nil)
+
+// querier is what *sql.DB and *sql.Tx have in common
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Store runs queries on db, or on the running transaction when created by WithTx
+type Store struct {
+	db      *sql.DB
+	q       querier
+	dialect string
+	inTx    bool
+}
+
+// Open connects to dsn with the given dialect and applies pending migrations.
+// SQLite gets immediate transactions, a busy timeout and foreign keys unless the DSN sets them
+func Open(dialect, dsn string) (*Store, error) {
+	switch dialect {
+	case SQLite:
+		dsn = withSQLiteDefaults(dsn)
+	case Postgres:
+	default:
+		return nil, fmt.Errorf("unknown SQL dialect %q", dialect)
+	}
+	db, err := sql.Open(dialect, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if dialect == SQLite {
+		// One writer at a time anyway, a single connection avoids SQLITE_BUSY between our own connections
+		db.SetMaxOpenConns(1)
+	}
+	if err := db.PingContext(context.Background()); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	s := &Store{db: db, q: db, dialect: dialect}
+	if err := s.migrate(context.Background()); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("migrating database: %w", err)
+	}
+	return s, nil
+}
+
+func withSQLiteDefaults(dsn string) string {
+	params := map[string]string{
+		"_txlock":      "_txlock=immediate",
+		"_time_format": "_time_format=sqlite",
+		"busy_timeout": "_pragma=busy_timeout(5000)",
+		"foreign_keys": "_pragma=foreign_keys(1)",
+	}
+	var missing []string
+	for key, param := range params {
+		if !strings.Contains(dsn, key) {
+			missing = append(missing, param)
+		}
+	}
+	if len(missing) == 0 {
+		return dsn
+	}
+	sort.Strings(missing)
+	if !strings.HasPrefix(dsn, "file:") {
+		dsn = "file:" + dsn
+	}
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + strings.Join(missing, "&")
+}
+
+// migrate applies every embedded migration not recorded in schema_migrations, each in its own transaction
+func (s *Store) migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version TEXT PRIMARY KEY)`); err != nil {
+		return err
+	}
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		version := strings.TrimSuffix(strings.TrimPrefix(file, "migrations/"), ".sql")
+		var applied int
+		if err := s.q.QueryRowContext(ctx, s.rebind(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`), version).Scan(&applied); err != nil {
+			return err
+		}
+		if applied > 0 {
+			continue
+		}
+		script, err := migrations.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		err = s.WithTx(ctx, func(ctx context.Context, ts store.Store) error {
+			tx := ts.(*Store)
+			// Scripts hold several statements, run them one by one as not every driver accepts a batch
+			for _, statement := range strings.Split(string(script), ";") {
+				if strings.TrimSpace(stripComments(statement)) == "" {
+					continue
+				}
+				if _, err := tx.exec(ctx, statement); err != nil {
+					return fmt.Errorf("%s: %w", version, err)
+				}
+			}
+			if step, ok := migrationSteps[version]; ok {
+				if err := step(ctx, tx); err != nil {
+					return fmt.Errorf("%s: %w", version, err)
+				}
+			}
+			_, err := tx.exec(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, version)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		log.Infof("Applied migration %s", version)
+	}
+	return nil
+}
+
+func stripComments(statement string) string {
+	var lines []string
+	for _, line := range strings.Split(statement, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (s *Store) WithTx(ctx context.Context, fn func(ctx context.Context, s store.Store) error) error {
+	if s.inTx {
+		return fn(ctx, s)
+	}
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = s.runTx(ctx, fn)
+		if err == nil || !retryable(err) {
+			return err
+		}
+		log.Warnf("Retrying transaction (attempt %d) after: %v", attempt, err)
+	}
+	return err
+}
+
+func (s *Store) runTx(ctx context.Context, fn func(ctx context.Context, s store.Store) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	txStore := &Store{db: s.db, q: tx, dialect: s.dialect, inTx: true}
+	if err := fn(ctx, txStore); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) Close(context.Context) error {
+	if s.inTx {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// rebind turns ? placeholders into $1, $2... for PostgreSQL
+func (s *Store) rebind(query string) string {
+	if s.dialect != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// forUpdate locks the selected rows until the transaction ends. SQLite locks the whole database on BEGIN IMMEDIATE instead
+func (s *Store) forUpdate() string {
+	if s.inTx && s.dialect == Postgres {
+		return " FOR UPDATE"
+	}
+	return ""
+}
+
+func (s *Store) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	res, err := s.q.ExecContext(ctx, s.rebind(query), args...)
+	return res, translate(err)
+}
+
+// execOne is exec for statements that must touch a row, store.ErrNotFound otherwise
+func (s *Store) execOne(ctx context.Context, query string, args ...any) error {
+	res, err := s.exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// scanner is what *sql.Row and *sql.Rows have in common
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func queryOne[T any](ctx context.Context, s *Store, scan func(scanner) (*T, error), query string, args ...any) (*T, error) {
+	result, err := scan(s.q.QueryRowContext(ctx, s.rebind(query), args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	return result, err
+}
+
+func queryAll[T any](ctx context.Context, s *Store, scan func(scanner) (*T, error), query string, args ...any) ([]T, error) {
+	rows, err := s.q.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Info("Error closing SQL rows, Error: " + err.Error())
+		}
+	}()
+	results := []T{}
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *item)
+	}
+	return results, rows.Err()
+}
+
+// where collects the conditions of a query and their arguments
+type where struct {
+	conds []string
+	args  []any
+}
+
+func (w *where) add(cond string, args ...any) {
+	w.conds = append(w.conds, cond)
+	w.args = append(w.args, args...)
+}
+
+func (w *where) String() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.conds, " AND ")
+}
+
+// queryPage fetches the page p of what query (a SELECT without WHERE) returns under w, keyset style: the cursor
+// turns into a condition on the sort column and id. Sort fields are known column names, never user input
+func queryPage[T any](ctx context.Context, s *Store, sorts store.Sorts[T], scan func(scanner) (*T, error), query string, w where, p store.Page, id func(T) primitive.ObjectID) (*store.Result[T], error) {
+	cursor, err := sorts.Check(&p)
+	if err != nil {
+		return nil, err
+	}
+	dir, op := "ASC", ">"
+	if p.Desc {
+		dir, op = "DESC", "<"
+	}
+	column := snakeCase(p.Sort)
+	order := "id " + dir
+	if p.Sort != "" {
+		order = column + " " + dir + ", id " + dir
+	}
+	if cursor != nil {
+		if p.Sort == "" {
+			w.add("id "+op+" ?", cursor.Id.Hex())
+		} else {
+			key := cursor.Key
+			if t, ok := key.(time.Time); ok {
+				key = utc(t)
+			}
+			w.add("("+column+" "+op+" ? OR ("+column+" = ? AND id "+op+" ?))", key, key, cursor.Id.Hex())
+		}
+	}
+	records, err := queryAll(ctx, s, scan, query+w.String()+" ORDER BY "+order+" LIMIT ?", append(w.args, p.Limit+1)...)
+	if err != nil {
+		return nil, err
+	}
+	return sorts.Page(p, records, id)
+}
+
+// snakeCase turns a bson field name into its column name, nextRun becomes next_run
+func snakeCase(field string) string {
+	var b strings.Builder
+	for _, r := range field {
+		if unicode.IsUpper(r) {
+			b.WriteByte('_')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// prefix adds a portable, case-sensitive "starts with" condition on column
+func (w *where) prefix(column, value string) {
+	w.add("substr("+column+", 1, ?) = ?", utf8.RuneCountInString(value), value)
+}
+
+// translate maps unique violations to store.ErrDuplicate
+func translate(err error) error {
+	if err == nil {
+		return nil
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return store.ErrDuplicate
+	}
+	var liteErr *sqlite.Error
+	if errors.As(err, &liteErr) && (liteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || liteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY) {
+		return store.ErrDuplicate
+	}
+	return err
+}
+
+// retryable reports deadlocks, serialization failures and busy SQLite databases
+func retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+	var liteErr *sqlite.Error
+	if errors.As(err, &liteErr) {
+		return liteErr.Code()&0xff == sqlite3.SQLITE_BUSY
+	}
+	return false
+}
+
+// hexID pairs a scanned hex string with the ObjectID it is parsed into
+type hexID struct {
+	hex string
+	id  *primitive.ObjectID
+}
+
+// parseIDs parses every scanned hex string, empty strings leave a zero ObjectID
+func parseIDs(ids ...hexID) error {
+	for _, h := range ids {
+		if h.hex == "" {
+			*h.id = primitive.NilObjectID
+			continue
+		}
+		var err error
+		if *h.id, err = primitive.ObjectIDFromHex(h.hex); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// utc keeps stored times comparable, SQLite compares them as text
+func utc(t time.Time) time.Time {
+	return t.UTC()
+}
@@ -0,0 +1,58 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"github.com/vovamod/BankAPI/store"
+	"time"
+)
+
+const refreshColumns = `hash, family, user_id, role, created_at, expires_at, used_at`
+
+func scanRefreshToken(row scanner) (*store.RefreshToken, error) {
+	var t store.RefreshToken
+	var usedAt sql.NullTime
+	if err := row.Scan(&t.Hash, &t.Family, &t.UserId, &t.Role, &t.CreatedAt, &t.ExpiresAt, &usedAt); err != nil {
+		return nil, err
+	}
+	t.UsedAt = usedAt.Time
+	return &t, nil
+}
+
+func (s *Store) CreateRefreshToken(ctx context.Context, t *store.RefreshToken) error {
+	_, err := s.exec(ctx, `INSERT INTO refresh_tokens (`+refreshColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		t.Hash, t.Family, t.UserId, t.Role, utc(t.CreatedAt), utc(t.ExpiresAt), nullTime(t.UsedAt))
+	return err
+}
+
+func (s *Store) GetRefreshToken(ctx context.Context, hash string) (*store.RefreshToken, error) {
+	return queryOne(ctx, s, scanRefreshToken, `SELECT `+refreshColumns+` FROM refresh_tokens WHERE hash = ?`, hash)
+}
+
+func (s *Store) UseRefreshToken(ctx context.Context, hash string, at time.Time) error {
+	return s.execOne(ctx, `UPDATE refresh_tokens SET used_at = ? WHERE hash = ? AND used_at IS NULL`, utc(at), hash)
+}
+
+func (s *Store) Revoke(ctx context.Context, r *store.Revocation) error {
+	_, err := s.exec(ctx, `INSERT INTO revocations (id, expires_at) VALUES (?, ?)
+		ON CONFLICT (id) DO UPDATE SET expires_at = CASE WHEN revocations.expires_at > excluded.expires_at
+		THEN revocations.expires_at ELSE excluded.expires_at END`, r.Id, utc(r.ExpiresAt))
+	return err
+}
+
+func (s *Store) Revoked(ctx context.Context, ids ...string) (bool, error) {
+	if len(ids) == 0 {
+		return false, nil
+	}
+	var n int
+	err := s.q.QueryRowContext(ctx, s.rebind(`SELECT COUNT(*) FROM revocations WHERE id IN (`+placeholders(len(ids))+`)`), anys(ids)...).Scan(&n)
+	return n > 0, err
+}
+
+func (s *Store) DeleteExpiredTokens(ctx context.Context, now time.Time) error {
+	if _, err := s.exec(ctx, `DELETE FROM refresh_tokens WHERE expires_at < ?`, utc(now)); err != nil {
+		return err
+	}
+	_, err := s.exec(ctx, `DELETE FROM revocations WHERE expires_at < ?`, utc(now))
+	return err
+}
@@ -0,0 +1,134 @@
+package sqlstore
+
+import (
+	"context"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+)
+
+const transactionColumns = `id, value, name_tz, date, status, by_who, to_who, type, original_id, refunded, currency, to_currency, to_value, rate, rate_id, flags`
+
+func scanTransaction(row scanner) (*store.Transaction, error) {
+	var t store.Transaction
+	var id, originalID, rateID, flags string
+	if err := row.Scan(&id, &t.Value, &t.NameTZ, &t.Date, &t.Status, &t.ByWho, &t.ToWho, &t.Type, &originalID, &t.Refunded,
+		&t.Currency, &t.ToCurrency, &t.ToValue, &t.Rate, &rateID, &flags); err != nil {
+		return nil, err
+	}
+	if flags != "" {
+		t.Flags = strings.Split(flags, ",")
+	}
+	return &t, parseIDs(hexID{id, &t.Id}, hexID{originalID, &t.OriginalId}, hexID{rateID, &t.RateId})
+}
+
+// optionalID stores a zero ObjectID as an empty string
+func optionalID(id primitive.ObjectID) string {
+	if id.IsZero() {
+		return ""
+	}
+	return id.Hex()
+}
+
+func (s *Store) CreateTransaction(ctx context.Context, t *store.Transaction) error {
+	return s.WithTx(ctx, func(ctx context.Context, ts store.Store) error {
+		tx := ts.(*Store)
+		if _, err := tx.exec(ctx, `INSERT INTO transactions (`+transactionColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			t.Id.Hex(), t.Value, t.NameTZ, utc(t.Date), t.Status, t.ByWho, t.ToWho, t.Type, optionalID(t.OriginalId), t.Refunded,
+			t.Currency, t.ToCurrency, t.ToValue, t.Rate, optionalID(t.RateId), strings.Join(t.Flags, ",")); err != nil {
+			return err
+		}
+		return tx.putFees(ctx, t)
+	})
+}
+
+func (s *Store) GetTransaction(ctx context.Context, id primitive.ObjectID) (*store.Transaction, error) {
+	t, err := queryOne(ctx, s, scanTransaction, `SELECT `+transactionColumns+` FROM transactions WHERE id = ?`+s.forUpdate(), id.Hex())
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := s.withFees(ctx, []store.Transaction{*t})
+	if err != nil {
+		return nil, err
+	}
+	return &transactions[0], nil
+}
+
+func (s *Store) ListTransactions(ctx context.Context, f store.TransactionFilter, p store.Page) (*store.Result[store.Transaction], error) {
+	result, err := queryPage(ctx, s, store.TransactionSorts, scanTransaction, `SELECT `+transactionColumns+` FROM transactions`, transactionWhere(f), p, func(t store.Transaction) primitive.ObjectID { return t.Id })
+	if err != nil {
+		return nil, err
+	}
+	result.Data, err = s.withFees(ctx, result.Data)
+	return result, err
+}
+
+func transactionWhere(f store.TransactionFilter) where {
+	var w where
+	if len(f.Ids) > 0 {
+		ids := make([]string, len(f.Ids))
+		for i, id := range f.Ids {
+			ids[i] = id.Hex()
+		}
+		w.add("id IN ("+placeholders(len(ids))+")", anys(ids)...)
+	}
+	if f.ByWho != "" {
+		w.add("by_who = ?", f.ByWho)
+	}
+	if f.ToWho != "" {
+		w.add("to_who = ?", f.ToWho)
+	}
+	if f.Account != "" {
+		w.add("(by_who = ? OR to_who = ?)", f.Account, f.Account)
+	}
+	if f.Status != "" {
+		w.add("status = ?", f.Status)
+	}
+	if len(f.StatusNot) > 0 {
+		w.add("status NOT IN ("+placeholders(len(f.StatusNot))+")", anys(f.StatusNot)...)
+	}
+	if !f.From.IsZero() {
+		w.add("date >= ?", utc(f.From))
+	}
+	if !f.To.IsZero() {
+		w.add("date < ?", utc(f.To))
+	}
+	return w
+}
+
+func (s *Store) Spent(ctx context.Context, f store.SpendingFilter) (int, int, error) {
+	if len(f.Payers) == 0 || len(f.Types) == 0 {
+		return 0, 0, nil
+	}
+	var w where
+	payers := placeholders(len(f.Payers))
+	w.add("((value >= 0 AND by_who IN ("+payers+")) OR (value < 0 AND to_who IN ("+payers+")))", append(anys(f.Payers), anys(f.Payers)...)...)
+	w.add("type IN ("+placeholders(len(f.Types))+")", anys(f.Types)...)
+	if len(f.StatusNot) > 0 {
+		w.add("status NOT IN ("+placeholders(len(f.StatusNot))+")", anys(f.StatusNot)...)
+	}
+	w.add("date >= ?", utc(f.Since))
+	var value, count int
+	err := s.q.QueryRowContext(ctx, s.rebind(`SELECT COALESCE(SUM(ABS(value)), 0), COUNT(*) FROM transactions`+w.String()), w.args...).Scan(&value, &count)
+	return value, count, err
+}
+
+// placeholders is n comma separated ?
+func placeholders(n int) string {
+	return "?" + strings.Repeat(", ?", n-1)
+}
+
+func anys(values []string) []any {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+func (s *Store) UpdateTransaction(ctx context.Context, t *store.Transaction) error {
+	return s.execOne(ctx, `UPDATE transactions SET value = ?, name_tz = ?, date = ?, status = ?, by_who = ?, to_who = ?, type = ?, original_id = ?, refunded = ?,
+		currency = ?, to_currency = ?, to_value = ?, rate = ?, rate_id = ?, flags = ? WHERE id = ?`,
+		t.Value, t.NameTZ, utc(t.Date), t.Status, t.ByWho, t.ToWho, t.Type, optionalID(t.OriginalId), t.Refunded,
+		t.Currency, t.ToCurrency, t.ToValue, t.Rate, optionalID(t.RateId), strings.Join(t.Flags, ","), t.Id.Hex())
+}
@@ -0,0 +1,118 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"github.com/vovamod/BankAPI/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const userColumns = `id, name, object_id, deleted_at, deleted_by`
+
+func scanUser(row scanner) (*store.User, error) {
+	var u store.User
+	var id string
+	var deletedAt sql.NullTime
+	if err := row.Scan(&id, &u.Name, &u.ObjectId, &deletedAt, &u.DeletedBy); err != nil {
+		return nil, err
+	}
+	u.DeletedAt = deletedAt.Time
+	var err error
+	u.Id, err = primitive.ObjectIDFromHex(id)
+	return &u, err
+}
+
+// withAccounts loads the linked accounts of every user
+func (s *Store) withAccounts(ctx context.Context, users []store.User) ([]store.User, error) {
+	for i := range users {
+		accounts, err := queryAll(ctx, s, func(row scanner) (*string, error) {
+			var account string
+			return &account, row.Scan(&account)
+		}, `SELECT account_id FROM user_accounts WHERE user_id = ? ORDER BY position`, users[i].Id.Hex())
+		if err != nil {
+			return nil, err
+		}
+		users[i].Account = accounts
+	}
+	return users, nil
+}
+
+func (s *Store) getUser(ctx context.Context, query string, args ...any) (*store.User, error) {
+	u, err := queryOne(ctx, s, scanUser, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.withAccounts(ctx, []store.User{*u})
+	if err != nil {
+		return nil, err
+	}
+	return &users[0], nil
+}
+
+// putAccounts replaces the linked accounts of u
+func (s *Store) putAccounts(ctx context.Context, u *store.User) error {
+	if _, err := s.exec(ctx, `DELETE FROM user_accounts WHERE user_id = ?`, u.Id.Hex()); err != nil {
+		return err
+	}
+	for i, account := range u.Account {
+		if _, err := s.exec(ctx, `INSERT INTO user_accounts (user_id, position, account_id) VALUES (?, ?, ?)`, u.Id.Hex(), i, account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) CreateUser(ctx context.Context, u *store.User) error {
+	return s.WithTx(ctx, func(ctx context.Context, ts store.Store) error {
+		tx := ts.(*Store)
+		if _, err := tx.exec(ctx, `INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?)`, u.Id.Hex(), u.Name, u.ObjectId, nullTime(u.DeletedAt), u.DeletedBy); err != nil {
+			return err
+		}
+		return tx.putAccounts(ctx, u)
+	})
+}
+
+func (s *Store) GetUser(ctx context.Context, id primitive.ObjectID) (*store.User, error) {
+	return s.getUser(ctx, `SELECT `+userColumns+` FROM users WHERE id = ?`, id.Hex())
+}
+
+func (s *Store) GetUserByName(ctx context.Context, name string) (*store.User, error) {
+	return s.getUser(ctx, `SELECT `+userColumns+` FROM users WHERE name = ?`, name)
+}
+
+func (s *Store) GetUserByAccount(ctx context.Context, accountID string) (*store.User, error) {
+	return s.getUser(ctx, `SELECT `+userColumns+` FROM users WHERE id IN (SELECT user_id FROM user_accounts WHERE account_id = ?) ORDER BY id LIMIT 1`, accountID)
+}
+
+func (s *Store) ListUsers(ctx context.Context, f store.UserFilter, p store.Page) (*store.Result[store.User], error) {
+	var w where
+	if f.NamePrefix != "" {
+		w.prefix("name", f.NamePrefix)
+	}
+	if f.Deleted {
+		w.add("deleted_at IS NOT NULL")
+	} else {
+		w.add("deleted_at IS NULL")
+	}
+	result, err := queryPage(ctx, s, store.UserSorts, scanUser, `SELECT `+userColumns+` FROM users`, w, p, func(u store.User) primitive.ObjectID { return u.Id })
+	if err != nil {
+		return nil, err
+	}
+	result.Data, err = s.withAccounts(ctx, result.Data)
+	return result, err
+}
+
+func (s *Store) UpdateUser(ctx context.Context, u *store.User) error {
+	return s.WithTx(ctx, func(ctx context.Context, ts store.Store) error {
+		tx := ts.(*Store)
+		if err := tx.execOne(ctx, `UPDATE users SET name = ?, object_id = ?, deleted_at = ?, deleted_by = ? WHERE id = ?`,
+			u.Name, u.ObjectId, nullTime(u.DeletedAt), u.DeletedBy, u.Id.Hex()); err != nil {
+			return err
+		}
+		return tx.putAccounts(ctx, u)
+	})
+}
+
+func (s *Store) DeleteUser(ctx context.Context, id primitive.ObjectID) error {
+	return s.execOne(ctx, `DELETE FROM users WHERE id = ?`, id.Hex())
+}
@@ -0,0 +1,462 @@
+// Package store holds the entities persisted by BankAPI and the Store interface every backend implements.
+// Values are whole numbers of minor units of their currency (see package money), an empty currency is the default one.
+// They stay plain ints rather than money.Money on purpose: every record already names its currency, and a scale stored
+// next to each amount could only drift from the one configured for it. Bodies and answers use money.Money (see json.go)
+package store
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Errors returned by every backend, handlers match them with errors.Is
+var (
+	ErrNotFound  = errors.New("store: record not found")
+	ErrDuplicate = errors.New("store: record already exists")
+)
+
+// Transaction is a single movement of value between two accounts (by their names).
+// Reversals and refunds point to the transaction they compensate with OriginalId,
+// which keeps in Refunded how much of its value has been given back so far.
+// Value is in Currency. A conversion credits ToValue in ToCurrency instead, at Rate of the exchange rate RateId.
+// Fees were paid on top of Value by the paying account. Flags are the fraud rules that sent it to review
+type Transaction struct {
+	Id         primitive.ObjectID `bson:"_id"`
+	Value      int                `bson:"value"`
+	NameTZ     string             `bson:"nameTZ"`
+	Date       time.Time          `bson:"date"`
+	Status     string             `bson:"status"`
+	ByWho      string             `bson:"byWho"`
+	ToWho      string             `bson:"toWho"`
+	Type       string             `bson:"type,omitempty"`
+	OriginalId primitive.ObjectID `bson:"originalId,omitempty"`
+	Refunded   int                `bson:"refunded,omitempty"`
+	Currency   string             `bson:"currency,omitempty"`
+	ToCurrency string             `bson:"toCurrency,omitempty"`
+	ToValue    int                `bson:"toValue,omitempty"`
+	Rate       string             `bson:"rate,omitempty"`
+	RateId     primitive.ObjectID `bson:"rateId,omitempty"`
+	Fees       []Fee              `bson:"fees,omitempty"`
+	Flags      []string           `bson:"flags,omitempty"`
+}
+
+// Fee is a fee or a tax (Kind) paid with a transaction to the system account Account, by the fee rule named Rule
+type Fee struct {
+	Rule    string `bson:"rule"`
+	Kind    string `bson:"kind"`
+	Value   int    `bson:"value"`
+	Account string `bson:"account"`
+}
+
+// Account is identified by its unique Name. Value caches the balance, which is the sum of the account postings.
+// Held caches the value of its active holds, what is available is Value - Held. Currency is empty for the default one.
+// Type is the name of its AccountType, empty for accounts earning no interest. Overdraft is the policy deciding
+// whether Value may go down to -OverdraftLimit, OverdraftRate the yearly interest in basis points charged when it does.
+// Limits set here replace those of its type one by one. Status is its lifecycle state, empty for accounts made
+// before states existed which are active. DeletedAt is set when it is deleted, DeletedBy being who did it
+type Account struct {
+	Id             primitive.ObjectID `bson:"_id"`
+	Name           string             `bson:"name"`
+	Value          int                `bson:"value"`
+	Held           int                `bson:"held,omitempty"`
+	Currency       string             `bson:"currency,omitempty"`
+	AccountId      string             `bson:"accountId"`
+	Type           string             `bson:"type,omitempty"`
+	Overdraft      string             `bson:"overdraft,omitempty"`
+	OverdraftLimit int                `bson:"overdraftLimit,omitempty"`
+	OverdraftRate  int                `bson:"overdraftRate,omitempty"`
+	Limits         Limits             `bson:"limits,omitempty"`
+	Status         string             `bson:"status,omitempty"`
+	DeletedAt      time.Time          `bson:"deletedAt,omitempty"`
+	DeletedBy      string             `bson:"deletedBy,omitempty"`
+}
+
+// Limits caps what an account pays: PerTransaction a single payment, Daily and Weekly the payments of the last
+// 24 hours and 7 days, Hourly the number of payments of the last hour. Zero is no limit
+type Limits struct {
+	PerTransaction int `bson:"perTransaction,omitempty"`
+	Daily          int `bson:"daily,omitempty"`
+	Weekly         int `bson:"weekly,omitempty"`
+	Hourly         int `bson:"hourly,omitempty"`
+}
+
+// AccountType sets the interest paid to the accounts of that type. Rate is yearly in basis points (1/100 of a percent),
+// Compound pays interest on the interest already paid too and Posting is "daily" or "monthly".
+// The amounts of Limits are at money.DefaultScale and apply in the currency of each account
+type AccountType struct {
+	Id       primitive.ObjectID `bson:"_id"`
+	Name     string             `bson:"name"`
+	Rate     int                `bson:"rate"`
+	Compound bool               `bson:"compound"`
+	Posting  string             `bson:"posting"`
+	Limits   Limits             `bson:"limits,omitempty"`
+}
+
+// Accrual is the interest bookkeeping of one account: every day before Through has been settled, Paid in total
+// to the account and Charged in total for its overdraft. Due is when the next period ends
+type Accrual struct {
+	Account primitive.ObjectID `bson:"_id"`
+	Through time.Time          `bson:"through"`
+	Due     time.Time          `bson:"due"`
+	Paid    int                `bson:"paid"`
+	Charged int                `bson:"charged"`
+}
+
+// User is linked to one or more accounts by their hex ObjectID, Name is unique. DeletedAt is set when it is deleted,
+// DeletedBy being who did it
+type User struct {
+	Id        primitive.ObjectID `bson:"_id"`
+	Name      string             `bson:"name"`
+	Account   []string           `bson:"account"`
+	ObjectId  string             `bson:"objectId"`
+	DeletedAt time.Time          `bson:"deletedAt,omitempty"`
+	DeletedBy string             `bson:"deletedBy,omitempty"`
+}
+
+// Posting is one side of a transaction in the double-entry ledger. Amount is positive for a credit and
+// negative for a debit, the postings of a single transaction always sum to zero
+type Posting struct {
+	Id            primitive.ObjectID `bson:"_id"`
+	TransactionId primitive.ObjectID `bson:"transactionId"`
+	Account       primitive.ObjectID `bson:"account"`
+	Amount        int                `bson:"amount"`
+	Date          time.Time          `bson:"date"`
+}
+
+// LedgerEntry is what one transaction did to an account, the sum of its postings there
+type LedgerEntry struct {
+	TransactionId primitive.ObjectID `bson:"_id"`
+	Date          time.Time          `bson:"date"`
+	Amount        int                `bson:"amount"`
+}
+
+// IdempotencyRecord keeps the response given to a request sent with an Idempotency-Key header.
+// Status stays 0 while the first request is still being handled
+type IdempotencyRecord struct {
+	Key         string    `bson:"_id"`
+	RequestHash string    `bson:"requestHash"`
+	Status      int       `bson:"status"`
+	Body        []byte    `bson:"body"`
+	CreatedAt   time.Time `bson:"createdAt"`
+}
+
+// RefreshToken is kept by the hash of its value, never the value itself. Family groups the tokens rotated from
+// one login, UsedAt is set once it has been traded for new tokens
+type RefreshToken struct {
+	Hash      string    `bson:"_id"`
+	Family    string    `bson:"family"`
+	UserId    string    `bson:"userId"`
+	Role      string    `bson:"role"`
+	CreatedAt time.Time `bson:"createdAt"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+	UsedAt    time.Time `bson:"usedAt,omitempty"`
+}
+
+// Revocation kills the access tokens whose jti or family is Id until ExpiresAt, when they have all expired anyway
+type Revocation struct {
+	Id        string    `bson:"_id"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// APIKey lets an integration call the API without logging in, as what its Scopes allow. It is kept by the Hash
+// of its value which never leaves the server. AllowedIPs are the addresses or CIDR ranges it may be used from,
+// any when empty. A zero ExpiresAt never expires
+type APIKey struct {
+	Id         primitive.ObjectID `bson:"_id"`
+	Name       string             `bson:"name"`
+	Hash       string             `bson:"hash" json:"-"`
+	Scopes     []string           `bson:"scopes"`
+	AllowedIPs []string           `bson:"allowedIps,omitempty"`
+	CreatedAt  time.Time          `bson:"createdAt"`
+	CreatedBy  string             `bson:"createdBy"`
+	ExpiresAt  time.Time          `bson:"expiresAt,omitempty"`
+}
+
+// StandingOrder moves Value from ByWho to ToWho on a schedule, either every Interval (a Go duration) or by Cron.
+// Retries counts failed attempts for the current run, Missed the runs given up on because they kept failing
+type StandingOrder struct {
+	Id        primitive.ObjectID `bson:"_id"`
+	Name      string             `bson:"name"`
+	ByWho     string             `bson:"byWho"`
+	ToWho     string             `bson:"toWho"`
+	Value     int                `bson:"value"`
+	Currency  string             `bson:"currency,omitempty"`
+	Interval  string             `bson:"interval,omitempty"`
+	Cron      string             `bson:"cron,omitempty"`
+	StartAt   time.Time          `bson:"startAt"`
+	EndAt     time.Time          `bson:"endAt,omitempty"`
+	MaxRuns   int                `bson:"maxRuns,omitempty"`
+	Runs      int                `bson:"runs"`
+	Retries   int                `bson:"retries"`
+	Missed    int                `bson:"missed"`
+	NextRun   time.Time          `bson:"nextRun"`
+	Status    string             `bson:"status"`
+	LastError string             `bson:"lastError,omitempty"`
+}
+
+// StandingOrderRun records one execution of a standing order, TransactionId is the transaction it created
+type StandingOrderRun struct {
+	Id            primitive.ObjectID `bson:"_id"`
+	OrderId       primitive.ObjectID `bson:"orderId"`
+	TransactionId primitive.ObjectID `bson:"transactionId"`
+	Date          time.Time          `bson:"date"`
+	Status        string             `bson:"status"`
+	Error         string             `bson:"error,omitempty"`
+}
+
+// Hold reserves Value of Account (by name) until ExpiresAt without moving it. Capturing it moves Captured
+// to ToWho with the transaction TransactionId and gives the rest back
+type Hold struct {
+	Id            primitive.ObjectID `bson:"_id"`
+	Name          string             `bson:"name"`
+	Account       string             `bson:"account"`
+	Value         int                `bson:"value"`
+	Currency      string             `bson:"currency,omitempty"`
+	Captured      int                `bson:"captured,omitempty"`
+	ToWho         string             `bson:"toWho,omitempty"`
+	TransactionId primitive.ObjectID `bson:"transactionId,omitempty"`
+	CreatedAt     time.Time          `bson:"createdAt"`
+	ExpiresAt     time.Time          `bson:"expiresAt"`
+	Status        string             `bson:"status"`
+}
+
+// Escrow keeps Value of a trade in the escrow account, paid in by Buyer with the transaction TransactionId.
+// Once closed ToSeller went to Seller and ToBuyer back to Buyer
+type Escrow struct {
+	Id            primitive.ObjectID `bson:"_id"`
+	Name          string             `bson:"name"`
+	Buyer         string             `bson:"buyer"`
+	Seller        string             `bson:"seller"`
+	Value         int                `bson:"value"`
+	Currency      string             `bson:"currency,omitempty"`
+	ToSeller      int                `bson:"toSeller"`
+	ToBuyer       int                `bson:"toBuyer"`
+	TransactionId primitive.ObjectID `bson:"transactionId"`
+	Status        string             `bson:"status"`
+	CreatedAt     time.Time          `bson:"createdAt"`
+	ClosedAt      time.Time          `bson:"closedAt,omitempty"`
+}
+
+// ExchangeRate converts From into To: one unit of From is Rate (a decimal) units of To.
+// Rates are never changed, a new one replaces the latest
+type ExchangeRate struct {
+	Id        primitive.ObjectID `bson:"_id"`
+	From      string             `bson:"from"`
+	To        string             `bson:"to"`
+	Rate      string             `bson:"rate"`
+	CreatedAt time.Time          `bson:"createdAt"`
+}
+
+// FeeRule charges the payer of transactions of Type (every type when empty) in Currency a fee or a tax (Kind)
+// on top of the value: Percent in basis points of the value plus Flat, kept between Min and Max (no maximum when 0).
+// The last of the Tiers a value reaches replaces Percent and Flat
+type FeeRule struct {
+	Id       primitive.ObjectID `bson:"_id"`
+	Name     string             `bson:"name"`
+	Kind     string             `bson:"kind"`
+	Type     string             `bson:"type"`
+	Currency string             `bson:"currency"`
+	Percent  int                `bson:"percent"`
+	Flat     int                `bson:"flat"`
+	Min      int                `bson:"min"`
+	Max      int                `bson:"max"`
+	Tiers    []FeeTier          `bson:"tiers,omitempty"`
+}
+
+// FraudRule flags transfers paid in Currency that its Check, one of the checks of the entities package, matches
+// with Value, Count and Window (a Go duration) as parameters. Action is "block" to refuse them
+// or "review" to keep them until an admin approves or rejects them
+type FraudRule struct {
+	Id       primitive.ObjectID `bson:"_id"`
+	Name     string             `bson:"name"`
+	Check    string             `bson:"check"`
+	Action   string             `bson:"action"`
+	Currency string             `bson:"currency"`
+	Value    int                `bson:"value"`
+	Count    int                `bson:"count"`
+	Window   string             `bson:"window"`
+}
+
+// FeeTier applies Percent and Flat to values from From on
+type FeeTier struct {
+	From    int `bson:"from"`
+	Percent int `bson:"percent"`
+	Flat    int `bson:"flat"`
+}
+
+type AccountStore interface {
+	CreateAccount(ctx context.Context, a *Account) error
+	GetAccount(ctx context.Context, id primitive.ObjectID) (*Account, error)
+	GetAccountByName(ctx context.Context, name string) (*Account, error)
+	ListAccounts(ctx context.Context, f AccountFilter, p Page) (*Result[Account], error)
+	// UpdateAccount saves everything but Value and Held, which only the ledger and holds change
+	UpdateAccount(ctx context.Context, a *Account) error
+	// IncAccountValue adds delta (may be negative) to the cached balance, only the ledger should call it
+	IncAccountValue(ctx context.Context, id primitive.ObjectID, delta int) error
+	// IncAccountHeld adds delta (may be negative) to the value held, only holds should call it
+	IncAccountHeld(ctx context.Context, id primitive.ObjectID, delta int) error
+	// DeleteAccount removes the account for good, handlers only delete softly with DeletedAt
+	DeleteAccount(ctx context.Context, id primitive.ObjectID) error
+}
+
+type UserStore interface {
+	CreateUser(ctx context.Context, u *User) error
+	GetUser(ctx context.Context, id primitive.ObjectID) (*User, error)
+	GetUserByName(ctx context.Context, name string) (*User, error)
+	// GetUserByAccount returns the user the account (hex ObjectID) is linked to
+	GetUserByAccount(ctx context.Context, accountID string) (*User, error)
+	ListUsers(ctx context.Context, f UserFilter, p Page) (*Result[User], error)
+	UpdateUser(ctx context.Context, u *User) error
+	// DeleteUser removes the user for good, handlers only delete softly with DeletedAt
+	DeleteUser(ctx context.Context, id primitive.ObjectID) error
+}
+
+type TransactionStore interface {
+	CreateTransaction(ctx context.Context, t *Transaction) error
+	GetTransaction(ctx context.Context, id primitive.ObjectID) (*Transaction, error)
+	ListTransactions(ctx context.Context, f TransactionFilter, p Page) (*Result[Transaction], error)
+	// Spent returns the value paid by the accounts of f and the number of payments they made
+	Spent(ctx context.Context, f SpendingFilter) (value int, count int, err error)
+	UpdateTransaction(ctx context.Context, t *Transaction) error
+}
+
+type LedgerStore interface {
+	CreatePostings(ctx context.Context, postings []Posting) error
+	// SumPostings returns the sum and the number of postings of the account
+	SumPostings(ctx context.Context, account primitive.ObjectID) (sum int, count int, err error)
+	// ListPostings returns the postings of the account in the order they were made
+	ListPostings(ctx context.Context, account primitive.ObjectID) ([]Posting, error)
+	// ListLedger returns up to limit entries of the account coming after the entry after, by date then transaction.
+	// The zero entry starts from the first one
+	ListLedger(ctx context.Context, account primitive.ObjectID, after LedgerEntry, limit int) ([]LedgerEntry, error)
+}
+
+type IdempotencyStore interface {
+	// CreateIdempotencyRecord fails with ErrDuplicate when the key is taken, which is how concurrent retries are told apart
+	CreateIdempotencyRecord(ctx context.Context, r *IdempotencyRecord) error
+	GetIdempotencyRecord(ctx context.Context, key string) (*IdempotencyRecord, error)
+	// CompleteIdempotencyRecord stores the response of the request holding the key
+	CompleteIdempotencyRecord(ctx context.Context, key string, status int, body []byte) error
+	DeleteIdempotencyRecord(ctx context.Context, key string) error
+	// DeleteIdempotencyRecordsBefore forgets the records created before before
+	DeleteIdempotencyRecordsBefore(ctx context.Context, before time.Time) error
+}
+
+type TokenStore interface {
+	CreateRefreshToken(ctx context.Context, t *RefreshToken) error
+	GetRefreshToken(ctx context.Context, hash string) (*RefreshToken, error)
+	// UseRefreshToken marks the token used at at, ErrNotFound when it is missing or used already so it rotates once
+	UseRefreshToken(ctx context.Context, hash string, at time.Time) error
+	// Revoke adds r to the revocation list, keeping the latest ExpiresAt when its Id is there already
+	Revoke(ctx context.Context, r *Revocation) error
+	// Revoked tells whether any of ids is on the revocation list
+	Revoked(ctx context.Context, ids ...string) (bool, error)
+	// DeleteExpiredTokens forgets the refresh tokens and revocations expired before now
+	DeleteExpiredTokens(ctx context.Context, now time.Time) error
+}
+
+type APIKeyStore interface {
+	// CreateAPIKey fails with ErrDuplicate when the name is taken
+	CreateAPIKey(ctx context.Context, k *APIKey) error
+	GetAPIKey(ctx context.Context, id primitive.ObjectID) (*APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (*APIKey, error)
+	ListAPIKeys(ctx context.Context, p Page) (*Result[APIKey], error)
+	DeleteAPIKey(ctx context.Context, id primitive.ObjectID) error
+}
+
+type StandingOrderStore interface {
+	CreateStandingOrder(ctx context.Context, o *StandingOrder) error
+	GetStandingOrder(ctx context.Context, id primitive.ObjectID) (*StandingOrder, error)
+	ListStandingOrders(ctx context.Context, p Page) (*Result[StandingOrder], error)
+	// DueStandingOrders returns at most limit orders with the given status and NextRun not after now, the most late first
+	DueStandingOrders(ctx context.Context, status string, now time.Time, limit int) ([]StandingOrder, error)
+	UpdateStandingOrder(ctx context.Context, o *StandingOrder) error
+	CreateStandingOrderRun(ctx context.Context, r *StandingOrderRun) error
+	ListStandingOrderRuns(ctx context.Context, order primitive.ObjectID, p Page) (*Result[StandingOrderRun], error)
+}
+
+type InterestStore interface {
+	CreateAccountType(ctx context.Context, t *AccountType) error
+	GetAccountType(ctx context.Context, id primitive.ObjectID) (*AccountType, error)
+	GetAccountTypeByName(ctx context.Context, name string) (*AccountType, error)
+	ListAccountTypes(ctx context.Context, p Page) (*Result[AccountType], error)
+	UpdateAccountType(ctx context.Context, t *AccountType) error
+	// CreateAccrual fails with ErrDuplicate when the account already has one
+	CreateAccrual(ctx context.Context, a *Accrual) error
+	GetAccrual(ctx context.Context, account primitive.ObjectID) (*Accrual, error)
+	// DueAccruals returns at most limit accruals with Due not after now, the most late first
+	DueAccruals(ctx context.Context, now time.Time, limit int) ([]Accrual, error)
+	UpdateAccrual(ctx context.Context, a *Accrual) error
+	DeleteAccrual(ctx context.Context, account primitive.ObjectID) error
+}
+
+type HoldStore interface {
+	CreateHold(ctx context.Context, h *Hold) error
+	GetHold(ctx context.Context, id primitive.ObjectID) (*Hold, error)
+	ListHolds(ctx context.Context, f HoldFilter, p Page) (*Result[Hold], error)
+	// ExpiredHolds returns at most limit holds with the given status and ExpiresAt not after now, the oldest first
+	ExpiredHolds(ctx context.Context, status string, now time.Time, limit int) ([]Hold, error)
+	UpdateHold(ctx context.Context, h *Hold) error
+}
+
+type EscrowStore interface {
+	CreateEscrow(ctx context.Context, e *Escrow) error
+	GetEscrow(ctx context.Context, id primitive.ObjectID) (*Escrow, error)
+	ListEscrows(ctx context.Context, f EscrowFilter, p Page) (*Result[Escrow], error)
+	UpdateEscrow(ctx context.Context, e *Escrow) error
+}
+
+type ExchangeRateStore interface {
+	CreateExchangeRate(ctx context.Context, r *ExchangeRate) error
+	GetExchangeRate(ctx context.Context, id primitive.ObjectID) (*ExchangeRate, error)
+	// LatestExchangeRate returns the most recent rate from one currency to the other
+	LatestExchangeRate(ctx context.Context, from, to string) (*ExchangeRate, error)
+	ListExchangeRates(ctx context.Context, f ExchangeRateFilter, p Page) (*Result[ExchangeRate], error)
+}
+
+type FeeStore interface {
+	CreateFeeRule(ctx context.Context, r *FeeRule) error
+	GetFeeRule(ctx context.Context, id primitive.ObjectID) (*FeeRule, error)
+	ListFeeRules(ctx context.Context, p Page) (*Result[FeeRule], error)
+	// FeeRulesFor returns by name the rules charged on transactions of type t in currency, with those of every type
+	FeeRulesFor(ctx context.Context, t, currency string) ([]FeeRule, error)
+	UpdateFeeRule(ctx context.Context, r *FeeRule) error
+	DeleteFeeRule(ctx context.Context, id primitive.ObjectID) error
+}
+
+type FraudStore interface {
+	CreateFraudRule(ctx context.Context, r *FraudRule) error
+	GetFraudRule(ctx context.Context, id primitive.ObjectID) (*FraudRule, error)
+	ListFraudRules(ctx context.Context, p Page) (*Result[FraudRule], error)
+	// FraudRulesFor returns by name the rules of transfers paid in currency
+	FraudRulesFor(ctx context.Context, currency string) ([]FraudRule, error)
+	UpdateFraudRule(ctx context.Context, r *FraudRule) error
+	DeleteFraudRule(ctx context.Context, id primitive.ObjectID) error
+}
+
+// Store is what the entities handlers depend on. Backends live in sub packages (mongostore, memstore)
+type Store interface {
+	AccountStore
+	UserStore
+	TransactionStore
+	LedgerStore
+	IdempotencyStore
+	StandingOrderStore
+	InterestStore
+	HoldStore
+	EscrowStore
+	ExchangeRateStore
+	FeeStore
+	FraudStore
+	TokenStore
+	APIKeyStore
+
+	// WithTx runs fn atomically. Everything done through the Store passed to fn (using the ctx passed to fn)
+	// commits when fn returns nil and rolls back otherwise. fn may be run more than once on transient errors.
+	// Calling WithTx on the Store passed to fn simply joins the running transaction.
+	WithTx(ctx context.Context, fn func(ctx context.Context, s Store) error) error
+	// Close releases the underlying connections
+	Close(ctx context.Context) error
+}
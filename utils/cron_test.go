@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr  string
+		valid bool
+	}{
+		{"* * * * *", true},
+		{"*/15 0-6,22-23 1-31/2 1,6,12 1-5", true},
+		{"5/15 * * * 7", true},
+		{"* * * *", false},
+		{"* * * * * *", false},
+		{"60 * * * *", false},
+		{"* 24 * * *", false},
+		{"* * 0 * *", false},
+		{"* * * 13 *", false},
+		{"* * * * 8", false},
+		{"*/0 * * * *", false},
+		{"5-1 * * * *", false},
+		{"a * * * *", false},
+		{"1-b * * * *", false},
+	}
+	for _, tt := range tests {
+		_, err := ParseCron(tt.expr)
+		if (err == nil) != tt.valid {
+			t.Errorf("ParseCron(%q) error = %v, want valid %v", tt.expr, err, tt.valid)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse(time.DateTime, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		expr, from, want string
+	}{
+		{"*/15 * * * *", "2024-03-01 10:07:00", "2024-03-01 10:15:00"},
+		// Seconds are dropped and the result is strictly after
+		{"*/15 * * * *", "2024-03-01 10:14:59", "2024-03-01 10:15:00"},
+		{"30 2 * * *", "2024-03-01 02:30:00", "2024-03-02 02:30:00"},
+		// 1 March 2024 is a Friday
+		{"0 9 * * 1-5", "2024-03-01 10:00:00", "2024-03-04 09:00:00"},
+		{"0 12 * * 7", "2024-03-01 00:00:00", "2024-03-03 12:00:00"},
+		// Both day fields restricted: either may match
+		{"0 0 15 * 0", "2024-03-01 00:00:00", "2024-03-03 00:00:00"},
+		{"0 0 31 * *", "2024-04-01 00:00:00", "2024-05-31 00:00:00"},
+		{"0 0 1 */6 *", "2024-02-10 00:00:00", "2024-07-01 00:00:00"},
+		{"59 23 31 12 *", "2024-12-31 23:59:00", "2025-12-31 23:59:00"},
+		{"0 0 29 2 *", "2024-03-01 00:00:00", "2028-02-29 00:00:00"},
+		// Impossible dates never come
+		{"0 0 31 2 *", "2024-01-01 00:00:00", ""},
+		{"0 0 30 2 *", "2024-01-01 00:00:00", ""},
+		{"0 0 31 4,6,9,11 *", "2024-01-01 00:00:00", ""},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+		}
+		got := c.Next(at(tt.from))
+		if tt.want == "" {
+			if !got.IsZero() {
+				t.Errorf("%q after %s = %s, want none", tt.expr, tt.from, got)
+			}
+			continue
+		}
+		if !got.Equal(at(tt.want)) {
+			t.Errorf("%q after %s = %s, want %s", tt.expr, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestCronNextUTC(t *testing.T) {
+	c, err := ParseCron("0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 08:30 in UTC+2 is 06:30 UTC, next is 09:00 UTC the same day
+	from := time.Date(2024, 3, 1, 8, 30, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+	if got, want := c.Next(from), time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next(%s) = %s, want %s", from, got, want)
+	}
+}
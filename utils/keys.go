@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// signingKey signs tokens from from on, until the next key takes over. It still checks tokens for keyOverlap
+// after that so the ones it signed last do not die on rotation
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+	from    time.Time
+}
+
+// Keys of JWT_KEYS ordered by when they start signing, none means tokens are signed with JWT_SECRET
+var (
+	keys       []signingKey
+	keyOverlap time.Duration
+)
+
+// LoadKeys reads the keys tokens are signed with. JWT_KEYS lists them as kid=file.pem, a key only signing from
+// some time on as kid=file.pem@2026-11-01T00:00:00Z. Files hold a PKCS#1 or PKCS#8 RSA key (RS256) or a PKCS#8
+// Ed25519 one (EdDSA). Without JWT_KEYS tokens are signed with JWT_SECRET as before
+func LoadKeys() error {
+	jwtSecret = []byte(os.Getenv("JWT_SECRET"))
+	var err error
+	if keyOverlap, err = time.ParseDuration(GetEnv("JWT_KEY_OVERLAP", "24h")); err != nil || keyOverlap < 0 {
+		return errors.New("JWT_KEY_OVERLAP must be a duration like 24h")
+	}
+	keys = nil
+	for _, entry := range strings.Split(os.Getenv("JWT_KEYS"), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		kid, file, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" || file == "" {
+			return fmt.Errorf("invalid key %q, expected kid=file.pem", entry)
+		}
+		k := signingKey{kid: kid}
+		file, from, timed := strings.Cut(file, "@")
+		if timed {
+			if k.from, err = time.Parse(time.RFC3339, from); err != nil {
+				return fmt.Errorf("invalid start of key %s, expected RFC 3339", kid)
+			}
+		}
+		if err := k.load(file); err != nil {
+			return fmt.Errorf("key %s: %w", kid, err)
+		}
+		if slices.ContainsFunc(keys, func(other signingKey) bool { return other.kid == kid }) {
+			return fmt.Errorf("key %s is listed twice", kid)
+		}
+		keys = append(keys, k)
+	}
+	slices.SortStableFunc(keys, func(a, b signingKey) int { return a.from.Compare(b.from) })
+	if len(keys) == 0 && len(jwtSecret) == 0 {
+		return errors.New("set JWT_KEYS or JWT_SECRET to sign tokens")
+	}
+	return nil
+}
+
+// load reads the private key of k from the PEM file
+func (k *signingKey) load(file string) error {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return errors.New("no PEM block found")
+	}
+	var key any
+	if key, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return errors.New("expected a PKCS#1 or PKCS#8 private key")
+		}
+	}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		k.method, k.private, k.public = jwt.SigningMethodRS256, key, &key.PublicKey
+	case ed25519.PrivateKey:
+		k.method, k.private, k.public = jwt.SigningMethodEdDSA, key, key.Public()
+	default:
+		return errors.New("only RSA and Ed25519 keys are supported")
+	}
+	return nil
+}
+
+// signer is the key signing at now, the last one started
+func signer(now time.Time) *signingKey {
+	for i := len(keys) - 1; i >= 0; i-- {
+		if !keys[i].from.After(now) {
+			return &keys[i]
+		}
+	}
+	return nil
+}
+
+// retired tells whether the key i no longer checks tokens at now, its successor having signed for longer than keyOverlap
+func retired(i int, now time.Time) bool {
+	return i+1 < len(keys) && now.After(keys[i+1].from.Add(keyOverlap))
+}
+
+// sign signs claims with the current key, or JWT_SECRET when there are none
+func sign(claims jwt.MapClaims) (string, error) {
+	if len(keys) == 0 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+	}
+	k := signer(time.Now())
+	if k == nil {
+		return "", errors.New("no key signs yet, the first one starts later")
+	}
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.kid
+	return token.SignedString(k.private)
+}
+
+// keyFor finds what checks token: the key of its kid if it is not retired, with the algorithm of the key
+func keyFor(token *jwt.Token) (any, error) {
+	if len(keys) == 0 {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return jwtSecret, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	now := time.Now()
+	for i := range keys {
+		if keys[i].kid != kid {
+			continue
+		}
+		if retired(i, now) {
+			return nil, errors.New("key has been retired")
+		}
+		if token.Method.Alg() != keys[i].method.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return keys[i].public, nil
+	}
+	return nil, errors.New("unknown key")
+}
+
+// JWKS is the JSON Web Key Set of the keys that check tokens, for other services to verify them on their own
+func JWKS() map[string]any {
+	set := []map[string]any{}
+	now := time.Now()
+	for i, k := range keys {
+		if retired(i, now) {
+			continue
+		}
+		jwk := map[string]any{"kid": k.kid, "use": "sig", "alg": k.method.Alg()}
+		switch public := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"], jwk["crv"] = "OKP", "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(public)
+		}
+		set = append(set, jwk)
+	}
+	return map[string]any{"keys": set}
+}
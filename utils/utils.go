@@ -2,26 +2,21 @@ package utils
 
 import (
 	"context"
-	"errors"
 	"github.com/gofiber/fiber/v2/log"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"os"
 	"time"
 )
 
-// jwtSecret needed for JWT encryption (make it about 256 char long with some stuff)
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
+// jwtSecret needed for JWT encryption (make it about 256 char long with some stuff), LoadKeys reads it
+var jwtSecret []byte
 
 // VerifyToken verifies a token JWT validate
 func VerifyToken(tokenString string) jwt.MapClaims {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return jwtSecret, nil
-	})
+	token, err := jwt.Parse(tokenString, keyFor)
 	if err != nil {
 		return nil
 	}
@@ -31,18 +26,38 @@ func VerifyToken(tokenString string) jwt.MapClaims {
 	return nil
 }
 
-// GenerateToken used for JWT token, pass userID, role (in case of bank it is the name of it), and time (prefer 72 hours)
-func GenerateToken(userID, role string, tm time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+// GenerateToken used for JWT token, pass userID, role (in case of bank it is the name of it), the family of refresh
+// tokens it comes from and how long it lasts. Every token gets its own jti so it can be revoked
+func GenerateToken(userID, role, family string, ttl time.Duration) (string, error) {
+	return sign(jwt.MapClaims{
 		"user_id": userID,
 		"role":    role,
-		"exp":     time.Now().Add(tm * time.Hour).Unix(),
+		"jti":     uuid.NewString(),
+		"fam":     family,
+		"exp":     time.Now().Add(ttl).Unix(),
 	})
-	t, err := token.SignedString(jwtSecret)
-	if err != nil {
-		return "", err
+}
+
+// GenerateChallenge signs a login challenge for the user userID, tied to its objectID so it dies when that changes.
+// It has no role, AuthMiddleware never takes it for a token
+func GenerateChallenge(userID, objectID string, ttl time.Duration) (string, error) {
+	return sign(jwt.MapClaims{
+		"user_id":   userID,
+		"object_id": objectID,
+		"typ":       "challenge",
+		"exp":       time.Now().Add(ttl).Unix(),
+	})
+}
+
+// VerifyChallenge returns the user and objectID a valid challenge was signed for, ok is false for anything else
+func VerifyChallenge(challenge string) (userID, objectID string, ok bool) {
+	claims := VerifyToken(challenge)
+	if claims == nil || claims["typ"] != "challenge" {
+		return "", "", false
 	}
-	return t, nil
+	userID, _ = claims["user_id"].(string)
+	objectID, _ = claims["object_id"].(string)
+	return userID, objectID, userID != ""
 }
 
 // MongoDatabase create a mongoDatabase pointer and off you go!
@@ -61,16 +76,33 @@ func MongoDatabase() *mongo.Database {
 	return client.Database(os.Getenv("MONGODB_DATABASE"))
 }
 
+// GetEnv returns the env var key or fallback when it is not set
+func GetEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
 // CheckEnv .env for var
 func CheckEnv() {
 	log.Info("Checking environment variables")
 	// Create sorta List obj to store our things
 	vars := map[string]string{
-		"MONGODB_URI":      "MongoDB URI (MONGODB_URI)",
-		"JWT_SECRET":       "JWT Secret (JWT_SECRET)",
-		"BANK_256_CODE":    "Bank 256 Code (BANK_256_CODE)",
-		"MONGODB_DATABASE": "MongoDB Database (MONGODB_DATABASE)",
-		"ADDR":             "Address (ADDR)",
+		"BANK_256_CODE": "Bank 256 Code (BANK_256_CODE)",
+		"ADDR":          "Address (ADDR)",
+	}
+	// Tokens are signed with the keys of JWT_KEYS, or with JWT_SECRET without them
+	if os.Getenv("JWT_KEYS") == "" {
+		vars["JWT_SECRET"] = "JWT Secret (JWT_SECRET)"
+	}
+	// Database settings depend on the backend, mongo is the default one
+	switch os.Getenv("STORE_BACKEND") {
+	case "", "mongo":
+		vars["MONGODB_URI"] = "MongoDB URI (MONGODB_URI)"
+		vars["MONGODB_DATABASE"] = "MongoDB Database (MONGODB_DATABASE)"
+	case "postgres":
+		vars["SQL_DSN"] = "PostgreSQL DSN (SQL_DSN)"
 	}
 	var missingVars []string
 